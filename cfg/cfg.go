@@ -14,6 +14,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/ava-labs/avash/node"
 	"github.com/ava-labs/avash/utils/logging"
 	"github.com/mitchellh/go-homedir"
 	"github.com/spf13/viper"
@@ -22,11 +23,17 @@ import (
 // Configuration is a shell-usable wrapper of the config file
 type Configuration struct {
 	AvalancheLocation, DataDir string
-	Log                        logging.Log
+	// PortRange is the range of ports allocated to nodes, as low-high
+	PortRange string
+	// Profiles are named sets of node flags, given to startnode with --profile
+	Profiles map[string]node.Profile
+	Log      logging.Log
 }
 
 type configFile struct {
 	AvalancheLocation, DataDir string
+	PortRange                  string
+	Profiles                   map[string]map[string]interface{}
 	Log                        configFileLog
 }
 
@@ -40,6 +47,9 @@ var Config Configuration
 // DefaultCfgName is the default config filename
 const DefaultCfgName = ".avash.yaml"
 
+// DefaultPortRange is the default range of ports allocated to nodes
+const DefaultPortRange = "9650-9999"
+
 // DefaultCfgNameShort is the default config filename with yml extension
 const DefaultCfgNameShort = ".avash.yml"
 
@@ -114,6 +124,17 @@ func InitConfig(cfgpath string) {
 		os.Exit(1)
 	}
 
+	// Set default `portRange` if missing
+	if config.PortRange == "" {
+		config.PortRange = DefaultPortRange
+	}
+
+	profiles, err := makeProfiles(config.Profiles)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
 	// Configure and create log
 	logCfg := makeLogConfig(config.Log, config.DataDir)
 	log, err := logging.New(logCfg)
@@ -125,12 +146,32 @@ func InitConfig(cfgpath string) {
 	Config = Configuration{
 		AvalancheLocation: config.AvalancheLocation,
 		DataDir:           config.DataDir,
+		PortRange:         config.PortRange,
+		Profiles:          profiles,
 		Log:               *log,
 	}
 	Config.Log.Info("Config file set: %s", viper.ConfigFileUsed())
 	Config.Log.Info("Avash successfully configured.")
 }
 
+// Parses the profiles of the config file, failing unless each one resolves
+func makeProfiles(config map[string]map[string]interface{}) (map[string]node.Profile, error) {
+	profiles := make(map[string]node.Profile, len(config))
+	for name, raw := range config {
+		p, err := node.ParseProfile(raw)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid profile %s: %s", name, err.Error())
+		}
+		profiles[name] = p
+	}
+	for name := range profiles {
+		if _, err := node.ResolveProfile(profiles, name); err != nil {
+			return nil, fmt.Errorf("Invalid profile %s: %s", name, err.Error())
+		}
+	}
+	return profiles, nil
+}
+
 func makeLogConfig(config configFileLog, dataDir string) logging.Config {
 	terminalLvl, err := logging.ToLevel(config.Terminal)
 	if err != nil && config.Terminal != "" {
@@ -51,150 +51,129 @@ var AVAXWalletNewKeyCmd = &cobra.Command{
 
 // AVAXWalletSendCmd will send a transaction through a node
 var AVAXWalletSendCmd = &cobra.Command{
-	Use:   "send [node name] [tx string]",
+	Use:   "send [node name or glob] [tx string]",
 	Short: "Sends a transaction to a node.",
 	Long:  `Sends a transaction to a node.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		if len(args) >= 2 {
-			log := cfg.Config.Log
-			if meta, err := pmgr.ProcManager.Metadata(args[0]); err == nil {
-				var md node.Metadata
-				metaBytes := []byte(meta)
-				if err := json.Unmarshal(metaBytes, &md); err == nil {
-					jrpcloc := fmt.Sprintf("http://%s:%s/ext/bc/avm", md.Serverhost, md.HTTPport)
-					rpcClient := jsonrpc.NewClient(jrpcloc)
-					response, err := rpcClient.Call("avm.issueTx", struct {
-						Tx string
-					}{
-						Tx: args[1],
-					})
-					if err != nil {
-						log.Error("error sent tx: %s", args[1])
-						log.Error("rpcClient returned error: %s", err.Error())
-					} else if response.Error != nil {
-						log.Error("error sent tx: %s", args[1])
-						log.Error("rpcClient returned error: %d, %s", response.Error.Code, response.Error.Message)
-					} else {
-						var s struct {
-							TxID string
-						}
-						err = response.GetObject(&s)
-						if err != nil {
-							log.Error("error on parsing response: %s", err.Error())
-						} else {
-							log.Info("TxID:%s", s.TxID)
-						}
-					}
-				} else {
-					log.Error("unable to unmarshal metadata for node %s: %s", args[0], err.Error())
-				}
+		names, args, ok := commandNodes(cmd, args, 1)
+		if !ok {
+			return
+		}
+		log := cfg.Config.Log
+		var results []nodeResult
+		for _, name := range names {
+			var s struct {
+				TxID string
+			}
+			err := avmCall(name, "avm.issueTx", struct {
+				Tx string
+			}{
+				Tx: args[0],
+			}, &s)
+			if err != nil {
+				log.Error("%serror sent tx: %s", nodePrefix(names, name), args[0])
+				log.Error(err.Error())
 			} else {
-				log.Error("node not found: %s", args[0])
+				log.Info("%sTxID:%s", nodePrefix(names, name), s.TxID)
 			}
-		} else {
-			cmd.Help()
+			results = append(results, newNodeResult(name, map[string]string{"txID": s.TxID}, err))
 		}
+		emitNodes(cmd, results)
 	},
 }
 
 // AVAXWalletStatusCmd will get the status of a transaction for a particular node
 var AVAXWalletStatusCmd = &cobra.Command{
-	Use:   "status [node name] [tx id]",
+	Use:   "status [node name or glob] [tx id]",
 	Short: "Checks the status of a transaction on a node.",
 	Long:  `Checks the status of a transaction on a node.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		if len(args) >= 2 {
-			log := cfg.Config.Log
-			if meta, err := pmgr.ProcManager.Metadata(args[0]); err == nil {
-				var md node.Metadata
-				metaBytes := []byte(meta)
-				if err := json.Unmarshal(metaBytes, &md); err == nil {
-					jrpcloc := fmt.Sprintf("http://%s:%s/ext/bc/avm", md.Serverhost, md.HTTPport)
-					rpcClient := jsonrpc.NewClient(jrpcloc)
-					response, err := rpcClient.Call("avm.getTxStatus", struct {
-						TxID string
-					}{
-						TxID: args[1],
-					})
-					if err != nil {
-						log.Error("error sent txid: %s", args[1])
-						log.Error("rpcClient returned error: %s", err.Error())
-					} else if response.Error != nil {
-						log.Error("error sent txid: %s", args[1])
-						log.Error("rpcClient returned error: %d, %s", response.Error.Code, response.Error.Message)
-					} else {
-						var s struct {
-							Status string
-						}
-						err = response.GetObject(&s)
-						if err != nil {
-							log.Error("error on parsing response: %s", err.Error())
-						} else {
-							log.Info("Status:%s", s.Status)
-						}
-					}
-				} else {
-					log.Error("unable to unmarshal metadata for node %s: %s", args[0], err.Error())
-				}
+		names, args, ok := commandNodes(cmd, args, 1)
+		if !ok {
+			return
+		}
+		log := cfg.Config.Log
+		var results []nodeResult
+		for _, name := range names {
+			var s struct {
+				Status string
+			}
+			err := avmCall(name, "avm.getTxStatus", struct {
+				TxID string
+			}{
+				TxID: args[0],
+			}, &s)
+			if err != nil {
+				log.Error("%serror sent txid: %s", nodePrefix(names, name), args[0])
+				log.Error(err.Error())
 			} else {
-				log.Error("node not found: %s", args[0])
+				log.Info("%sStatus:%s", nodePrefix(names, name), s.Status)
 			}
-		} else {
-			cmd.Help()
+			results = append(results, newNodeResult(name, map[string]string{"txID": args[0], "status": s.Status}, err))
 		}
+		emitNodes(cmd, results)
 	},
 }
 
 // AVAXWalletGetBalanceCmd will get the balance of an address from a node
 var AVAXWalletGetBalanceCmd = &cobra.Command{
-	Use:   "balance [node name] [address]",
+	Use:   "balance [node name or glob] [address]",
 	Short: "Checks the balance of an address from a node.",
 	Long:  `Checks the balance of an address from a node.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		if len(args) >= 2 {
-			log := cfg.Config.Log
-			if meta, err := pmgr.ProcManager.Metadata(args[0]); err == nil {
-				var md node.Metadata
-				metaBytes := []byte(meta)
-				if err := json.Unmarshal(metaBytes, &md); err == nil {
-					jrpcloc := fmt.Sprintf("http://%s:%s/ext/bc/avm", md.Serverhost, md.HTTPport)
-					rpcClient := jsonrpc.NewClient(jrpcloc)
-					response, err := rpcClient.Call("avm.getBalance", struct {
-						Address string
-						AssetID string
-					}{
-						Address: args[1],
-						AssetID: "AVAX",
-					})
-					if err != nil {
-						log.Error("error sent address: %s", args[1])
-						log.Error("rpcClient returned error: %s", err.Error())
-					} else if response.Error != nil {
-						log.Error("error sent address: %s", args[1])
-						log.Error("rpcClient returned error: %d, %s", response.Error.Code, response.Error.Message)
-					} else {
-						var s struct {
-							Balance string
-						}
-						err = response.GetObject(&s)
-						if err != nil {
-							log.Error("error on parsing response: %s", err.Error())
-						} else {
-							log.Info("Balance: %s", s.Balance)
-						}
-					}
-				} else {
-					log.Error("unable to unmarshal metadata for node %s: %s", args[0], err.Error())
-				}
+		names, args, ok := commandNodes(cmd, args, 1)
+		if !ok {
+			return
+		}
+		log := cfg.Config.Log
+		var results []nodeResult
+		for _, name := range names {
+			var s struct {
+				Balance string
+			}
+			err := avmCall(name, "avm.getBalance", struct {
+				Address string
+				AssetID string
+			}{
+				Address: args[0],
+				AssetID: "AVAX",
+			}, &s)
+			if err != nil {
+				log.Error("%serror sent address: %s", nodePrefix(names, name), args[0])
+				log.Error(err.Error())
 			} else {
-				log.Error("node not found: %s", args[0])
+				log.Info("%sBalance: %s", nodePrefix(names, name), s.Balance)
 			}
-		} else {
-			cmd.Help()
+			results = append(results, newNodeResult(name, map[string]string{"address": args[0], "assetID": "AVAX", "balance": s.Balance}, err))
 		}
+		emitNodes(cmd, results)
 	},
 }
 
+// avmCall issues the AVM API call to the node name, decoding the result into `res`
+func avmCall(name, method string, params, res interface{}) error {
+	meta, err := pmgr.ProcManager.Metadata(name)
+	if err != nil {
+		return fmt.Errorf("node not found: %s", name)
+	}
+	var md node.Metadata
+	if err := json.Unmarshal([]byte(meta), &md); err != nil {
+		return fmt.Errorf("unable to unmarshal metadata for node %s: %s", name, err.Error())
+	}
+	jrpcloc := fmt.Sprintf("http://%s:%s/ext/bc/avm", md.Serverhost, md.HTTPport)
+	rpcClient := jsonrpc.NewClient(jrpcloc)
+	response, err := rpcClient.Call(method, params)
+	if err != nil {
+		return fmt.Errorf("rpcClient returned error: %s", err.Error())
+	} else if response.Error != nil {
+		return fmt.Errorf("rpcClient returned error: %d, %s", response.Error.Code, response.Error.Message)
+	}
+	if err := response.GetObject(res); err != nil {
+		return fmt.Errorf("error on parsing response: %s", err.Error())
+	}
+	return nil
+}
+
 /*
 avaxwallet
 	create [wallet name] -> "wallet created: " + [wallet name]
@@ -213,4 +192,8 @@ func init() {
 	AVAXWalletCmd.AddCommand(AVAXWalletGetBalanceCmd)
 	AVAXWalletCmd.AddCommand(AVAXWalletSendCmd)
 	AVAXWalletCmd.AddCommand(AVAXWalletStatusCmd)
+
+	addSelectorFlag(AVAXWalletGetBalanceCmd)
+	addSelectorFlag(AVAXWalletSendCmd)
+	addSelectorFlag(AVAXWalletStatusCmd)
 }
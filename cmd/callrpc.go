@@ -13,58 +13,82 @@ import (
 
 // CallRPCCmd issues an RPC to a node endpoint using JSONRPC protocol
 var CallRPCCmd = &cobra.Command{
-	Use:     "callrpc [node name] [endpoint] [method] [JSON params] [var scope] [var name]",
+	Use:     "callrpc [node name or glob] [endpoint] [method] [JSON params] [var scope] [var name]",
 	Short:   "Issues an RPC call to a node.",
 	Long:    `Issues an RPC call to a node endpoint for the specified method and params.
-	Response is saved to the local varstore.`,
-	Example: `callrpc n1 ext/bc/X avm.getBalance {"address":"X-KqpU28P2ipUxfTfwaT847wWxyXB4XuWad","assetID":"AVAX"} s v`,
-	Args: cobra.MinimumNArgs(6),
+	Response is saved to the local varstore. When several nodes are targeted by a glob
+	or selector, each response is saved to "[var name].[node name]".`,
+	Example: `callrpc n1 ext/bc/X avm.getBalance {"address":"X-KqpU28P2ipUxfTfwaT847wWxyXB4XuWad","assetID":"AVAX"} s v
+callrpc -l role=api ext/info info.getNodeID {} s v`,
 	Run: func(cmd *cobra.Command, args []string) {
-		log := cfg.Config.Log
-		meta, err := pmgr.ProcManager.Metadata(args[0])
-		if err != nil {
-			log.Error("process not found: %s", args[0])
+		names, args, ok := commandNodes(cmd, args, 5)
+		if !ok {
 			return
 		}
-		var md node.Metadata
-		if err = json.Unmarshal([]byte(meta), &md); err != nil {
-			log.Error("unable to unmarshal metadata for process %s: %s", args[0], err.Error())
-			return
-		}
-		base := "http"
-		if md.HTTPTLS {
-			base = "https"
-		}
-		jrpcloc := fmt.Sprintf("%s://%s:%s/%s", base, md.Serverhost, md.HTTPport, args[1])
-		log.Info(jrpcloc)
-		rpcClient := jsonrpc.NewClient(jrpcloc)
-		argMap := make(map[string]interface{})
-		if err = json.Unmarshal([]byte(args[3]), &argMap); err != nil {
-			log.Error("invalid JSON object: %s", args[3])
-			return
-		}
-		response, err := rpcClient.Call(args[2], argMap)
-		if err != nil {
-			log.Error("rpcClient returned error: %s", err.Error())
-			return
-		}
-		if response.Error != nil {
-			log.Error("rpcClient returned error: %d, %s", response.Error.Code, response.Error.Message)
-			return
+		var results []nodeResult
+		for _, name := range names {
+			varName := args[4]
+			if len(names) > 1 {
+				varName += "." + name
+			}
+			res, err := callRPC(name, args[0], args[1], args[2], args[3], varName)
+			if err != nil {
+				cfg.Config.Log.Error(err.Error())
+			}
+			results = append(results, newNodeResult(name, res, err))
 		}
-		resBytes, err := json.Marshal(response.Result)
-		if err != nil {
-			log.Error("rpcClient returned invalid JSON object: %v", response.Result)
-			return
-		}
-		resVal := string(resBytes)
-		log.Info("Response: %s", resVal)
-		store, err := AvashVars.Get(args[4])
-		if err != nil {
-			log.Error("store not found: %s", args[4])
-			return
-		}
-		store.Set(args[5], resVal)
-		log.Info("Response saved to %q.%q", args[4], args[5])
+		emitNodes(cmd, results)
 	},
 }
+
+// callRPC issues the RPC to the node name, saving the response to the varstore.
+// Returns the response and the variable it is saved to.
+func callRPC(name, endpoint, method, params, scope, varName string) (interface{}, error) {
+	log := cfg.Config.Log
+	meta, err := pmgr.ProcManager.Metadata(name)
+	if err != nil {
+		return nil, fmt.Errorf("process not found: %s", name)
+	}
+	var md node.Metadata
+	if err = json.Unmarshal([]byte(meta), &md); err != nil {
+		return nil, fmt.Errorf("unable to unmarshal metadata for process %s: %s", name, err.Error())
+	}
+	base := "http"
+	if md.HTTPTLS {
+		base = "https"
+	}
+	jrpcloc := fmt.Sprintf("%s://%s:%s/%s", base, md.Serverhost, md.HTTPport, endpoint)
+	log.Info(jrpcloc)
+	rpcClient := jsonrpc.NewClient(jrpcloc)
+	argMap := make(map[string]interface{})
+	if err = json.Unmarshal([]byte(params), &argMap); err != nil {
+		return nil, fmt.Errorf("invalid JSON object: %s", params)
+	}
+	response, err := rpcClient.Call(method, argMap)
+	if err != nil {
+		return nil, fmt.Errorf("rpcClient returned error: %s", err.Error())
+	}
+	if response.Error != nil {
+		return nil, fmt.Errorf("rpcClient returned error: %d, %s", response.Error.Code, response.Error.Message)
+	}
+	resBytes, err := json.Marshal(response.Result)
+	if err != nil {
+		return nil, fmt.Errorf("rpcClient returned invalid JSON object: %v", response.Result)
+	}
+	resVal := string(resBytes)
+	log.Info("Response: %s", resVal)
+	store, err := AvashVars.Get(scope)
+	if err != nil {
+		return nil, fmt.Errorf("store not found: %s", scope)
+	}
+	store.Set(varName, resVal)
+	log.Info("Response saved to %q.%q", scope, varName)
+	return map[string]interface{}{
+		"response": response.Result,
+		"variable": scope + "." + varName,
+	}, nil
+}
+
+func init() {
+	addSelectorFlag(CallRPCCmd)
+}
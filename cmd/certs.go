@@ -0,0 +1,77 @@
+/*
+Copyright © 2019 AVA Labs <collin@avalabs.org>
+*/
+
+package cmd
+
+import (
+	"path/filepath"
+
+	"github.com/ava-labs/avash/cfg"
+	"github.com/ava-labs/avash/node"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+)
+
+// CertsCmd represents the certs command
+var CertsCmd = &cobra.Command{
+	Use:   "certs",
+	Short: "Tools for generating staking certificates.",
+	Long: `Tools for generating staking certificates and keys, allocated to nodes
+	started with "startnode --staking-cert auto".`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Help()
+	},
+}
+
+var (
+	certsCount = 1
+	certsOut   = node.DefaultCertsDir
+)
+
+// CertsGenerateCmd generates staking certificates and keys
+var CertsGenerateCmd = &cobra.Command{
+	Use:   "generate --count N --out certs/generated",
+	Short: "Generates staking certificates and keys.",
+	Long: `Generates staking certificates and keys as the client does, into the keys1,
+	keys2... subdirectories of the output directory following those already
+	generated, and prints their NodeIDs. The certificates are listed with their
+	NodeIDs in the index.json file of the directory. Certificates generated into
+	the default directory are allocated by "startnode --staking-cert auto".`,
+	Run: func(cmd *cobra.Command, args []string) {
+		count, out := certsCount, certsOut
+		// Set flags to default for next `generate` call
+		certsCount, certsOut = 1, node.DefaultCertsDir
+		log := cfg.Config.Log
+		generated, err := node.GenerateStakingCerts(out, count)
+		if err != nil {
+			log.Error("Unable to generate staking certificates: %s", err.Error())
+		}
+		index := filepath.Join(out, node.CertsIndexFile)
+		if structured() {
+			if generated == nil {
+				generated = []node.StakingCert{}
+			}
+			emit(cmd, map[string]interface{}{"index": index, "certs": generated}, err)
+			return
+		}
+		if len(generated) == 0 {
+			return
+		}
+		table := tablewriter.NewWriter(AvalancheShell.rl.Stdout())
+		table.SetHeader([]string{"Name", "NodeID", "Certificate", "Key"})
+		table.SetBorder(false)
+		for _, c := range generated {
+			certPath, keyPath := c.Paths(out)
+			table.Append([]string{c.Name, c.NodeID, certPath, keyPath})
+		}
+		table.Render()
+		log.Info("Generated %d staking certificates, listed in %s", len(generated), index)
+	},
+}
+
+func init() {
+	CertsGenerateCmd.Flags().IntVar(&certsCount, "count", certsCount, "Number of staking certificates to generate.")
+	CertsGenerateCmd.Flags().StringVar(&certsOut, "out", certsOut, "Directory to generate the staking certificates into.")
+	CertsCmd.AddCommand(CertsGenerateCmd)
+}
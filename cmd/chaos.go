@@ -0,0 +1,182 @@
+/*
+Copyright © 2019 AVA Labs <collin@avalabs.org>
+*/
+
+package cmd
+
+import (
+	"path/filepath"
+	"time"
+
+	"github.com/ava-labs/avash/cfg"
+	pmgr "github.com/ava-labs/avash/processmgr"
+	"github.com/spf13/cobra"
+)
+
+// DefaultChaosTimeline is the file in the data stash recording chaos campaigns
+const DefaultChaosTimeline = "chaos-timeline.json"
+
+// ChaosCmd represents the chaos command
+var ChaosCmd = &cobra.Command{
+	Use:   "chaos",
+	Short: "Kills and restarts nodes at random to test resilience.",
+	Long: `Runs campaigns killing random nodes and restarting them after a random
+	downtime, recording every fault in a timeline that can be replayed.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Help()
+	},
+}
+
+type chaosFlags struct {
+	Targets  string
+	KillRate string
+	Downtime string
+	Seed     int64
+	MaxDown  int
+	Duration time.Duration
+	Timeline string
+}
+
+func defaultChaosFlags() chaosFlags {
+	return chaosFlags{
+		KillRate: "1/5m",
+		Downtime: "30s-2m",
+		MaxDown:  1,
+	}
+}
+
+var chaosStart = defaultChaosFlags()
+
+// ChaosStartCmd starts a chaos campaign
+var ChaosStartCmd = &cobra.Command{
+	Use:   "start [optional: node name or glob] --targets role=validator --kill-rate 1/5m --downtime 30s-2m --seed 42 --max-down 1",
+	Short: "Starts a chaos campaign.",
+	Long: `Starts a chaos campaign on the nodes matching the name or glob and the
+	--targets label selector. At the kill rate on average, a random running target
+	is killed and restarted after a random downtime. A kill is skipped if it would
+	leave more than --max-down targets down, or fewer than a majority of the targets
+	running. Every fault is recorded in the timeline file, which chaos replay
+	reproduces. The campaign runs until chaos stop, or for --duration if given.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		flags := chaosStart
+		// Set flags to default for next `chaos start` call
+		chaosStart = defaultChaosFlags()
+		log := cfg.Config.Log
+		names, _, err := selectTargets(args, flags.Targets)
+		if err == errNoTargets {
+			cmd.Help()
+			return
+		} else if err != nil {
+			log.Error(err.Error())
+			return
+		}
+		seed := flags.Seed
+		if seed == 0 {
+			seed = time.Now().UnixNano()
+		}
+		path := flags.Timeline
+		if path == "" {
+			path = filepath.Join(cfg.Config.DataDir, DefaultChaosTimeline)
+		}
+		c := pmgr.ChaosConfig{
+			Targets:  names,
+			KillRate: flags.KillRate,
+			Downtime: flags.Downtime,
+			Seed:     seed,
+			MaxDown:  flags.MaxDown,
+			Duration: flags.Duration,
+		}
+		if err := pmgr.ProcManager.StartChaos(c, path); err != nil {
+			log.Error(err.Error())
+			return
+		}
+		log.Info("Chaos campaign started on %d targets with seed %d, recording to %s", len(names), seed, path)
+	},
+}
+
+// ChaosStopCmd stops the running chaos campaign
+var ChaosStopCmd = &cobra.Command{
+	Use:   "stop",
+	Short: "Stops the running chaos campaign.",
+	Long: `Stops the running chaos campaign or replay, restarting the nodes it has
+	left down.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := pmgr.ProcManager.StopChaos(); err != nil {
+			cfg.Config.Log.Error(err.Error())
+		}
+	},
+}
+
+// ChaosStatusCmd shows the running chaos campaign
+var ChaosStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Shows the running chaos campaign.",
+	Long:  `Shows the configuration and the faults so far of the running chaos campaign.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		log := cfg.Config.Log
+		t, ok := pmgr.ProcManager.ChaosTimeline()
+		if !ok {
+			log.Info("No chaos campaign is running.")
+			emit(cmd, nil, nil)
+			return
+		}
+		if structured() {
+			emit(cmd, t, nil)
+			return
+		}
+		c := t.Config
+		log.Info("Chaos campaign running for %s on %v", time.Since(t.Started).Round(time.Second), c.Targets)
+		log.Info("Kill rate %s, downtime %s, seed %d, max-down %d", c.KillRate, c.Downtime, c.Seed, c.MaxDown)
+		for _, e := range t.Events {
+			switch e.Action {
+			case pmgr.ChaosKill:
+				log.Info("  %s: kill %s for %s", e.At.Round(time.Millisecond), e.Target, e.Downtime)
+			case pmgr.ChaosStart:
+				log.Info("  %s: start %s", e.At.Round(time.Millisecond), e.Target)
+			case pmgr.ChaosSkip:
+				log.Info("  %s: skip (%s)", e.At.Round(time.Millisecond), e.Reason)
+			}
+		}
+	},
+}
+
+// ChaosReplayCmd replays a chaos timeline
+var ChaosReplayCmd = &cobra.Command{
+	Use:   "replay [timeline file]",
+	Short: "Replays the faults of a chaos campaign.",
+	Long: `Kills and restarts the nodes of a recorded chaos timeline at the same times
+	since its start, reproducing the campaign. Skipped kills are not replayed.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) != 1 {
+			cmd.Help()
+			return
+		}
+		log := cfg.Config.Log
+		t, err := pmgr.ReadChaosTimeline(args[0])
+		if err != nil {
+			log.Error(err.Error())
+			return
+		}
+		if err := pmgr.ProcManager.ReplayChaos(t); err != nil {
+			log.Error(err.Error())
+			return
+		}
+		log.Info("Replaying %d chaos events from %s", len(t.Events), args[0])
+	},
+}
+
+func init() {
+	ChaosCmd.AddCommand(ChaosReplayCmd)
+	ChaosCmd.AddCommand(ChaosStartCmd)
+	ChaosCmd.AddCommand(ChaosStatusCmd)
+	ChaosCmd.AddCommand(ChaosStopCmd)
+
+	fs := ChaosStartCmd.Flags()
+	fs.StringVar(&chaosStart.Targets, "targets", chaosStart.Targets, selectorUsage)
+	fs.StringVar(&chaosStart.KillRate, "kill-rate", chaosStart.KillRate, "Mean rate of kills, as count/interval.")
+	fs.StringVar(&chaosStart.Downtime, "downtime", chaosStart.Downtime, "Time a killed node stays down, as a duration or a min-max range.")
+	fs.Int64Var(&chaosStart.Seed, "seed", chaosStart.Seed, "Seed of the random choices. A random seed is picked and recorded if 0.")
+	fs.IntVar(&chaosStart.MaxDown, "max-down", chaosStart.MaxDown, "Maximum number of targets down at the same time.")
+	fs.DurationVar(&chaosStart.Duration, "duration", chaosStart.Duration, "End the campaign after this long. Runs until chaos stop if 0.")
+	fs.StringVar(&chaosStart.Timeline, "timeline", chaosStart.Timeline, "File recording the timeline. Defaults to "+DefaultChaosTimeline+" in the data stash.")
+}
@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestChaosStartTargets(t *testing.T) {
+	defer withShell(t)()
+	addSleep(t, "v1", true, "role=validator")
+	addSleep(t, "v2", true, "role=validator")
+	addSleep(t, "v3", true, "role=validator")
+	addSleep(t, "x1", true, "role=validator")
+	addSleep(t, "a1", true, "role=api")
+
+	for _, c := range []struct {
+		ln      string
+		targets []interface{}
+	}{
+		{"chaos start --targets role=validator --kill-rate 1/5m --downtime 30s-2m --seed 42 --max-down 1", []interface{}{"v1", "v2", "v3", "x1"}},
+		{"chaos start v* --targets role=validator --seed 42", []interface{}{"v1", "v2", "v3"}},
+	} {
+		execute(t, c.ln)
+		res, _ := execute(t, "chaos status --output json")
+		data, ok := res.Data.(map[string]interface{})
+		if !ok {
+			t.Fatalf("%s started no campaign", c.ln)
+		}
+		config := data["config"].(map[string]interface{})
+		if targets := config["targets"]; !reflect.DeepEqual(targets, c.targets) {
+			t.Fatalf("%s targeted %v expected %v", c.ln, targets, c.targets)
+		} else if seed := config["seed"]; seed != float64(42) {
+			t.Fatalf("%s recorded seed %v expected %d", c.ln, seed, 42)
+		}
+		execute(t, "chaos stop")
+	}
+}
@@ -6,6 +6,9 @@ package cmd
 
 import (
 	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
 
 	"github.com/ava-labs/avash/cfg"
 	pmgr "github.com/ava-labs/avash/processmgr"
@@ -18,12 +21,37 @@ var ExitCmd = &cobra.Command{
 	Short: "Exit the shell.",
 	Long:  `Exit the shell, attempting to gracefully stop all processes first.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		pmgr.ProcManager.StopAllProcesses()
-		if pmgr.ProcManager.HasRunning() {
-			cfg.Config.Log.Fatal("Unable to stop all processes, exiting anyway...")
-			os.Exit(1)
-		}
-		cfg.Config.Log.Info("Cleanup successful, exiting...")
-		os.Exit(0)
+		exitShell()
 	},
 }
+
+// exitShell stops all processes and exits
+func exitShell() {
+	pmgr.ProcManager.StopAllProcesses()
+	if pmgr.ProcManager.HasRunning() {
+		cfg.Config.Log.Fatal("Unable to stop all processes, exiting anyway...")
+		os.Exit(1)
+	}
+	cfg.Config.Log.Info("Cleanup successful, exiting...")
+	os.Exit(0)
+}
+
+// interruptListeners is the number of commands handling SIGINT themselves, such
+// as those following output until Ctrl-C. While any run, SIGINT does not exit.
+var interruptListeners int32
+
+// stopOnTermination exits the shell cleanly when avash is interrupted, terminated
+// or its terminal closes
+func stopOnTermination() {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	go func() {
+		for sig := range sigs {
+			if sig == os.Interrupt && atomic.LoadInt32(&interruptListeners) > 0 {
+				continue
+			}
+			cfg.Config.Log.Info("Received %s, stopping all processes...", sig)
+			exitShell()
+		}
+	}()
+}
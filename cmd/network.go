@@ -3,6 +3,7 @@ package cmd
 import (
 	"github.com/ava-labs/avash/cfg"
 	"github.com/ava-labs/avash/network"
+	"github.com/ava-labs/avash/node"
 	"github.com/spf13/cobra"
 )
 
@@ -16,6 +17,8 @@ var NetworkCommand = &cobra.Command{
 	},
 }
 
+var deployConfigMode = node.ConfigModeArgs
+
 // SSHDeployCommand deploys a network config through an SSH client
 var SSHDeployCommand = &cobra.Command{
 	Use: "deploy [config file]",
@@ -23,13 +26,20 @@ var SSHDeployCommand = &cobra.Command{
 	Long:  `Deploys a remote network of nodes from the provided config file.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		log := cfg.Config.Log
+		configMode := deployConfigMode
+		// Set flags to default for next `network deploy` call
+		deployConfigMode = node.ConfigModeArgs
+		if err := node.ValidateConfigMode(configMode); err != nil {
+			log.Error(err.Error())
+			return
+		}
 		netCfg, err := network.InitConfig(args[0])
 		if err != nil {
 			log.Error(err.Error())
 			return
 		}
 		log.Info("Deployment starting... (this process typically takes 3-6 minutes depending on host)")
-		if err := network.Deploy(netCfg, false); err != nil {
+		if err := network.Deploy(netCfg, false, configMode); err != nil {
 			log.Error(err.Error())
 			return
 		}
@@ -59,6 +69,7 @@ var SSHRemoveCommand = &cobra.Command{
 }
 
 func init() {
+	SSHDeployCommand.Flags().StringVar(&deployConfigMode, "config-mode", deployConfigMode, "How flags are given to the nodes. Should be one of {args, file}: file writes them into a JSON config file in each node's stash directory, passed with --config-file.")
 	NetworkCommand.AddCommand(SSHDeployCommand)
 	NetworkCommand.AddCommand(SSHRemoveCommand)
 }
\ No newline at end of file
@@ -0,0 +1,90 @@
+/*
+Copyright © 2019 AVA Labs <collin@avalabs.org>
+*/
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/ava-labs/avash/cfg"
+	"github.com/ava-labs/avash/node"
+	pmgr "github.com/ava-labs/avash/processmgr"
+	lua "github.com/yuin/gopher-lua"
+)
+
+// nodesStore is the variable store holding the identities of the started nodes,
+// as variables named `<node>.node-id`, `<node>.network-id` and `<node>.client-version`
+const nodesStore = "nodes"
+
+// nodeIdentity returns the metadata of the node, with its NodeID. A NodeID
+// missing from metadata generated by startnode, rather than given with --meta,
+// is queried from the running node and recorded.
+func nodeIdentity(name string) (node.Metadata, error) {
+	var md node.Metadata
+	meta, err := pmgr.ProcManager.Metadata(name)
+	if err != nil {
+		return md, err
+	}
+	if err := json.Unmarshal([]byte(meta), &md); err != nil {
+		return md, fmt.Errorf("unable to unmarshal metadata for node %s: %s", name, err.Error())
+	}
+	if md.NodeID != "" {
+		return md, nil
+	}
+	if !pmgr.ProcManager.MetadataGenerated(name) {
+		return md, fmt.Errorf("NodeID of %s unknown: its metadata was not generated by startnode", name)
+	}
+	id, err := node.QueryNodeID(md)
+	if err != nil {
+		return md, fmt.Errorf("NodeID of %s unknown: %s", name, err.Error())
+	}
+	md.NodeID = id
+	mdbytes, _ := json.MarshalIndent(md, " ", "    ")
+	if err := pmgr.ProcManager.SetMetadata(name, string(mdbytes)); err != nil {
+		return md, err
+	}
+	recordNodeVars(name, md)
+	return md, nil
+}
+
+// recordNodeVars sets the identity of the node in the nodes store, creating it if needed
+func recordNodeVars(name string, md node.Metadata) {
+	if _, err := AvashVars.Get(nodesStore); err != nil {
+		AvashVars.Create(nodesStore)
+	}
+	store, _ := AvashVars.Get(nodesStore)
+	for variable, value := range map[string]string{
+		"node-id":        md.NodeID,
+		"network-id":     md.NetworkID,
+		"client-version": md.ClientVersion,
+	} {
+		if value != "" {
+			store.Set(name+"."+variable, value)
+		}
+	}
+	if md.NodeID != "" {
+		cfg.Config.Log.Info("NodeID of %s: %s", name, md.NodeID)
+	}
+}
+
+// AvashNodeInfo returns a table of the identity of the named node, or nil and
+// an error message if its NodeID is unknown.
+// Lua usage: local info, err = avash.node_info("n1"); info.node_id
+func AvashNodeInfo(L *lua.LState) int {
+	md, err := nodeIdentity(L.CheckString(1))
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+	info := L.NewTable()
+	info.RawSetString("node_id", lua.LString(md.NodeID))
+	info.RawSetString("network_id", lua.LString(md.NetworkID))
+	info.RawSetString("client_version", lua.LString(md.ClientVersion))
+	info.RawSetString("http_port", lua.LString(md.HTTPport))
+	info.RawSetString("staking_port", lua.LString(md.Stakingport))
+	L.Push(info)
+	return 1
+}
@@ -0,0 +1,167 @@
+/*
+Copyright © 2019 AVA Labs <collin@avalabs.org>
+*/
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/ava-labs/avash/cfg"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+)
+
+// Output formats of command results
+const (
+	outputText = "text"
+	outputJSON = "json"
+	outputYAML = "yaml"
+)
+
+// outputFormat is the format of the running command's result. It is set for the
+// session by `avash --output` and for a single command by its `--output` flag.
+var outputFormat = outputText
+
+// sessionOutput is the format restored after every shell command
+var sessionOutput = outputText
+
+// resultWriter receives structured results, os.Stdout if nil
+var resultWriter io.Writer
+
+// Statuses of structured results
+const (
+	statusOK      = "ok"
+	statusError   = "error"
+	statusPartial = "partial"
+)
+
+// result is the document written by a command in json or yaml mode
+type result struct {
+	Command string      `json:"command" yaml:"command"`
+	Status  string      `json:"status" yaml:"status"`
+	Error   string      `json:"error,omitempty" yaml:"error,omitempty"`
+	Data    interface{} `json:"data" yaml:"data"`
+}
+
+// nodeResult is the result of a command for one of its target nodes
+type nodeResult struct {
+	Node   string      `json:"node" yaml:"node"`
+	Status string      `json:"status" yaml:"status"`
+	Error  string      `json:"error,omitempty" yaml:"error,omitempty"`
+	Result interface{} `json:"result,omitempty" yaml:"result,omitempty"`
+}
+
+// validateOutput returns an error if `format` is not an output format
+func validateOutput(format string) error {
+	switch format {
+	case outputText, outputJSON, outputYAML:
+		return nil
+	default:
+		return fmt.Errorf("invalid output format: %s. Should be one of {%s, %s, %s}", format, outputText, outputJSON, outputYAML)
+	}
+}
+
+// structured returns true if command results are written as documents
+func structured() bool {
+	return outputFormat == outputJSON || outputFormat == outputYAML
+}
+
+// resetOutput restores the session output format after a command
+func resetOutput() {
+	outputFormat = sessionOutput
+}
+
+// emit writes the result of `cmd` as a single document, if in json or yaml mode.
+// A non-nil `err` sets the error status.
+func emit(cmd *cobra.Command, data interface{}, err error) {
+	res := result{Command: cmd.CommandPath(), Status: statusOK, Data: data}
+	if err != nil {
+		res.Status, res.Error = statusError, err.Error()
+	}
+	emitResult(res)
+}
+
+// emitNodes writes the per-node results of `cmd`, if in json or yaml mode. The
+// document status is ok if every node succeeded, error if every node failed,
+// and partial otherwise.
+func emitNodes(cmd *cobra.Command, results []nodeResult) {
+	failed := 0
+	for _, r := range results {
+		if r.Status != statusOK {
+			failed++
+		}
+	}
+	res := result{Command: cmd.CommandPath(), Status: statusOK, Data: results}
+	switch {
+	case failed > 0 && failed == len(results):
+		res.Status = statusError
+	case failed > 0:
+		res.Status = statusPartial
+	}
+	emitResult(res)
+}
+
+// emitStream writes one result of a stream written by `cmd`, such as a refresh
+// of a watch, if in json or yaml mode. JSON results are written on a single line
+// each, and YAML results as documents starting with "---", so that a reader can
+// split the stream into results.
+func emitStream(cmd *cobra.Command, data interface{}) {
+	writeResult(result{Command: cmd.CommandPath(), Status: statusOK, Data: data}, true)
+}
+
+func emitResult(res result) {
+	writeResult(res, false)
+}
+
+// writeResult writes the result as a document, delimited within a stream if `stream`
+func writeResult(res result, stream bool) {
+	if !structured() {
+		return
+	}
+	var out []byte
+	var err error
+	switch {
+	case outputFormat == outputYAML && stream:
+		out, err = yaml.Marshal(res)
+		out = append([]byte("---\n"), out...)
+	case outputFormat == outputYAML:
+		out, err = yaml.Marshal(res)
+	case stream:
+		out, err = json.Marshal(res)
+		out = append(out, '\n')
+	default:
+		out, err = json.MarshalIndent(res, "", "    ")
+		out = append(out, '\n')
+	}
+	if err != nil {
+		cfg.Config.Log.Error("unable to marshal result: %s", err.Error())
+		return
+	}
+	w := resultWriter
+	if w == nil {
+		w = os.Stdout
+	}
+	w.Write(out)
+}
+
+// newNodeResult returns the result of a node, failed if `err` is not nil
+func newNodeResult(name string, res interface{}, err error) nodeResult {
+	if err != nil {
+		return nodeResult{Node: name, Status: statusError, Error: err.Error()}
+	}
+	return nodeResult{Node: name, Status: statusOK, Result: res}
+}
+
+// jsonValue decodes `s` if it is JSON, returning it unchanged otherwise, so that
+// JSON held in strings is nested in documents rather than quoted
+func jsonValue(s string) interface{} {
+	var v interface{}
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		return s
+	}
+	return v
+}
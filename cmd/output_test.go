@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// lockedBuffer is a buffer written by a command while the test reads it
+type lockedBuffer struct {
+	lock sync.Mutex
+	buf  bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.buf.String()
+}
+
+func TestOutputFlag(t *testing.T) {
+	defer withShell(t)()
+	addSleep(t, "o1", true)
+
+	for _, ln := range []string{
+		"procmanager list --output json",
+		"procmanager stats --output json",
+		"procmanager artifacts --output json",
+	} {
+		res, ok := execute(t, ln)
+		if !ok || res.Status != statusOK {
+			t.Fatalf("%s returned %v expected an ok result", ln, res)
+		}
+		if _, ok := res.Data.([]interface{}); !ok {
+			t.Fatalf("%s returned %v expected a list", ln, res.Data)
+		}
+	}
+	res, _ := execute(t, "procmanager stats o1 --output json")
+	if stats := res.Data.([]interface{}); len(stats) != 1 || stats[0].(map[string]interface{})["name"] != "o1" {
+		t.Fatalf("stats returned %v expected the stats of o1", stats)
+	}
+}
+
+// Runs `stats --watch` until it has refreshed three times, then interrupts it,
+// returning its output
+func watchStats(t *testing.T, format string, sep string) string {
+	var out lockedBuffer
+	resultWriter = &out
+	defer func() { resultWriter = nil }()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		AvalancheShell.execute("procmanager stats w1 --watch 10ms --output " + format)
+	}()
+	for i := 0; strings.Count(out.String(), sep) < 3; i++ {
+		if i == 500 {
+			t.Fatalf("stats --watch did not refresh: %s", out.String())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	// stats --watch receives SIGINT from the first refresh on
+	syscall.Kill(os.Getpid(), syscall.SIGINT)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("stats --watch was not interrupted")
+	}
+	return out.String()
+}
+
+func TestStatsWatch(t *testing.T) {
+	defer withShell(t)()
+	addSleep(t, "w1", true)
+
+	t.Run("JSON", func(t *testing.T) {
+		lines := strings.Split(strings.TrimSuffix(watchStats(t, outputJSON, "\n"), "\n"), "\n")
+		for _, line := range lines {
+			var res result
+			if err := json.Unmarshal([]byte(line), &res); err != nil {
+				t.Fatalf("stats --watch wrote an invalid JSON line %q: %s", line, err.Error())
+			} else if stats, ok := res.Data.([]interface{}); !ok || len(stats) != 1 {
+				t.Fatalf("stats --watch wrote %v expected the stats of w1", res.Data)
+			}
+		}
+	})
+	t.Run("YAML", func(t *testing.T) {
+		out := watchStats(t, outputYAML, "---\n")
+		if !strings.HasPrefix(out, "---\n") {
+			t.Fatalf("stats --watch wrote %q expected a leading document separator", out)
+		}
+		for _, doc := range strings.Split(out, "---\n")[1:] {
+			var res result
+			if err := yaml.Unmarshal([]byte(doc), &res); err != nil {
+				t.Fatalf("stats --watch wrote an invalid YAML document %q: %s", doc, err.Error())
+			} else if stats, ok := res.Data.([]interface{}); !ok || len(stats) != 1 {
+				t.Fatalf("stats --watch wrote %v expected the stats of w1", res.Data)
+			}
+		}
+	})
+}
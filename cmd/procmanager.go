@@ -5,13 +5,22 @@ Copyright © 2019 AVA Labs <collin@avalabs.org>
 package cmd
 
 import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
 	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/ava-labs/avash/cfg"
+	"github.com/ava-labs/avash/node"
 	pmgr "github.com/ava-labs/avash/processmgr"
 	"github.com/olekukonko/tablewriter"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
 // ProcmanagerCmd represents the procmanager command
@@ -26,31 +35,107 @@ var ProcmanagerCmd = &cobra.Command{
 	},
 }
 
+var listStats bool
+
 // PMListCmd represents the list operation on the procmanager command
 var PMListCmd = &cobra.Command{
-	Use:   "list",
+	Use:   "list [optional: node names or globs]",
 	Short: "Lists the processes currently running.",
-	Long:  `Lists the processes currently running in tabular format.`,
+	Long: `Lists the processes currently running in tabular format. Lists only the 
+	processes matching the given names, globs and selector, if any. With --stats, 
+	the resource usage columns of "procmanager stats" are included.`,
 	Run: func(cmd *cobra.Command, args []string) {
+		stats := listStats
+		// Set flags to default for next `list` call
+		listStats = false
+		names, ok := listTargets(cmd, args)
+		if !ok {
+			return
+		}
+		if structured() {
+			emit(cmd, pmgr.ProcManager.Processes(stats, names...), nil)
+			return
+		}
 		table := tablewriter.NewWriter(AvalancheShell.rl.Stdout())
-		table = pmgr.ProcManager.ProcessTable(table)
+		table = pmgr.ProcManager.ProcessTable(table, stats, names...)
 		table.Render()
 	},
 }
 
+var statsWatch time.Duration
+
+// PMStatsCmd prints the resource usage of processes
+var PMStatsCmd = &cobra.Command{
+	Use:   "stats [optional: node names or globs] --watch 2s",
+	Short: "Prints the resource usage of processes.",
+	Long: `Prints the PID, CPU usage, resident memory, open file descriptors, threads, 
+	uptime and stash disk usage of every process, or of the processes matching the 
+	given names, globs and selector. CPU% is the usage of one core since the previous 
+	sample. With --watch, the statistics are refreshed at the given interval until 
+	interrupted with Ctrl-C. In json mode each refresh is written as one line, and 
+	in yaml mode as a document starting with "---". Requires /proc.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		watch := statsWatch
+		// Set flags to default for next `stats` call
+		statsWatch = 0
+		names, ok := listTargets(cmd, args)
+		if !ok {
+			return
+		}
+		interrupted, release := notifyInterrupt()
+		defer release()
+		for {
+			if structured() && watch > 0 {
+				emitStream(cmd, pmgr.ProcManager.Stats(names...))
+			} else if structured() {
+				emit(cmd, pmgr.ProcManager.Stats(names...), nil)
+			} else {
+				table := tablewriter.NewWriter(AvalancheShell.rl.Stdout())
+				table = pmgr.ProcManager.StatsTable(table, names...)
+				table.Render()
+			}
+			if watch <= 0 {
+				return
+			}
+			select {
+			case <-time.After(watch):
+			case <-interrupted:
+				return
+			}
+		}
+	},
+}
+
 // PMMetadataCmd represents the list operation on the procmanager command
 var PMMetadataCmd = &cobra.Command{
 	Use:   "metadata [node name]",
 	Short: "Prints the metadata associated with the node name.",
-	Long:  `Prints the metadata associated with the node name.`,
+	Long: `Prints the metadata associated with the node name, including its NodeID,
+	network ID and client version. The NodeID of a running node started without a
+	staking certificate or --meta is queried from its info API.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		if len(args) >= 1 && args[0] != "" {
 			log := cfg.Config.Log
 			name := args[0]
+			// The NodeID of a node started without a staking certificate is queried once it runs
+			infos := pmgr.ProcManager.Processes(false, name)
+			if pmgr.ProcManager.MetadataGenerated(name) && len(infos) == 1 && infos[0].Status == pmgr.StateRunning.String() {
+				if _, err := nodeIdentity(name); err != nil {
+					log.Debug(err.Error())
+				}
+			}
 			metadata, err := pmgr.ProcManager.Metadata(name)
 			if err != nil {
 				log.Error(err.Error())
 			}
+			if structured() {
+				var data interface{}
+				if err == nil {
+					data = map[string]interface{}{"name": name, "metadata": jsonValue(metadata)}
+				}
+				emit(cmd, data, err)
+				return
+			}
 			log.Info(metadata)
 		} else {
 			cmd.Help()
@@ -60,193 +145,813 @@ var PMMetadataCmd = &cobra.Command{
 
 // PMStartCmd represents the start operation on the procmanager command
 var PMStartCmd = &cobra.Command{
-	Use:   "start [node name] [optional: delay in secs]",
-	Short: "Starts the process named if not currently running.",
-	Long:  `Starts the process named if not currently running.`,
+	Use:   "start [node name or glob] [optional: delay in secs] --every 10m",
+	Short: "Starts the processes named if not currently running.",
+	Long: `Starts the processes matching the name, glob or selector if not currently 
+	running. With a delay or --every, the start is scheduled as a job.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		if len(args) >= 1 && args[0] != "" {
-			log := cfg.Config.Log
-			name := args[0]
-			delay := time.Duration(0)
-			if len(args) >= 2 {
-				if v, e := strconv.ParseInt(args[1], 10, 64); e == nil && v > 0 {
-					delay = time.Duration(v)
-					log.Info("process will start in %ds: %s", int(delay), name)
-				}
-			}
-			start := func() {
-				err := pmgr.ProcManager.StartProcess(name)
-				if err != nil {
-					log.Error(err.Error())
+		names, args, ok := commandTargets(cmd, args)
+		if !ok {
+			return
+		}
+		scheduleRun("start", names, args, func(names []string) {
+			for _, name := range names {
+				if err := pmgr.ProcManager.StartProcess(name); err != nil {
+					cfg.Config.Log.Error(err.Error())
 				}
 			}
-			delayRun(start, delay)
-		} else {
-			cmd.Help()
-		}
+		})
 	},
 }
 
+var stopTimeout = pmgr.DefaultStopTimeout
+
 // PMStopCmd represents the stop operation on the procmanager command
 var PMStopCmd = &cobra.Command{
-	Use:   "stop [node name] [optional: delay in secs]",
-	Short: "Stops the process named if currently running.",
-	Long:  `Stops the process named if currently running.`,
+	Use:   "stop [node name or glob] [optional: delay in secs] --timeout 30s --every 10m",
+	Short: "Stops the processes named if currently running.",
+	Long: `Stops the processes matching the name, glob or selector if currently running. 
+	Each process is sent SIGINT, then SIGTERM if it has not exited within the timeout, 
+	and finally SIGKILL if it still has not exited after another timeout. With a 
+	delay or --every, the stop is scheduled as a job.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		if len(args) >= 1 && args[0] != "" {
-			log := cfg.Config.Log
-			name := args[0]
-			delay := time.Duration(0)
-			if len(args) >= 2 {
-				if v, e := strconv.ParseInt(args[1], 10, 64); e == nil && v > 0 {
-					delay = time.Duration(v)
-					log.Info("process will stop in %ds: %s", int(delay), name)
-				}
-			}
-			stop := func() {
-				err := pmgr.ProcManager.StopProcess(name)
-				if err != nil {
-					log.Error(err.Error())
+		timeout := stopTimeout
+		// Set flags to default for next `stop` call
+		stopTimeout = pmgr.DefaultStopTimeout
+		names, args, ok := commandTargets(cmd, args)
+		if !ok {
+			return
+		}
+		scheduleRun("stop", names, args, func(names []string) {
+			for _, name := range names {
+				if err := pmgr.ProcManager.StopProcessTimeout(name, timeout); err != nil {
+					cfg.Config.Log.Error(err.Error())
 				}
 			}
-			delayRun(stop, delay)
-		} else {
-			cmd.Help()
-		}
+		})
 	},
 }
 
 // PMKillCmd represents the stop operation on the procmanager command
 var PMKillCmd = &cobra.Command{
-	Use:   "kill [node name] [optional: delay in secs]",
-	Short: "Kills the process named if currently running.",
-	Long:  `Kills the process named if currently running.`,
+	Use:   "kill [node name or glob] [optional: delay in secs] --every 10m",
+	Short: "Kills the processes named if currently running.",
+	Long: `Kills the processes matching the name, glob or selector with SIGKILL if 
+	currently running, or being stopped by a stop that has not escalated to SIGKILL 
+	yet. With a delay or --every, the kill is scheduled as a job.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		if len(args) >= 1 && args[0] != "" {
-			log := cfg.Config.Log
-			name := args[0]
-			delay := time.Duration(0)
-			if len(args) >= 2 {
-				if v, e := strconv.ParseInt(args[1], 10, 64); e == nil && v > 0 {
-					delay = time.Duration(v)
-					log.Info("process will stop in %ds: %s", int(delay), name)
-				}
-			}
-			kill := func() {
-				err := pmgr.ProcManager.KillProcess(name)
-				if err != nil {
-					log.Error(err.Error())
+		names, args, ok := commandTargets(cmd, args)
+		if !ok {
+			return
+		}
+		scheduleRun("kill", names, args, func(names []string) {
+			for _, name := range names {
+				if err := pmgr.ProcManager.KillProcess(name); err != nil {
+					cfg.Config.Log.Error(err.Error())
 				}
 			}
-			delayRun(kill, delay)
-		} else {
-			cmd.Help()
-		}
+		})
 	},
 }
 
 // PMKillAllCmd stops all processes in the procmanager
 var PMKillAllCmd = &cobra.Command{
-	Use:   "killall [optional: delay in secs]",
+	Use:   "killall [optional: delay in secs] --every 10m",
 	Short: "Kills all processes if currently running.",
-	Long:  `Kills all processes if currently running.`,
+	Long: `Kills all processes if currently running or being stopped. With a delay or 
+	--every, the kill is scheduled as a job acting on the processes present when it runs.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		log := cfg.Config.Log
-		delay := time.Duration(0)
-		if len(args) >= 1 {
-			if v, e := strconv.ParseInt(args[0], 10, 64); e == nil && v > 0 {
-				delay = time.Duration(v)
-				log.Info("all processes will be killed in %ds", int(delay))
-			}
-		}
-		delayRun(pmgr.ProcManager.KillAllProcesses, delay)
+		scheduleRun("killall", nil, args, func([]string) {
+			pmgr.ProcManager.KillAllProcesses()
+		})
 	},
 }
 
+var stopAllTimeout = pmgr.DefaultStopTimeout
+
 // PMStopAllCmd stops all processes in the procmanager
 var PMStopAllCmd = &cobra.Command{
-	Use:   "stopall [optional: delay in secs]",
+	Use:   "stopall [optional: delay in secs] --timeout 30s --every 10m",
 	Short: "Stops all processes if currently running.",
-	Long:  `Stops all processes if currently running.`,
+	Long: `Stops all processes if currently running, escalating from SIGINT to SIGTERM 
+	to SIGKILL for any process that has not exited within the timeout. With a delay 
+	or --every, the stop is scheduled as a job acting on the processes present when 
+	it runs.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		log := cfg.Config.Log
-		delay := time.Duration(0)
-		if len(args) >= 1 {
-			if v, e := strconv.ParseInt(args[0], 10, 64); e == nil && v > 0 {
-				delay = time.Duration(v)
-				log.Info("all processes will stop in %ds", int(delay))
-			}
-		}
-		delayRun(pmgr.ProcManager.StopAllProcesses, delay)
+		timeout := stopAllTimeout
+		// Set flags to default for next `stopall` call
+		stopAllTimeout = pmgr.DefaultStopTimeout
+		scheduleRun("stopall", nil, args, func([]string) {
+			pmgr.ProcManager.StopAllProcessesTimeout(timeout)
+		})
 	},
 }
 
 // PMStartAllCmd starts all processes in the procmanager
 var PMStartAllCmd = &cobra.Command{
-	Use:   "startall [optional: delay in secs]",
+	Use:   "startall [optional: delay in secs] --every 10m",
 	Short: "Starts all processes if currently stopped.",
-	Long:  `Starts all processes if currently stopped.`,
+	Long: `Starts all processes if currently stopped. With a delay or --every, the 
+	start is scheduled as a job acting on the processes present when it runs.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		log := cfg.Config.Log
-		delay := time.Duration(0)
+		scheduleRun("startall", nil, args, func([]string) {
+			pmgr.ProcManager.StartAllProcesses()
+		})
+	},
+}
+
+// PMRemoveCmd represents the list operation on the procmanager command
+var PMRemoveCmd = &cobra.Command{
+	Use:   "remove [node name or glob] [optional: delay in secs] --every 10m",
+	Short: "Removes the processes named.",
+	Long: `Removes the processes matching the name, glob or selector. It will stop the 
+	processes if they are running. With a delay or --every, the removal is scheduled 
+	as a job.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		names, args, ok := commandTargets(cmd, args)
+		if !ok {
+			return
+		}
+		scheduleRun("remove", names, args, func(names []string) {
+			for _, name := range names {
+				if err := pmgr.ProcManager.RemoveProcess(name); err != nil {
+					cfg.Config.Log.Error(err.Error())
+				}
+			}
+		})
+	},
+}
+
+// PMArtifactsCmd lists the crash artifact bundles in the stash
+var PMArtifactsCmd = &cobra.Command{
+	Use:   "artifacts [optional: node name or glob]",
+	Short: "Lists the crash artifact bundles of processes.",
+	Long: `Lists the crash artifact bundles saved in the data stash of the processes 
+	matching the name or glob, or of every process. A bundle is saved whenever a 
+	process fails, holding its logs, captured output, command line, metadata, 
+	environment and exit status.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		pattern := ""
 		if len(args) >= 1 {
-			if v, e := strconv.ParseInt(args[0], 10, 64); e == nil && v > 0 {
-				delay = time.Duration(v)
-				log.Info("all processes will start in %ds", int(delay))
+			pattern = args[0]
+		}
+		artifacts, err := pmgr.ProcManager.Artifacts(pattern)
+		if err != nil {
+			cfg.Config.Log.Error(err.Error())
+			emit(cmd, nil, err)
+			return
+		}
+		if structured() {
+			if artifacts == nil {
+				artifacts = []pmgr.Artifact{}
 			}
+			emit(cmd, artifacts, nil)
+			return
 		}
-		delayRun(pmgr.ProcManager.StartAllProcesses, delay)
+		table := tablewriter.NewWriter(AvalancheShell.rl.Stdout())
+		pmgr.ArtifactsTable(table, artifacts).Render()
 	},
 }
 
-// PMRemoveCmd represents the list operation on the procmanager command
-var PMRemoveCmd = &cobra.Command{
-	Use:   "remove [node name] [optional: delay in secs]",
-	Short: "Removes the process named.",
-	Long:  `Removes the process named. It will stop the process if it is running.`,
+var pauseFor time.Duration
+
+// PMPauseCmd represents the pause operation on the procmanager command
+var PMPauseCmd = &cobra.Command{
+	Use:   "pause [node name or glob] --for 30s",
+	Short: "Pauses the processes named if currently running.",
+	Long: `Pauses the processes matching the name, glob or selector with SIGSTOP, 
+	leaving them alive but unresponsive until resumed. With --for, the processes 
+	are resumed after the duration. Paused processes can be stopped and killed.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		resume := pauseFor
+		// Set flags to default for next `pause` call
+		pauseFor = 0
+		names, _, ok := commandTargets(cmd, args)
+		if !ok {
+			return
+		}
+		for _, name := range names {
+			if err := pmgr.ProcManager.PauseProcess(name, resume); err != nil {
+				cfg.Config.Log.Error(err.Error())
+			}
+		}
+	},
+}
+
+// PMResumeCmd represents the resume operation on the procmanager command
+var PMResumeCmd = &cobra.Command{
+	Use:   "resume [node name or glob]",
+	Short: "Resumes the processes named if currently paused.",
+	Long:  `Resumes the paused processes matching the name, glob or selector with SIGCONT.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		if !(len(args) >= 1 && args[0] != "") {
+		names, _, ok := commandTargets(cmd, args)
+		if !ok {
+			return
+		}
+		for _, name := range names {
+			if err := pmgr.ProcManager.ResumeProcess(name); err != nil {
+				cfg.Config.Log.Error(err.Error())
+			}
+		}
+	},
+}
+
+// jobEvery is the interval of the job scheduled by the running command
+var jobEvery time.Duration
+
+// scheduleRun calls `run` with `names` now, or schedules it as a job if `args`,
+// the positional args following the targets, hold a delay in seconds or
+// `--every` is set. Nil names act on every process.
+func scheduleRun(action string, names []string, args []string, run func(names []string)) {
+	log := cfg.Config.Log
+	every := jobEvery
+	// Set flags to default for next call
+	jobEvery = 0
+	delay := time.Duration(0)
+	if len(args) > 1 {
+		log.Error("unexpected args: %s", strings.Join(args[1:], " "))
+		return
+	}
+	if len(args) == 1 {
+		v, err := strconv.ParseInt(args[0], 10, 64)
+		if err != nil || v < 0 {
+			log.Error("invalid delay in secs: %s", args[0])
+			return
+		}
+		delay = time.Duration(v) * time.Second
+	}
+	if delay == 0 && every == 0 {
+		run(names)
+		return
+	}
+	j, err := pmgr.ProcManager.Schedule(action, names, delay, every, run)
+	if err != nil {
+		log.Error(err.Error())
+		return
+	}
+	targets := "all processes"
+	if names != nil {
+		targets = strings.Join(names, ", ")
+	}
+	msg := fmt.Sprintf("Scheduled job %d: %s %s in %s", j.ID, action, targets, time.Until(j.Next).Round(time.Second))
+	if every > 0 {
+		msg += fmt.Sprintf(", every %s", every)
+	}
+	log.Info(msg)
+}
+
+// jobInfo is a pending job in json or yaml output
+type jobInfo struct {
+	ID      int       `json:"id" yaml:"id"`
+	Action  string    `json:"action" yaml:"action"`
+	Targets []string  `json:"targets" yaml:"targets"`
+	Next    time.Time `json:"next" yaml:"next"`
+	ETA     string    `json:"eta" yaml:"eta"`
+	Every   string    `json:"every,omitempty" yaml:"every,omitempty"`
+	Runs    int       `json:"runs" yaml:"runs"`
+}
+
+// PMJobsCmd lists the jobs scheduled in the procmanager
+var PMJobsCmd = &cobra.Command{
+	Use:   "jobs",
+	Short: "Lists the scheduled jobs.",
+	Long: `Lists the jobs scheduled by a delay or --every, with the time of their next 
+	run. Jobs acting on every process list no targets.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if !structured() {
+			table := tablewriter.NewWriter(AvalancheShell.rl.Stdout())
+			pmgr.ProcManager.JobsTable(table).Render()
+			return
+		}
+		jobs := []jobInfo{}
+		for _, j := range pmgr.ProcManager.Jobs() {
+			info := jobInfo{
+				ID:      j.ID,
+				Action:  j.Action,
+				Targets: j.Targets,
+				Next:    j.Next,
+				ETA:     time.Until(j.Next).Round(time.Second).String(),
+				Runs:    j.Runs,
+			}
+			if j.Every > 0 {
+				info.Every = j.Every.String()
+			}
+			jobs = append(jobs, info)
+		}
+		emit(cmd, jobs, nil)
+	},
+}
+
+// PMCancelCmd cancels a job scheduled in the procmanager
+var PMCancelCmd = &cobra.Command{
+	Use:   "cancel [job-id]",
+	Short: "Cancels a scheduled job.",
+	Long:  `Cancels the scheduled job with the ID listed by jobs.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) != 1 {
 			cmd.Help()
+			return
+		}
+		log := cfg.Config.Log
+		id, err := strconv.Atoi(args[0])
+		if err == nil {
+			err = pmgr.ProcManager.CancelJob(id)
+		} else {
+			err = fmt.Errorf("invalid job ID: %s", args[0])
+		}
+		if err != nil {
+			log.Error(err.Error())
+		} else {
+			log.Info("Cancelled job %d", id)
+		}
+		emit(cmd, nil, err)
+	},
+}
+
+const defaultLogsTail = 50
+
+var (
+	logsTail   = defaultLogsTail
+	logsFollow bool
+	logsStderr bool
+)
+
+// PMLogsCmd prints the captured console output of a process
+var PMLogsCmd = &cobra.Command{
+	Use:   "logs [node name]",
+	Short: "Prints the console output of the process named.",
+	Long: `Prints the stdout (or stderr) output captured from the process named. 
+	The output is also written to stdout.log and stderr.log in the node's stash 
+	directory. With --follow, new output is printed until interrupted with Ctrl-C.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) < 1 || args[0] == "" {
+			cmd.Help()
+			return
 		}
 		log := cfg.Config.Log
 		name := args[0]
-		delay := time.Duration(0)
-		if len(args) >= 2 {
-			if v, e := strconv.ParseInt(args[1], 10, 64); e == nil && v > 0 {
-				delay = time.Duration(v)
-				log.Info("process will be removed in %ds: %s", int(delay), name)
+		tail, follow, stderr := logsTail, logsFollow, logsStderr
+		// Set flags to default for next `logs` call
+		logsTail, logsFollow, logsStderr = defaultLogsTail, false, false
+
+		lines, err := pmgr.ProcManager.Output(name, stderr, tail)
+		if err != nil {
+			log.Error(err.Error())
+			return
+		}
+		for _, line := range lines {
+			log.Info("%s", line)
+		}
+		if !follow {
+			return
+		}
+		followed, stop, err := pmgr.ProcManager.FollowOutput(name, stderr)
+		if err != nil {
+			log.Error(err.Error())
+			return
+		}
+		defer stop()
+		interrupted, release := notifyInterrupt()
+		defer release()
+		for {
+			select {
+			case line, ok := <-followed:
+				if !ok {
+					return
+				}
+				log.Info("%s", line)
+			case <-interrupted:
+				return
 			}
 		}
-		remove := func() {
-			err := pmgr.ProcManager.RemoveProcess(name)
-			if err != nil {
-				log.Error(err.Error())
+	},
+}
+
+var eventsFollow bool
+
+// PMEventsCmd prints the lifecycle events of all processes
+var PMEventsCmd = &cobra.Command{
+	Use:   "events",
+	Short: "Prints the lifecycle events of all processes.",
+	Long: `Prints the lifecycle events (started, stopped, failed, restarting, paused, resumed) of all 
+	processes, oldest first. With --follow, new events are printed until interrupted 
+	with Ctrl-C.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		log := cfg.Config.Log
+		follow := eventsFollow
+		// Set flags to default for next `events` call
+		eventsFollow = false
+
+		var sub *pmgr.Subscription
+		if follow {
+			sub = pmgr.ProcManager.SubscribeEvents()
+			defer sub.Close()
+		}
+		for _, e := range pmgr.ProcManager.Events() {
+			log.Info("%s", e)
+		}
+		if !follow {
+			return
+		}
+		interrupted, release := notifyInterrupt()
+		defer release()
+		for {
+			select {
+			case e := <-sub.Events:
+				if dropped := sub.Dropped(); dropped > 0 {
+					log.Warn("%d events were dropped while printing fell behind", dropped)
+				}
+				log.Info("%s", e)
+			case <-interrupted:
+				return
 			}
 		}
-		delayRun(remove, delay)
 	},
 }
 
-func delayRun(f func(), delay time.Duration) {
-	if delay == 0 {
-		f()
-		return
-	}
-	timer := time.NewTimer(delay * time.Second)
+// DefaultWaitTimeout is the time `procmanager wait` and `startnode --wait` wait for nodes by default
+const DefaultWaitTimeout = 5 * time.Minute
+
+// waitInterval is the time between readiness probes
+const waitInterval = time.Second
+
+var waitUntil = node.Bootstrapped.String()
+
+var waitTimeout = DefaultWaitTimeout
+
+// PMWaitCmd waits for nodes to become ready
+var PMWaitCmd = &cobra.Command{
+	Use:   "wait [node name or glob] --until bootstrapped --timeout 5m",
+	Short: "Waits for nodes to become ready.",
+	Long: `Waits until the nodes matching the name, glob or selector are ready, or the 
+	timeout expires. Nodes are listening once their HTTP port accepts connections, 
+	healthy once the health API reports healthy, and bootstrapped once 
+	info.isBootstrapped is true for every chain. Each stage implies the previous 
+	ones. Reports the reason every node that is not ready failed its probe.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		untilFlag, timeout := waitUntil, waitTimeout
+		// Set flags to default for next `wait` call
+		waitUntil, waitTimeout = node.Bootstrapped.String(), DefaultWaitTimeout
+		until, err := node.ToReadiness(untilFlag)
+		if err != nil {
+			cfg.Config.Log.Error(err.Error())
+			return
+		}
+		names, _, ok := commandTargets(cmd, args)
+		if !ok {
+			return
+		}
+		waitNodes(cmd, names, until, timeout)
+	},
+}
+
+// waitNodes probes the named nodes concurrently until each one reaches readiness
+// `until` or the timeout expires, logging and emitting the result of every node.
+// Returns true if every node is ready.
+func waitNodes(cmd *cobra.Command, names []string, until node.Readiness, timeout time.Duration) bool {
+	log := cfg.Config.Log
+	log.Info("Waiting up to %s for %s to be %s", timeout, strings.Join(names, ", "), until)
+	interrupted, release := notifyInterrupt()
+	defer release()
+	stop := make(chan struct{})
 	go func() {
-		<-timer.C
-		f()
+		select {
+		case <-interrupted:
+			close(stop)
+		case <-time.After(timeout):
+		}
 	}()
+
+	start := time.Now()
+	errs := make([]error, len(names))
+	elapsed := make([]time.Duration, len(names))
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+			errs[i] = waitNode(name, until, start.Add(timeout), stop)
+			elapsed[i] = time.Since(start).Round(time.Millisecond)
+		}(i, name)
+	}
+	wg.Wait()
+
+	ready := true
+	var results []nodeResult
+	for i, name := range names {
+		if errs[i] != nil {
+			ready = false
+			log.Error("%s is not %s: %s", name, until, errs[i].Error())
+		} else {
+			log.Info("%s is %s", name, until)
+		}
+		results = append(results, newNodeResult(name, map[string]string{"readiness": until.String(), "elapsed": elapsed[i].String()}, errs[i]))
+	}
+	emitNodes(cmd, results)
+	return ready
+}
+
+// waitNode probes the named node until it reaches readiness `until`, returning
+// the reason it has not by the deadline or if it stops running
+func waitNode(name string, until node.Readiness, deadline time.Time, stop <-chan struct{}) error {
+	for {
+		retry, err := probeNode(name, until)
+		if err == nil || !retry {
+			return err
+		}
+		if time.Now().Add(waitInterval).After(deadline) {
+			return fmt.Errorf("timed out: %s", err.Error())
+		}
+		select {
+		case <-time.After(waitInterval):
+		case <-stop:
+			return fmt.Errorf("interrupted: %s", err.Error())
+		}
+	}
+}
+
+// probeNode probes the named node, returning on failure whether probing it again may succeed
+func probeNode(name string, until node.Readiness) (bool, error) {
+	infos := pmgr.ProcManager.Processes(false, name)
+	if len(infos) == 0 {
+		return false, fmt.Errorf("process not found: %s", name)
+	}
+	switch status := infos[0].Status; status {
+	case pmgr.StateRunning.String():
+	case "restarting", pmgr.StateStarting.String(), pmgr.StatePaused.String():
+		return true, fmt.Errorf("process is %s", status)
+	default:
+		return false, fmt.Errorf("process is %s", status)
+	}
+	meta, err := pmgr.ProcManager.Metadata(name)
+	if err != nil {
+		return false, err
+	}
+	var md node.Metadata
+	if err := json.Unmarshal([]byte(meta), &md); err != nil {
+		return false, fmt.Errorf("unable to unmarshal metadata for node %s: %s", name, err.Error())
+	}
+	return true, node.Probe(md, until)
+}
+
+// notifyInterrupt returns a channel receiving Ctrl-C interrupts and a function to stop listening
+func notifyInterrupt() (<-chan os.Signal, func()) {
+	sigs := make(chan os.Signal, 1)
+	atomic.AddInt32(&interruptListeners, 1)
+	signal.Notify(sigs, os.Interrupt)
+	return sigs, func() {
+		signal.Stop(sigs)
+		atomic.AddInt32(&interruptListeners, -1)
+	}
+}
+
+// policyFlags holds the supervision flags shared by `startnode` and `procmanager set-policy`
+type policyFlags struct {
+	Restart     string
+	MaxRestarts int
+	Backoff     time.Duration
+}
+
+func defaultPolicyFlags() policyFlags {
+	pol := pmgr.DefaultPolicy()
+	return policyFlags{
+		Restart:     pol.Restart.String(),
+		MaxRestarts: pol.MaxRestarts,
+		Backoff:     pol.Backoff,
+	}
+}
+
+func (pf *policyFlags) register(fs *pflag.FlagSet) {
+	fs.StringVar(&pf.Restart, "restart", pf.Restart, "Restart policy when the process exits on its own. Should be one of {never, on-failure, always}")
+	fs.IntVar(&pf.MaxRestarts, "max-restarts", pf.MaxRestarts, "Maximum number of restarts before giving up. Unlimited if 0.")
+	fs.DurationVar(&pf.Backoff, "restart-backoff", pf.Backoff, "Delay before the first restart, doubled on every subsequent attempt.")
+}
+
+func (pf *policyFlags) policy() (pmgr.Policy, error) {
+	restart, err := pmgr.ToRestartPolicy(pf.Restart)
+	if err != nil {
+		return pmgr.Policy{}, err
+	}
+	if pf.MaxRestarts < 0 {
+		return pmgr.Policy{}, fmt.Errorf("max restarts cannot be negative: %d", pf.MaxRestarts)
+	}
+	if pf.Backoff <= 0 {
+		return pmgr.Policy{}, fmt.Errorf("restart backoff must be positive: %s", pf.Backoff)
+	}
+	return pmgr.Policy{
+		Restart:     restart,
+		MaxRestarts: pf.MaxRestarts,
+		Backoff:     pf.Backoff,
+	}, nil
+}
+
+var setPolicyFlags = defaultPolicyFlags()
+
+// limitFlags holds the resource limit flags shared by `startnode` and `procmanager set-limits`
+type limitFlags struct {
+	AddressSpace string
+	NoFile       uint64
+	CPUTime      time.Duration
+	Nice         int
+	IONice       string
+}
+
+func (lf *limitFlags) register(fs *pflag.FlagSet) {
+	fs.StringVar(&lf.AddressSpace, "limit-address-space", lf.AddressSpace, "Maximum virtual memory of the process, e.g. 8GiB. Unlimited if empty.")
+	fs.Uint64Var(&lf.NoFile, "limit-nofile", lf.NoFile, "Maximum number of open files of the process. Unlimited if 0.")
+	fs.DurationVar(&lf.CPUTime, "limit-cpu-time", lf.CPUTime, "Maximum CPU time of the process, e.g. 2h. Unlimited if 0.")
+	fs.IntVar(&lf.Nice, "nice", lf.Nice, "Scheduling priority of the process, from -20 (highest) to 19 (lowest). Unchanged if 0.")
+	fs.StringVar(&lf.IONice, "ionice", lf.IONice, "I/O scheduling of the process as class[:level]. Class should be one of {realtime, best-effort, idle}, level from 0 (highest) to 7 (lowest).")
+}
+
+func (lf *limitFlags) limits() (pmgr.Limits, error) {
+	return pmgr.ParseLimits(lf.AddressSpace, lf.NoFile, lf.CPUTime, lf.Nice, lf.IONice)
+}
+
+var setLimitsFlags limitFlags
+
+// PMSetLimitsCmd sets the resource limits of a process
+var PMSetLimitsCmd = &cobra.Command{
+	Use:   "set-limits [node name] --limit-address-space=8GiB --limit-nofile=4096 --nice=10",
+	Short: "Sets the resource limits of the process named.",
+	Long: `Sets the resource limits of the process named, applied before it executes the
+	next time it starts. Limits not given are removed. A process ending with the signal
+	of the CPU time limit, or crashing after reporting a failed allocation under the
+	address space limit, is reported with the limit as its failure reason. This is a
+	heuristic. Requires Linux.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) < 1 || args[0] == "" {
+			cmd.Help()
+			return
+		}
+		log := cfg.Config.Log
+		name := args[0]
+		limits, err := setLimitsFlags.limits()
+		// Set flags to default for next `set-limits` call
+		setLimitsFlags = limitFlags{}
+		if err != nil {
+			log.Error(err.Error())
+			return
+		}
+		if err := pmgr.ProcManager.SetLimits(name, limits); err != nil {
+			log.Error(err.Error())
+			return
+		}
+		log.Info("Resource limits for %s set: %s", name, limits)
+	},
+}
+
+// PMSetPolicyCmd sets the restart policy of a process
+var PMSetPolicyCmd = &cobra.Command{
+	Use:   "set-policy [node name] --restart=on-failure --max-restarts=5",
+	Short: "Sets the restart policy of the process named.",
+	Long: `Sets the restart policy of the process named. With "on-failure" the process 
+	is restarted when it exits with an error, with "always" it is restarted whenever 
+	it exits on its own. Processes stopped or killed through avash are never restarted.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) < 1 || args[0] == "" {
+			cmd.Help()
+			return
+		}
+		log := cfg.Config.Log
+		name := args[0]
+		policy, err := setPolicyFlags.policy()
+		// Set flags to default for next `set-policy` call
+		setPolicyFlags = defaultPolicyFlags()
+		if err != nil {
+			log.Error(err.Error())
+			return
+		}
+		if err := pmgr.ProcManager.SetPolicy(name, policy); err != nil {
+			log.Error(err.Error())
+			return
+		}
+		log.Info("Restart policy for %s set: %s", name, policy)
+	},
+}
+
+// PMAdoptCmd reattaches processes left running by an ended avash session
+var PMAdoptCmd = &cobra.Command{
+	Use:   "adopt [optional: node names]",
+	Short: "Reattaches processes orphaned by a previous session.",
+	Long: `Reattaches processes left running by an avash session that ended without 
+	stopping them, such as after a crash. Adopted processes are managed like any 
+	other, but their console output is not captured. Adopts every orphaned process 
+	if no names are given.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		forOrphans(args, pmgr.ProcManager.AdoptProcess)
+	},
+}
+
+// PMReapCmd kills processes left running by an ended avash session
+var PMReapCmd = &cobra.Command{
+	Use:   "reap [optional: node names]",
+	Short: "Kills processes orphaned by a previous session.",
+	Long: `Kills processes left running by an avash session that ended without 
+	stopping them, such as after a crash. Kills every orphaned process if no names 
+	are given.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		forOrphans(args, pmgr.ProcManager.ReapProcess)
+	},
+}
+
+// forOrphans calls `f` with each name, or with every orphaned process if `names` is empty
+func forOrphans(names []string, f func(name string) error) {
+	log := cfg.Config.Log
+	if len(names) == 0 {
+		orphans, err := pmgr.ProcManager.Orphans()
+		if err != nil {
+			log.Error(err.Error())
+			return
+		}
+		if len(orphans) == 0 {
+			log.Info("No orphaned processes found.")
+			return
+		}
+		for _, o := range orphans {
+			names = append(names, o.Name)
+		}
+	}
+	for _, name := range names {
+		if err := f(name); err != nil {
+			log.Error(err.Error())
+		}
+	}
+}
+
+// warnOrphans reports processes left running by ended avash sessions
+func warnOrphans() {
+	log := cfg.Config.Log
+	orphans, err := pmgr.ProcManager.Orphans()
+	if err != nil {
+		log.Error(err.Error())
+		return
+	}
+	if len(orphans) == 0 {
+		return
+	}
+	log.Warn("Found %d process(es) left running by a previous avash session:", len(orphans))
+	for _, o := range orphans {
+		log.Warn("  %s (pid %d, started %s): %s", o.Name, o.PID, o.StartTime.Format(time.RFC3339), o.Metadata)
+	}
+	log.Warn("Use `procmanager adopt` to reattach them or `procmanager reap` to kill them.")
 }
 
 func init() {
+	ProcmanagerCmd.AddCommand(PMAdoptCmd)
+	ProcmanagerCmd.AddCommand(PMArtifactsCmd)
+	ProcmanagerCmd.AddCommand(PMCancelCmd)
+	ProcmanagerCmd.AddCommand(PMEventsCmd)
+	ProcmanagerCmd.AddCommand(PMJobsCmd)
 	ProcmanagerCmd.AddCommand(PMKillCmd)
 	ProcmanagerCmd.AddCommand(PMKillAllCmd)
 	ProcmanagerCmd.AddCommand(PMListCmd)
+	ProcmanagerCmd.AddCommand(PMLogsCmd)
 	ProcmanagerCmd.AddCommand(PMMetadataCmd)
+	ProcmanagerCmd.AddCommand(PMPauseCmd)
+	ProcmanagerCmd.AddCommand(PMReapCmd)
 	ProcmanagerCmd.AddCommand(PMRemoveCmd)
+	ProcmanagerCmd.AddCommand(PMResumeCmd)
+	ProcmanagerCmd.AddCommand(PMSetLimitsCmd)
+	ProcmanagerCmd.AddCommand(PMSetPolicyCmd)
+	ProcmanagerCmd.AddCommand(PMStatsCmd)
 	ProcmanagerCmd.AddCommand(PMStopCmd)
 	ProcmanagerCmd.AddCommand(PMStopAllCmd)
 	ProcmanagerCmd.AddCommand(PMStartAllCmd)
 	ProcmanagerCmd.AddCommand(PMStartCmd)
+	ProcmanagerCmd.AddCommand(PMWaitCmd)
+
+	PMLogsCmd.Flags().IntVar(&logsTail, "tail", logsTail, "Number of most recent lines to print. Prints all retained lines if 0.")
+	PMLogsCmd.Flags().BoolVar(&logsFollow, "follow", logsFollow, "Keep printing new output until interrupted.")
+	PMLogsCmd.Flags().BoolVar(&logsStderr, "stderr", logsStderr, "Print stderr instead of stdout.")
+
+	PMStopCmd.Flags().DurationVar(&stopTimeout, "timeout", stopTimeout, "Time to wait for the process to exit after each signal before escalating.")
+	PMStopAllCmd.Flags().DurationVar(&stopAllTimeout, "timeout", stopAllTimeout, "Time to wait for each process to exit after each signal before escalating.")
+
+	for _, c := range []*cobra.Command{PMStartCmd, PMStopCmd, PMKillCmd, PMRemoveCmd, PMStartAllCmd, PMStopAllCmd, PMKillAllCmd} {
+		c.Flags().DurationVar(&jobEvery, "every", jobEvery, "Repeat the action at this interval, first running it after the delay or else after one interval.")
+	}
+
+	PMPauseCmd.Flags().DurationVar(&pauseFor, "for", pauseFor, "Resume the processes after this long. Paused until resumed if 0.")
+
+	PMListCmd.Flags().BoolVar(&listStats, "stats", listStats, "Include resource usage columns.")
+	PMStatsCmd.Flags().DurationVar(&statsWatch, "watch", statsWatch, "Refresh the statistics at this interval until interrupted.")
+
+	PMWaitCmd.Flags().StringVar(&waitUntil, "until", waitUntil, "Readiness to wait for. Should be one of {listening, healthy, bootstrapped}.")
+	PMWaitCmd.Flags().DurationVar(&waitTimeout, "timeout", waitTimeout, "Time to wait for the nodes to become ready.")
+
+	PMEventsCmd.Flags().BoolVar(&eventsFollow, "follow", eventsFollow, "Keep printing new events until interrupted.")
+
+	setPolicyFlags.register(PMSetPolicyCmd.Flags())
+	setLimitsFlags.register(PMSetLimitsCmd.Flags())
+
+	addSelectorFlag(PMKillCmd)
+	addSelectorFlag(PMListCmd)
+	addSelectorFlag(PMPauseCmd)
+	addSelectorFlag(PMRemoveCmd)
+	addSelectorFlag(PMResumeCmd)
+	addSelectorFlag(PMStartCmd)
+	addSelectorFlag(PMStatsCmd)
+	addSelectorFlag(PMStopCmd)
+	addSelectorFlag(PMWaitCmd)
 }
@@ -0,0 +1,160 @@
+package cmd
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	pmgr "github.com/ava-labs/avash/processmgr"
+)
+
+// Starts a process ignoring SIGINT and SIGTERM, so that only SIGKILL stops it
+func addTrapped(t *testing.T, name string) {
+	args := []string{"-c", "trap '' INT TERM; echo ready; exec sleep 60"}
+	if err := pmgr.ProcManager.AddProcess("sh", "test", args, name, "{}", nil, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := pmgr.ProcManager.StartProcess(name); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; ; i++ {
+		if lines, _ := pmgr.ProcManager.Output(name, false, 1); len(lines) == 1 && lines[0] == "ready" {
+			return
+		} else if i == 500 {
+			t.Fatalf("process %s did not trap its signals", name)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// Returns the signal that ended the process
+func endSignal(t *testing.T, name string) string {
+	infos := pmgr.ProcManager.Processes(false, name)
+	if len(infos) != 1 {
+		t.Fatalf("process %s not found", name)
+	}
+	return infos[0].Signal
+}
+
+func TestStopTimeout(t *testing.T) {
+	defer withShell(t)()
+	for _, ln := range []string{
+		"procmanager stop t1 --timeout 50ms",
+		"procmanager stop --timeout 50ms t1",
+		"procmanager stopall --timeout 50ms",
+	} {
+		addTrapped(t, "t1")
+		start := time.Now()
+		execute(t, ln)
+		if status := processStatus(t, "t1"); status != pmgr.StateStopped.String() {
+			t.Fatalf("%s left t1 %s expected %s", ln, status, pmgr.StateStopped)
+		} else if sig := endSignal(t, "t1"); sig != "SIGKILL" {
+			t.Fatalf("%s ended t1 by %s expected SIGKILL", ln, sig)
+		} else if elapsed := time.Since(start); elapsed > 5*time.Second {
+			t.Fatalf("%s took %s, ignoring the timeout", ln, elapsed)
+		}
+		pmgr.ProcManager.RemoveProcess("t1")
+	}
+}
+
+func TestKillStopping(t *testing.T) {
+	defer withShell(t)()
+	addTrapped(t, "t1")
+	// The stop is scheduled, so that the kill runs while it waits on the process
+	execute(t, "procmanager stop t1 1 --timeout 1m")
+	waitStatus(t, "t1", pmgr.StateStopping.String())
+	execute(t, "procmanager kill t1")
+	if status := processStatus(t, "t1"); status != pmgr.StateStopped.String() {
+		t.Fatalf("kill left t1 %s expected %s", status, pmgr.StateStopped)
+	} else if sig := endSignal(t, "t1"); sig != "SIGKILL" {
+		t.Fatalf("kill ended t1 by %s expected SIGKILL", sig)
+	}
+}
+
+// Returns the jobs listed by `procmanager jobs`, by ID
+func listedJobs(t *testing.T) map[int]map[string]interface{} {
+	res, _ := execute(t, "procmanager jobs --output json")
+	infos, ok := res.Data.([]interface{})
+	if !ok {
+		t.Fatalf("jobs returned %v expected a list of jobs", res.Data)
+	}
+	jobs := make(map[int]map[string]interface{})
+	for _, info := range infos {
+		job := info.(map[string]interface{})
+		jobs[int(job["id"].(float64))] = job
+	}
+	return jobs
+}
+
+func TestScheduleJobs(t *testing.T) {
+	defer withShell(t)()
+	addSleep(t, "j1", true)
+
+	for _, ln := range []string{
+		"procmanager stop j1 --every 1h",
+		"procmanager stop --every 1h j1 30",
+		"procmanager kill j1 30",
+	} {
+		execute(t, ln)
+	}
+	jobs := listedJobs(t)
+	if len(jobs) != 3 {
+		t.Fatalf("jobs listed %v expected 3 jobs", jobs)
+	}
+	for _, job := range jobs {
+		if targets := job["targets"].([]interface{}); len(targets) != 1 || targets[0] != "j1" {
+			t.Fatalf("jobs listed targets %v expected [j1]", targets)
+		}
+	}
+	if status := processStatus(t, "j1"); status != pmgr.StateRunning.String() {
+		t.Fatalf("scheduling left j1 %s expected %s", status, pmgr.StateRunning)
+	}
+	execute(t, "procmanager stop j1 soon")
+	if len(listedJobs(t)) != 3 {
+		t.Fatalf("stop scheduled a job for an invalid delay")
+	}
+
+	for id := range jobs {
+		if res, _ := execute(t, fmt.Sprintf("procmanager cancel %d --output json", id)); res.Status != statusOK {
+			t.Fatalf("cancel %d returned %s: %s", id, res.Status, res.Error)
+		}
+		if _, ok := listedJobs(t)[id]; ok {
+			t.Fatalf("cancel left job %d scheduled", id)
+		}
+	}
+	if res, _ := execute(t, "procmanager cancel 1000 --output json"); res.Status != statusError {
+		t.Fatalf("cancel returned %s for an unknown job expected %s", res.Status, statusError)
+	}
+}
+
+func TestListStats(t *testing.T) {
+	defer withShell(t)()
+	addSleep(t, "s1", true)
+	addSleep(t, "s2", false)
+
+	for _, ln := range []string{
+		"procmanager list --stats --output json",
+		"procmanager list --output json --stats s*",
+	} {
+		res, _ := execute(t, ln)
+		infos, _ := res.Data.([]interface{})
+		if len(infos) != 2 {
+			t.Fatalf("%s listed %v expected s1 and s2", ln, res.Data)
+		}
+		for _, info := range infos {
+			stats, ok := info.(map[string]interface{})["stats"].(map[string]interface{})
+			if !ok {
+				t.Fatalf("%s listed %v without stats", ln, info)
+			}
+			if name := info.(map[string]interface{})["name"]; name == "s1" && stats["pid"] == nil {
+				t.Fatalf("%s listed stats %v for s1 expected its PID", ln, stats)
+			}
+		}
+	}
+	res, _ := execute(t, "procmanager list --output json")
+	for _, info := range res.Data.([]interface{}) {
+		if _, ok := info.(map[string]interface{})["stats"]; ok {
+			t.Fatalf("list listed stats without --stats")
+		}
+	}
+}
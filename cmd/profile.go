@@ -0,0 +1,136 @@
+/*
+Copyright © 2019 AVA Labs <collin@avalabs.org>
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ava-labs/avash/cfg"
+	"github.com/ava-labs/avash/node"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+)
+
+// profileInfo is the structured result of a profile
+type profileInfo struct {
+	Name string `json:"name" yaml:"name"`
+	// Chain is the profile followed by those it inherits from
+	Chain []string       `json:"chain" yaml:"chain"`
+	Flags node.FlagsYAML `json:"flags" yaml:"flags"`
+}
+
+// ProfileCmd represents the profile command
+var ProfileCmd = &cobra.Command{
+	Use:   "profile",
+	Short: "Lists and shows the node flag profiles of the config file.",
+	Long: `Lists and shows the profiles of node flags defined under "profiles" in the
+	config file, applied to nodes with "startnode --profile".`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Help()
+	},
+}
+
+// ProfileListCmd lists the profiles
+var ProfileListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Lists the profiles.",
+	Long:  `Lists the profiles with the profile each inherits from and the number of flags it sets.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		profiles := cfg.Config.Profiles
+		names := node.ProfileNames(profiles)
+		if structured() {
+			infos := []profileInfo{}
+			for _, name := range names {
+				info, err := newProfileInfo(name)
+				if err != nil {
+					emit(cmd, nil, err)
+					return
+				}
+				infos = append(infos, info)
+			}
+			emit(cmd, infos, nil)
+			return
+		}
+		if len(names) == 0 {
+			cfg.Config.Log.Info("No profiles defined in the config file.")
+			return
+		}
+		table := tablewriter.NewWriter(AvalancheShell.rl.Stdout())
+		table.SetHeader([]string{"Profile", "Inherits", "Flags"})
+		table.SetBorder(false)
+		for _, name := range names {
+			p := profiles[name]
+			table.Append([]string{name, p.Inherits, strconv.Itoa(len(p.Flags))})
+		}
+		table.Render()
+	},
+}
+
+// ProfileShowCmd shows the resolved flags of a profile
+var ProfileShowCmd = &cobra.Command{
+	Use:   "show [profile name]",
+	Short: "Shows the flags of a profile.",
+	Long: `Shows the flags a profile sets, including those it inherits, with the profile
+	defining each one.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) < 1 {
+			cmd.Help()
+			return
+		}
+		info, err := newProfileInfo(args[0])
+		if err != nil {
+			cfg.Config.Log.Error(err.Error())
+			emit(cmd, nil, err)
+			return
+		}
+		if structured() {
+			emit(cmd, info, nil)
+			return
+		}
+		var names []string
+		for name := range info.Flags {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		cfg.Config.Log.Info("Profile %s", strings.Join(info.Chain, " <- "))
+		table := tablewriter.NewWriter(AvalancheShell.rl.Stdout())
+		table.SetHeader([]string{"Flag", "Value", "Profile"})
+		table.SetBorder(false)
+		for _, name := range names {
+			table.Append([]string{name, fmt.Sprint(info.Flags[name]), definingProfile(info.Chain, name)})
+		}
+		table.Render()
+	},
+}
+
+func newProfileInfo(name string) (profileInfo, error) {
+	chain, err := node.ProfileChain(cfg.Config.Profiles, name)
+	if err != nil {
+		return profileInfo{}, err
+	}
+	flags, err := node.ResolveProfile(cfg.Config.Profiles, name)
+	if err != nil {
+		return profileInfo{}, err
+	}
+	return profileInfo{Name: name, Chain: chain, Flags: flags}, nil
+}
+
+// definingProfile returns the first profile of the chain setting the flag
+func definingProfile(chain []string, flag string) string {
+	for _, name := range chain {
+		if _, ok := cfg.Config.Profiles[name].Flags[flag]; ok {
+			return name
+		}
+	}
+	return ""
+}
+
+func init() {
+	ProfileCmd.AddCommand(ProfileListCmd)
+	ProfileCmd.AddCommand(ProfileShowCmd)
+}
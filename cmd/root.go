@@ -82,54 +82,89 @@ func (sh *Shell) ShellLoop() {
 
 	for {
 		ln, err := sh.rl.Readline()
-		if err != nil {
-			continue
+		// At the prompt, readline reads Ctrl-C rather than the terminal raising
+		// SIGINT. On an empty line it exits cleanly, as SIGINT does.
+		if err == readline.ErrInterrupt && ln == "" {
+			cfg.Config.Log.Info("Interrupted, stopping all processes...")
+			exitShell()
 		}
-		cmd, flags, err := sh.root.Find(strings.Fields(ln))
 		if err != nil {
-			sh.rl.Terminal.Write([]byte(err.Error()))
-		}
-		sh.addHistory(cmd, flags)
-		if err := cmd.ParseFlags(flags); err != nil {
-			cfg.Config.Log.Error(err.Error())
-			continue
-		}
-		if err := cmd.ValidateArgs(flags); err != nil {
-			cfg.Config.Log.Error(err.Error())
 			continue
 		}
-		cmd.Run(cmd, flags)
+		sh.execute(ln)
 	}
 }
 
+// execute runs the command line `ln` as entered into the shell
+func (sh *Shell) execute(ln string) {
+	cmd, flags, err := sh.root.Find(strings.Fields(ln))
+	if err != nil {
+		sh.rl.Terminal.Write([]byte(err.Error()))
+	}
+	sh.addHistory(cmd, flags)
+	if err := runCommand(cmd, flags); err != nil {
+		cfg.Config.Log.Error(err.Error())
+	}
+	resetOutput()
+}
+
+// runCommand parses the flags of `cmd` from `flags` and runs it with the
+// remaining positional args, as cobra does when executing a command
+func runCommand(cmd *cobra.Command, flags []string) error {
+	if err := cmd.ParseFlags(flags); err != nil {
+		return err
+	}
+	args := cmd.Flags().Args()
+	if err := cmd.ValidateArgs(args); err != nil {
+		return err
+	}
+	if err := validateOutput(outputFormat); err != nil {
+		return err
+	}
+	cmd.Run(cmd, args)
+	return nil
+}
+
 // AvalancheShell is the shell for our little client
 var AvalancheShell *Shell
 var RootCmd *cobra.Command
 
+// cfgPath is the path of the config file, read before the root command runs
+var cfgPath string
+
 func init() {
 	AvalancheShell = new(Shell)
 	// allow config file path to be set by user
-	var cfgpath string
-	pflag.StringVar(&cfgpath, "config", cfg.DefaultCfgName, "Config file path")
+	pflag.StringVar(&cfgPath, "config", cfg.DefaultCfgName, "Config file path")
+	// Also inherited by every command, to override the format of a single result
+	pflag.StringVar(&outputFormat, "output", outputText, "Format of command results. Should be one of {text, json, yaml}.")
 	pflag.Parse()
+	sessionOutput = outputFormat
 
 	RootCmd = &cobra.Command{
 		Use:   "avash",
 		Short: "A shell environment for one or more Avalanche nodes",
 		Long:  "A shell environment for launching and interacting with multiple Avalanche nodes.",
 		Args:  cobra.NoArgs,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return validateOutput(outputFormat)
+		},
 		Run: func(cmd *cobra.Command, args []string) {
+			stopOnTermination()
+			warnOrphans()
 			AvalancheShell.ShellLoop()
 		},
 		SilenceUsage: true,
 	}
 
-	cfg.InitConfig(cfgpath)
 	RootCmd.AddCommand(AVAXWalletCmd)
 	RootCmd.AddCommand(CallRPCCmd)
+	RootCmd.AddCommand(CertsCmd)
+	RootCmd.AddCommand(ChaosCmd)
 	RootCmd.AddCommand(ExitCmd)
 	RootCmd.AddCommand(NetworkCommand)
 	RootCmd.AddCommand(ProcmanagerCmd)
+	RootCmd.AddCommand(ProfileCmd)
 	RootCmd.AddCommand(RunScriptCmd)
 	RootCmd.AddCommand(SetOutputCmd)
 	RootCmd.AddCommand(StartnodeCmd)
@@ -142,6 +177,7 @@ func init() {
 
 // Execute runs the root command for avash
 func Execute() {
+	cfg.InitConfig(cfgPath)
 	if err := RootCmd.Execute(); err != nil {
 		os.Exit(1)
 	}
@@ -0,0 +1,146 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ava-labs/avash/cfg"
+	pmgr "github.com/ava-labs/avash/processmgr"
+	"github.com/ava-labs/avash/utils/logging"
+)
+
+// Configures avash for the test with a temporary data directory, returning a
+// function that removes the processes started by the test, waiting for them to
+// exit, and the directory
+func withShell(t *testing.T) func() {
+	dir, err := ioutil.TempDir("", "avash-cmd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	level, _ := logging.ToLevel("off")
+	log, err := logging.New(logging.Config{
+		RotationInterval: 24 * time.Hour,
+		FileSize:         1 << 23,
+		RotationSize:     7,
+		FlushSize:        1,
+		DisplayLevel:     level,
+		LogLevel:         level,
+		Directory:        filepath.Join(dir, "logs"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Config = cfg.Configuration{
+		AvalancheLocation: "true",
+		DataDir:           dir,
+		PortRange:         cfg.DefaultPortRange,
+		Log:               *log,
+	}
+	return func() {
+		names, _ := pmgr.ProcManager.Select("", "")
+		for _, name := range names {
+			pmgr.ProcManager.KillProcess(name)
+			pmgr.ProcManager.RemoveProcess(name)
+		}
+		for _, j := range pmgr.ProcManager.Jobs() {
+			pmgr.ProcManager.CancelJob(j.ID)
+		}
+		// Removed processes have exited, so only their crash artifacts remain to be saved
+		pmgr.ProcManager.WaitArtifacts()
+		log.Stop()
+		os.RemoveAll(dir)
+	}
+}
+
+// Adds a `sleep` process with the labels, starting it if `start`
+func addSleep(t *testing.T, name string, start bool, labels ...string) {
+	if err := pmgr.ProcManager.AddProcess("sleep", "test", []string{"60"}, name, "{}", nil, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := pmgr.ParseLabels(labels)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := pmgr.ProcManager.SetLabels(name, parsed); err != nil {
+		t.Fatal(err)
+	}
+	if start {
+		if err := pmgr.ProcManager.StartProcess(name); err != nil {
+			t.Fatal(err)
+		}
+		waitStatus(t, name, pmgr.StateRunning.String())
+	}
+}
+
+// Returns the status of the process listed by the process manager
+func processStatus(t *testing.T, name string) string {
+	infos := pmgr.ProcManager.Processes(false, name)
+	if len(infos) != 1 {
+		t.Fatalf("process %s not found", name)
+	}
+	return infos[0].Status
+}
+
+// Waits for the process to reach the status
+func waitStatus(t *testing.T, name string, status string) {
+	for i := 0; processStatus(t, name) != status; i++ {
+		if i == 500 {
+			t.Fatalf("process %s is %s expected %s", name, processStatus(t, name), status)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// Runs the command line in the shell, returning its result if it wrote one
+func execute(t *testing.T, ln string) (result, bool) {
+	var out bytes.Buffer
+	resultWriter = &out
+	defer func() { resultWriter = nil }()
+	AvalancheShell.execute(ln)
+	if out.Len() == 0 {
+		return result{}, false
+	}
+	var res result
+	if err := json.Unmarshal(out.Bytes(), &res); err != nil {
+		t.Fatalf("%s wrote an invalid result: %s\n%s", ln, err.Error(), out.String())
+	}
+	return res, true
+}
+
+// Returns the names of the processes of a `procmanager list` result
+func listedNames(t *testing.T, res result) []string {
+	infos, ok := res.Data.([]interface{})
+	if !ok {
+		t.Fatalf("%s returned %v expected a list of processes", res.Command, res.Data)
+	}
+	names := []string{}
+	for _, info := range infos {
+		names = append(names, info.(map[string]interface{})["name"].(string))
+	}
+	return names
+}
+
+func TestExecute(t *testing.T) {
+	defer withShell(t)()
+	addSleep(t, "e1", false)
+
+	res, ok := execute(t, "procmanager list e1 --output json")
+	if !ok || res.Status != statusOK {
+		t.Fatalf("list returned %v expected an ok result", res)
+	} else if names := listedNames(t, res); len(names) != 1 || names[0] != "e1" {
+		t.Fatalf("list returned %v expected [e1]", names)
+	}
+	if outputFormat != outputText {
+		t.Fatalf("execute left the output format %s expected %s", outputFormat, outputText)
+	}
+	if _, ok := execute(t, "procmanager list e1 --output xml"); ok {
+		t.Fatalf("list wrote a result for an invalid output format")
+	} else if outputFormat != outputText {
+		t.Fatalf("execute left the output format %s expected %s", outputFormat, outputText)
+	}
+}
@@ -6,6 +6,7 @@ package cmd
 
 import (
 	//"encoding/json"
+	"fmt"
 	"io"
 	"os"
 	"strings"
@@ -16,6 +17,7 @@ import (
 	"go.uber.org/multierr"
 
 	"github.com/ava-labs/avash/cfg"
+	pmgr "github.com/ava-labs/avash/processmgr"
 	"github.com/spf13/cobra"
 	lua "github.com/yuin/gopher-lua"
 )
@@ -42,16 +44,28 @@ var RunScriptCmd = &cobra.Command{
 			//L.SetContext(ctx)
 			//defer cancel()
 
+			events := newScriptEvents()
+			defer events.Close()
+
 			/* set new Lua functions here */
-			L.SetGlobal("avash_call", L.NewFunction(AvashCall))
-			L.SetGlobal("avash_sleepmicro", L.NewFunction(AvashSleepMicro))
+			L.SetGlobal("avash_call", L.NewFunction(events.wrap(AvashCall)))
+			L.SetGlobal("avash_sleepmicro", L.NewFunction(events.wrap(AvashSleepMicro)))
 			L.SetGlobal("avash_setvar", L.NewFunction(AvashSetVar))
 			//L.SetGlobal("avash_coroutine", L.NewFunction(AvashCoroutine))
+			avash := L.NewTable()
+			L.SetField(avash, "on_event", L.NewFunction(events.OnEvent))
+			L.SetField(avash, "node_info", L.NewFunction(events.wrap(AvashNodeInfo)))
+			L.SetGlobal("avash", avash)
 
 			filename := args[0]
 			log.Info("RunScript: Running " + filename)
 
-			if err := L.DoFile(filename); err != nil {
+			err := L.DoFile(filename)
+			if err == nil {
+				// Deliver events raised by the script's last commands
+				err = events.Dispatch(L)
+			}
+			if err != nil {
 				log.Error("RunScript: Failed to run " + filename + "\n" + err.Error())
 			} else {
 				log.Info("RunScript: Successfully ran " + filename)
@@ -62,6 +76,102 @@ var RunScriptCmd = &cobra.Command{
 	},
 }
 
+// scriptEvents delivers process lifecycle events to the Lua handlers registered
+// with `avash.on_event`. Lua states are not goroutine safe, so handlers only run
+// on the script's goroutine: after every `avash_call` and `avash_sleepmicro`, and
+// once more when the script finishes.
+type scriptEvents struct {
+	sub      *pmgr.Subscription
+	handlers map[string][]*lua.LFunction
+}
+
+func newScriptEvents() *scriptEvents {
+	return &scriptEvents{
+		sub:      pmgr.ProcManager.SubscribeEvents(),
+		handlers: make(map[string][]*lua.LFunction),
+	}
+}
+
+// Close stops receiving events
+func (se *scriptEvents) Close() {
+	se.sub.Close()
+}
+
+// OnEvent registers a handler for an event kind, or for every event with "*".
+// Lua usage: avash.on_event("failed", function(e) ... end)
+func (se *scriptEvents) OnEvent(L *lua.LState) int {
+	kind := L.CheckString(1)
+	fn := L.CheckFunction(2)
+	if kind != "*" {
+		if _, err := pmgr.ToEventKind(kind); err != nil {
+			L.ArgError(1, err.Error())
+			return 0
+		}
+	}
+	se.handlers[kind] = append(se.handlers[kind], fn)
+	return 0
+}
+
+// Dispatch runs the registered handlers for every event received so far,
+// returning the first handler error. Events dropped because the script fell
+// behind are an error once handlers are registered, as they would go unhandled.
+func (se *scriptEvents) Dispatch(L *lua.LState) error {
+	for {
+		select {
+		case e := <-se.sub.Events:
+			if err := se.handle(L, e); err != nil {
+				return err
+			}
+		default:
+			if dropped := se.sub.Dropped(); dropped > 0 && len(se.handlers) > 0 {
+				return fmt.Errorf("%d events were dropped before their handlers ran", dropped)
+			}
+			return nil
+		}
+	}
+}
+
+func (se *scriptEvents) handle(L *lua.LState, e pmgr.Event) error {
+	var handlers []*lua.LFunction
+	handlers = append(handlers, se.handlers[e.Kind.String()]...)
+	handlers = append(handlers, se.handlers["*"]...)
+	if len(handlers) == 0 {
+		return nil
+	}
+	event := L.NewTable()
+	event.RawSetString("kind", lua.LString(e.Kind.String()))
+	event.RawSetString("name", lua.LString(e.Name))
+	event.RawSetString("time", lua.LString(e.Time.Format(time.RFC3339)))
+	event.RawSetString("exit_code", lua.LNumber(e.ExitCode))
+	if e.Signal != "" {
+		event.RawSetString("signal", lua.LString(e.Signal))
+	}
+	if e.Reason != "" {
+		event.RawSetString("reason", lua.LString(e.Reason))
+	}
+	if e.Err != nil {
+		event.RawSetString("error", lua.LString(e.Err.Error()))
+	}
+	for _, fn := range handlers {
+		if err := L.CallByParam(lua.P{Fn: fn, NRet: 0, Protect: true}, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// wrap returns `fn` followed by a dispatch of pending events. A failing
+// handler raises an error in the script.
+func (se *scriptEvents) wrap(fn lua.LGFunction) lua.LGFunction {
+	return func(L *lua.LState) int {
+		n := fn(L)
+		if err := se.Dispatch(L); err != nil {
+			L.RaiseError("event handler failed: %s", err.Error())
+		}
+		return n
+	}
+}
+
 func capture() func() (string, error) {
 	re, we, err := os.Pipe()
 	if err != nil {
@@ -139,10 +249,19 @@ func AvashCall(L *lua.LState) int { /* returns number of results */
 		AvalancheShell.rl.Terminal.Write([]byte(err.Error()))
 	}
 	AvalancheShell.addHistory(cmd, flags)
-	cmd.ParseFlags(flags)
+	// A structured result is returned in place of the command's log output
+	var doc strings.Builder
+	resultWriter = &doc
 	captureDone := capture()
-	cmd.Run(cmd, flags)
+	if err := runCommand(cmd, flags); err != nil {
+		cfg.Config.Log.Error(err.Error())
+	}
 	capturedOutout, err := captureDone()
+	resultWriter = nil
+	resetOutput()
+	if doc.Len() > 0 {
+		capturedOutout = doc.String()
+	}
 	log := cfg.Config.Log
 	if err != nil {
 		L.Push(lua.LString("Error: Unable to execute in capture: " + err.Error()))
@@ -0,0 +1,147 @@
+/*
+Copyright © 2019 AVA Labs <collin@avalabs.org>
+*/
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/ava-labs/avash/cfg"
+	pmgr "github.com/ava-labs/avash/processmgr"
+	"github.com/spf13/cobra"
+)
+
+const selectorUsage = "Label selector to target processes by, e.g. role=validator,zone!=a"
+
+var (
+	errNoTargets   = errors.New("Process name or selector required")
+	errMissingArgs = errors.New("Missing args")
+)
+
+// addSelectorFlag adds the `--selector` (`-l`) flag to the command
+func addSelectorFlag(cmd *cobra.Command) {
+	cmd.Flags().StringP("selector", "l", "", selectorUsage)
+}
+
+// takeSelector returns the command's `--selector` value, resetting it for the next call
+func takeSelector(cmd *cobra.Command) string {
+	f := cmd.Flags().Lookup("selector")
+	if f == nil {
+		return ""
+	}
+	selector := f.Value.String()
+	f.Value.Set(f.DefValue)
+	f.Changed = false
+	return selector
+}
+
+// selectTargets resolves the processes targeted by a command, returning their
+// names and the remaining args. The first arg is a process name or glob unless
+// a selector is given, in which case it is optional and may not be an integer.
+func selectTargets(args []string, selector string) ([]string, []string, error) {
+	pattern := ""
+	if len(args) >= 1 && args[0] != "" {
+		if _, err := strconv.ParseInt(args[0], 10, 64); selector == "" || err != nil {
+			pattern, args = args[0], args[1:]
+		}
+	}
+	if pattern == "" && selector == "" {
+		return nil, args, errNoTargets
+	}
+	names, err := pmgr.ProcManager.Select(pattern, selector)
+	if err != nil {
+		return nil, args, err
+	}
+	if len(names) == 0 {
+		return nil, args, fmt.Errorf("No processes match")
+	}
+	return names, args, nil
+}
+
+// selectNodes resolves the nodes targeted by a command taking `nargs` args after
+// the node name, returning their names and the remaining args. With a selector
+// the node name is omitted.
+func selectNodes(args []string, selector string, nargs int) ([]string, []string, error) {
+	if selector != "" {
+		if len(args) < nargs {
+			return nil, args, errMissingArgs
+		}
+		names, _, err := selectTargets(nil, selector)
+		return names, args, err
+	}
+	if len(args) < nargs+1 {
+		return nil, args, errMissingArgs
+	}
+	names, _, err := selectTargets(args[:1], "")
+	return names, args[1:], err
+}
+
+// commandTargets resolves the processes targeted by `cmd` like selectTargets,
+// printing its help or logging the error and returning false if there are none
+func commandTargets(cmd *cobra.Command, args []string) ([]string, []string, bool) {
+	names, args, err := selectTargets(args, takeSelector(cmd))
+	if err == errNoTargets {
+		cmd.Help()
+		return nil, args, false
+	} else if err != nil {
+		cfg.Config.Log.Error(err.Error())
+		return nil, args, false
+	}
+	return names, args, true
+}
+
+// commandNodes resolves the nodes targeted by `cmd` like selectNodes,
+// printing its help or reporting the error and returning false if there are none
+func commandNodes(cmd *cobra.Command, args []string, nargs int) ([]string, []string, bool) {
+	names, args, err := selectNodes(args, takeSelector(cmd), nargs)
+	if err == errMissingArgs {
+		cmd.Help()
+		return nil, args, false
+	} else if err != nil {
+		cfg.Config.Log.Error(err.Error())
+		emit(cmd, nil, err)
+		return nil, args, false
+	}
+	return names, args, true
+}
+
+// nodePrefix returns a prefix naming the node for output when several nodes are targeted
+func nodePrefix(names []string, name string) string {
+	if len(names) < 2 {
+		return ""
+	}
+	return name + ": "
+}
+
+// listTargets resolves the processes matching the names or globs in `args` and the
+// selector of `cmd`, returning nil if neither is given to select every process.
+// Reports and returns false if no process matches.
+func listTargets(cmd *cobra.Command, args []string) ([]string, bool) {
+	selector := takeSelector(cmd)
+	if len(args) == 0 && selector == "" {
+		return nil, true
+	}
+	patterns := args
+	if len(patterns) == 0 {
+		patterns = []string{""}
+	}
+	var names []string
+	for _, pattern := range patterns {
+		matched, err := pmgr.ProcManager.Select(pattern, selector)
+		if err != nil {
+			cfg.Config.Log.Error(err.Error())
+			emit(cmd, nil, err)
+			return nil, false
+		}
+		names = append(names, matched...)
+	}
+	if len(names) == 0 {
+		cfg.Config.Log.Info("No processes match")
+		emit(cmd, []interface{}{}, nil)
+		return nil, false
+	}
+	return names, true
+}
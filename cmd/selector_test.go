@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	pmgr "github.com/ava-labs/avash/processmgr"
+)
+
+func TestSelectorTargets(t *testing.T) {
+	defer withShell(t)()
+	addSleep(t, "v1", true, "role=validator")
+	addSleep(t, "v2", true, "role=validator")
+	addSleep(t, "a1", true, "role=api")
+
+	res, _ := execute(t, "procmanager list -l role=validator --output json")
+	if names := listedNames(t, res); !reflect.DeepEqual(names, []string{"v1", "v2"}) {
+		t.Fatalf("list -l returned %v expected [v1 v2]", names)
+	}
+
+	execute(t, "procmanager stop -l role=validator")
+	for _, name := range []string{"v1", "v2"} {
+		if status := processStatus(t, name); status != pmgr.StateStopped.String() {
+			t.Fatalf("stop -l left %s %s expected %s", name, status, pmgr.StateStopped)
+		}
+	}
+	if status := processStatus(t, "a1"); status != pmgr.StateRunning.String() {
+		t.Fatalf("stop -l left %s %s expected %s", "a1", status, pmgr.StateRunning)
+	}
+
+	execute(t, "procmanager start v*")
+	waitStatus(t, "v1", pmgr.StateRunning.String())
+	waitStatus(t, "v2", pmgr.StateRunning.String())
+	execute(t, "procmanager kill --selector role=validator")
+	for _, name := range []string{"v1", "v2"} {
+		if status := processStatus(t, name); status != pmgr.StateStopped.String() {
+			t.Fatalf("kill --selector left %s %s expected %s", name, status, pmgr.StateStopped)
+		}
+	}
+}
+
+func TestSelectorNodes(t *testing.T) {
+	defer withShell(t)()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"jsonrpc":"2.0","id":0,"result":{"nodeID":"NodeID-1"}}`)
+	}))
+	defer server.Close()
+	host, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+	meta := fmt.Sprintf(`{"public-ip":%q,"http-port":%q}`, host, port)
+	if err := pmgr.ProcManager.AddProcess("sleep", "test", []string{"60"}, "api1", meta, nil, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	labels, _ := pmgr.ParseLabels([]string{"role=api"})
+	pmgr.ProcManager.SetLabels("api1", labels)
+
+	execute(t, "varstore create s")
+	for _, ln := range []string{
+		"callrpc -l role=api ext/info info.getNodeID {} s v1",
+		"callrpc ext/info info.getNodeID {} s v2 --selector role=api",
+		"callrpc api1 ext/info info.getNodeID {} s v3",
+	} {
+		res, _ := execute(t, ln+" --output json")
+		if res.Status != statusOK {
+			t.Fatalf("%s returned %s: %v", ln, res.Status, res.Data)
+		}
+	}
+	store, err := AvashVars.Get("s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range []string{"v1", "v2", "v3"} {
+		if value, err := store.Get(v); err != nil || value != `{"nodeID":"NodeID-1"}` {
+			t.Fatalf("callrpc saved %s = %s, %v expected the response", v, value, err)
+		}
+	}
+}
@@ -6,7 +6,9 @@ package cmd
 
 import (
 	"encoding/json"
-	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/kennygrant/sanitize"
@@ -15,18 +17,62 @@ import (
 	"github.com/ava-labs/avash/node"
 	pmgr "github.com/ava-labs/avash/processmgr"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
 var flags node.Flags
 
+var startnodePolicy = defaultPolicyFlags()
+
+var startnodeLabels []string
+
+var startnodeLimits limitFlags
+
+var (
+	startnodeEnv     []string
+	startnodeWorkDir string
+)
+
+var (
+	startnodeAutoPorts bool
+	startnodePortRange string
+)
+
+var (
+	startnodeConfigMode   = node.ConfigModeArgs
+	startnodeImportConfig string
+)
+
+var startnodeProfile string
+
+// stakingCertAuto allocates an unused generated staking certificate to the node,
+// from the default directory of `certs generate` or from the one following a colon
+const stakingCertAuto = "auto"
+
+var startnodeStakingCert string
+
+var (
+	startnodeWait        bool
+	startnodeWaitUntil   = node.Bootstrapped.String()
+	startnodeWaitTimeout = DefaultWaitTimeout
+)
+
 // StartnodeCmd represents the startnode command
 var StartnodeCmd = &cobra.Command{
-	Use:   "startnode [node name] args...",
+	Use:   "startnode [node name] args... [-- client args...]",
 	Short: "Starts a node process and gives it a name.",
 	Long: `Starts an Avalanche client node using pmgo and gives it a name. Example:
-	startnode MyNode1 --public-ip=127.0.0.1 --staking-port=9651 --http-port=9650 ... `,
+	startnode MyNode1 --public-ip=127.0.0.1 --staking-port=9651 --http-port=9650 ...
+Arguments after -- are passed to the client verbatim, without validation, for
+client flags unknown to avash. Example:
+	startnode MyNode1 --http-port=9650 -- --some-new-flag=true
+With --profile, the flags of a profile of the config file apply, overridden by
+those given on the command line. Example:
+	startnode MyNode1 --profile staker --http-port=9652`,
 	Run: func(cmd *cobra.Command, args []string) {
-		if len(args) < 1 {
+		changed := takeChangedFlags(cmd)
+		extra := extraArgs(cmd)
+		if len(args)-len(extra) < 1 {
 			cmd.Help()
 			return
 		}
@@ -41,179 +87,384 @@ var StartnodeCmd = &cobra.Command{
 			return
 		}
 
-		err := validateConsensusArgs(
-			flags.SnowSampleSize,
-			flags.SnowQuorumSize,
-			flags.SnowVirtuousCommitThreshold,
-			flags.SnowRogueCommitThreshold,
-		)
-		if err != nil {
+		policy, err := startnodePolicy.policy()
+		labels, lerr := pmgr.ParseLabels(startnodeLabels)
+		limits, rerr := startnodeLimits.limits()
+		wait, waitTimeout := startnodeWait, startnodeWaitTimeout
+		waitUntil, werr := node.ToReadiness(startnodeWaitUntil)
+		env, eerr := pmgr.ParseEnv(startnodeEnv)
+		workdir, derr := workDir(startnodeWorkDir)
+		// Set flags to default for next `startnode` call
+		startnodePolicy = defaultPolicyFlags()
+		startnodeLabels = nil
+		startnodeLimits = limitFlags{}
+		startnodeWait, startnodeWaitUntil, startnodeWaitTimeout = false, node.Bootstrapped.String(), DefaultWaitTimeout
+		startnodeEnv, startnodeWorkDir = nil, ""
+		autoPorts, portRange := startnodeAutoPorts, startnodePortRange
+		startnodeAutoPorts, startnodePortRange = false, ""
+		configMode, importConfig := startnodeConfigMode, startnodeImportConfig
+		startnodeConfigMode, startnodeImportConfig = node.ConfigModeArgs, ""
+		profile := startnodeProfile
+		startnodeProfile = ""
+		stakingCert := startnodeStakingCert
+		startnodeStakingCert = ""
+		var profileFlags node.FlagsYAML
+		var perr error
+		if profile != "" {
+			profileFlags, perr = node.ResolveProfile(cfg.Config.Profiles, profile)
+		}
+		merr := node.ValidateConfigMode(configMode)
+		if merr == nil && configMode == node.ConfigModeFile && flags.ConfigFile != "" {
+			merr = fmt.Errorf("--config-file cannot be combined with --config-mode=%s, import it with --import-config instead", configMode)
+		}
+		for _, e := range []error{err, lerr, rerr, werr, eerr, derr, merr, perr} {
+			if e != nil {
+				log.Error(e.Error())
+				flags = node.DefaultFlags()
+				return
+			}
+		}
+		if profile != "" {
+			// Flags given on the command line override those of the profile
+			profiled := node.ConvertYAML(node.OverrideFlags(profileFlags, node.PickFlags(flags, changed)))
+			profiled.ClientLocation, profiled.Meta, profiled.DataDir = flags.ClientLocation, flags.Meta, flags.DataDir
+			flags = profiled
+		}
+		var imported node.FlagsYAML
+		if importConfig != "" {
+			// Flags given on the command line or by the profile take precedence over the imported ones
+			var err error
+			imported, err = node.ImportConfig(importConfig)
+			if err != nil {
+				log.Error(err.Error())
+				flags = node.DefaultFlags()
+				return
+			}
+			keep := changed
+			for name := range profileFlags {
+				keep = append(keep, name)
+			}
+			node.FillFlags(&flags, imported, keep)
+		}
+		if stakingCert != "" {
+			if err := assignStakingCert(name, stakingCert); err != nil {
+				log.Error(err.Error())
+				flags = node.DefaultFlags()
+				return
+			}
+		}
+		if limits.NoFile > 0 {
+			// The node raises its own descriptor limit to --fd-limit, which must fit under
+			// the cap applied before it runs, so it is the cap unless given
+			if !flagGiven("fd-limit", changed, profileFlags, imported) {
+				flags.FDLimit = int(limits.NoFile)
+			} else if uint64(flags.FDLimit) > limits.NoFile {
+				log.Error("--fd-limit=%d exceeds --limit-nofile=%d", flags.FDLimit, limits.NoFile)
+				flags = node.DefaultFlags()
+				return
+			}
+		}
+
+		// Allocated ports are free, so only given ones are checked against other nodes
+		reserved := reservedPorts(name)
+		validation := node.Validation{WorkDir: workdir}
+		if !autoPorts {
+			validation.Reserved = reserved
+		}
+		if err := node.ValidateFlags(flags, validation); err != nil {
+			log.Error(err.Error())
+			flags = node.DefaultFlags()
+			return
+		}
+
+		if err := assignPorts(name, autoPorts, portRange, reserved, changed); err != nil {
 			log.Error(err.Error())
+			flags = node.DefaultFlags()
 			return
 		}
 
 		args, md := node.FlagsToArgs(flags, sanitize.Path(datapath), false)
+		md.ReservedPorts = []uint{flags.HTTPPort, flags.StakingPort}
+		meta, avalancheLocation := flags.Meta, flags.ClientLocation
 		// Set flags to default for next `startnode` call
 		flags = node.DefaultFlags()
+		if avalancheLocation == "" {
+			avalancheLocation = cfg.Config.AvalancheLocation
+		}
+		// Flags are adapted to the client version, or all passed if it is unknown
+		version, err := node.ProbeClientVersion(avalancheLocation, workdir, env)
+		if err != nil {
+			log.Warn("%s, passing every flag", err.Error())
+		} else {
+			var omitted []string
+			args, omitted = node.CompatArgs(args, version)
+			if len(omitted) > 0 {
+				log.Info("Omitted flags unsupported by client version %s: %s", version, strings.Join(omitted, ", "))
+			}
+			md.ClientVersion = version.String()
+		}
+		// Without a staking certificate, the NodeID is only known once the node runs
+		if md.StakerCertPath != "" {
+			if id, err := node.ReadCertNodeID(md.StakerCertPath); err != nil {
+				log.Warn("Unable to derive the NodeID of %s: %s", name, err.Error())
+			} else {
+				md.NodeID = id
+			}
+		}
+		if configMode == node.ConfigModeFile {
+			config, rest := node.ConfigFromArgs(args)
+			path, err := writeNodeConfig(sanitize.Path(datapath), config)
+			if err != nil {
+				log.Error("Unable to write the config file of %s: %s", name, err.Error())
+				return
+			}
+			args = append(rest, "--config-file="+path)
+			md.ConfigFile = path
+		}
+		if len(extra) > 0 {
+			for _, dup := range node.DuplicateArgs(args, extra) {
+				log.Warn("Client argument --%s duplicates a flag set by avash, so is passed to the node twice", dup)
+			}
+			args = append(args, extra...)
+			md.ExtraArgs = extra
+		}
+		if !limits.IsZero() {
+			md.Limits = limits.Map()
+		}
+		md.Env, md.WorkDir = env, workdir
 		mdbytes, _ := json.MarshalIndent(md, " ", "    ")
 		metadata := string(mdbytes)
-		meta := flags.Meta
 		if meta != "" {
 			metadata = meta
 		}
-		avalancheLocation := flags.ClientLocation
-		if avalancheLocation == "" {
-			avalancheLocation = cfg.Config.AvalancheLocation
-		}
 		err = pmgr.ProcManager.AddProcess(avalancheLocation, "avalanche node", args, name, metadata, nil, nil, nil)
 		if err != nil {
 			log.Error(err.Error())
 			return
 		}
+		if err := pmgr.ProcManager.SetMetadataGenerated(name, meta == ""); err != nil {
+			log.Error(err.Error())
+		}
+		if err := pmgr.ProcManager.SetPolicy(name, policy); err != nil {
+			log.Error(err.Error())
+		}
+		if md.ClientVersion != "" {
+			if err := pmgr.ProcManager.SetVersion(name, md.ClientVersion); err != nil {
+				log.Error(err.Error())
+			}
+		}
+		if err := pmgr.ProcManager.SetLabels(name, labels); err != nil {
+			log.Error(err.Error())
+		}
+		if err := pmgr.ProcManager.SetLimits(name, limits); err != nil {
+			log.Error(err.Error())
+		}
+		if err := pmgr.ProcManager.SetEnvironment(name, env, workdir); err != nil {
+			log.Error(err.Error())
+		}
+		// The node logs are collected into crash artifact bundles
+		logsdir := md.Logsdir
+		if !filepath.IsAbs(logsdir) && workdir != "" {
+			logsdir = filepath.Join(workdir, logsdir)
+		}
+		if err := pmgr.ProcManager.SetArtifactPaths(name, []string{logsdir}); err != nil {
+			log.Error(err.Error())
+		}
+		if meta == "" {
+			recordNodeVars(name, md)
+		}
 		log.Info("Created process %s.", name)
 		pmgr.ProcManager.StartProcess(name)
+		if wait && waitNodes(cmd, []string{name}, waitUntil, waitTimeout) && meta == "" && md.NodeID == "" {
+			if _, err := nodeIdentity(name); err != nil {
+				log.Warn(err.Error())
+			}
+		}
 	},
 }
 
-func validateConsensusArgs(k int, alpha int, beta1 int, beta2 int) error {
-	rulesfailed := []string(nil)
-	if k <= 0 {
-		rulesfailed = append(rulesfailed, "k > 0")
+// writeNodeConfig writes the config into the config file of the stash directory
+// of the node, returning its absolute path
+func writeNodeConfig(stash string, config map[string]interface{}) (string, error) {
+	if err := os.MkdirAll(stash, os.ModePerm); err != nil {
+		return "", err
+	}
+	path, err := filepath.Abs(filepath.Join(stash, node.ConfigFileName))
+	if err != nil {
+		return "", err
+	}
+	return path, node.WriteConfigFile(path, config)
+}
+
+// takeChangedFlags returns the names of the flags given to the command, resetting
+// them for the next call
+func takeChangedFlags(cmd *cobra.Command) []string {
+	var names []string
+	cmd.Flags().VisitAll(func(f *pflag.Flag) {
+		if f.Changed {
+			names = append(names, f.Name)
+			f.Changed = false
+		}
+	})
+	return names
+}
+
+// flagGiven returns true if the flag at the name is among the `changed` flags or
+// set by one of the flag values
+func flagGiven(name string, changed []string, values ...node.FlagsYAML) bool {
+	for _, n := range changed {
+		if n == name {
+			return true
+		}
+	}
+	for _, v := range values {
+		if _, ok := v[name]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// extraArgs returns the arguments of `cmd` following `--`, passed through to the
+// client verbatim
+func extraArgs(cmd *cobra.Command) []string {
+	if dash := cmd.ArgsLenAtDash(); dash >= 0 {
+		return cmd.Flags().Args()[dash:]
+	}
+	return nil
+}
+
+// assignPorts allocates the HTTP and staking ports of the node from the port
+// range if `auto`, or else checks that its ports are free. Ports are free if no
+// other managed node reserves them and no listener holds them. `changed` are the
+// flags given on the command line.
+func assignPorts(name string, auto bool, portRange string, reserved map[uint]string, changed []string) error {
+	if !auto {
+		return node.CheckPorts([]uint{flags.HTTPPort, flags.StakingPort}, reserved)
+	}
+	// Ports given on the command line conflict, while those of a profile or an
+	// imported config are replaced, as --auto-ports takes precedence over them
+	if flagGiven("http-port", changed) || flagGiven("staking-port", changed) {
+		return fmt.Errorf("--auto-ports cannot be combined with --http-port or --staking-port")
 	}
-	if alpha > k {
-		rulesfailed = append(rulesfailed, "alpha <= k")
+	if portRange == "" {
+		portRange = cfg.Config.PortRange
 	}
-	if (k / 2) >= alpha {
-		rulesfailed = append(rulesfailed, "alpha > floor(k/2)")
+	r, err := node.ParsePortRange(portRange)
+	if err != nil {
+		return err
 	}
-	if beta1 <= 0 {
-		rulesfailed = append(rulesfailed, "beta1 > 0")
+	httpPort, stakingPort, err := node.AllocatePorts(r, reserved)
+	if err != nil {
+		return err
 	}
-	if beta1 > beta2 {
-		rulesfailed = append(rulesfailed, "beta2 >= beta1")
+	flags.HTTPPort, flags.StakingPort = httpPort, stakingPort
+	cfg.Config.Log.Info("Allocated ports for %s: HTTP %d, staking %d", name, httpPort, stakingPort)
+	return nil
+}
+
+// assignStakingCert sets the staking certificate and key files of the node to
+// those of the directory `cert`, or if "auto" to an unused pair generated by
+// `certs generate`, or if "auto:DIR" by `certs generate --out DIR`. Generated
+// pairs are unused unless another managed node stakes with them.
+func assignStakingCert(name string, cert string) error {
+	if flags.StakingTLSCertFile != "" || flags.StakingTLSKeyFile != "" {
+		return fmt.Errorf("--staking-cert cannot be combined with --staking-tls-cert-file or --staking-tls-key-file")
 	}
-	if len(rulesfailed) == 0 {
+	// The directory is resolved as by `certs generate --out`
+	dir := node.DefaultCertsDir
+	if strings.HasPrefix(cert, stakingCertAuto+":") {
+		dir = cert[len(stakingCertAuto)+1:]
+		if dir == "" {
+			return fmt.Errorf("--staking-cert %s: requires a directory", stakingCertAuto)
+		}
+	} else if cert != stakingCertAuto {
+		flags.StakingTLSCertFile = filepath.Join(cert, node.StakerCertFile)
+		flags.StakingTLSKeyFile = filepath.Join(cert, node.StakerKeyFile)
 		return nil
 	}
-	return errors.New("Invalid consensus params: \n" + strings.Join(rulesfailed, "\n"))
+	used := make(map[string]string)
+	for n, md := range otherNodes(name) {
+		if md.StakerCertPath != "" {
+			used[filepath.Clean(md.StakerCertPath)] = n
+		}
+	}
+	c, err := node.AllocateStakingCert(dir, used)
+	if err != nil {
+		return fmt.Errorf("%s, generate more with \"certs generate --out %s\"", err.Error(), dir)
+	}
+	flags.StakingTLSCertFile, flags.StakingTLSKeyFile = c.Paths(dir)
+	cfg.Config.Log.Info("Allocated staking certificate %s to %s: %s", c.Name, name, c.NodeID)
+	return nil
+}
+
+// reservedPorts returns the ports reserved by the managed nodes other than the
+// named one, mapped to the names of the nodes
+func reservedPorts(except string) map[uint]string {
+	reserved := make(map[uint]string)
+	for name, md := range otherNodes(except) {
+		for _, port := range md.Ports() {
+			reserved[port] = name
+		}
+	}
+	return reserved
+}
+
+// otherNodes returns the metadata of the managed nodes other than the named one,
+// by name. Processes without node metadata are omitted.
+func otherNodes(except string) map[string]node.Metadata {
+	nodes := make(map[string]node.Metadata)
+	names, _ := pmgr.ProcManager.Select("", "")
+	for _, name := range names {
+		if name == except {
+			continue
+		}
+		meta, err := pmgr.ProcManager.Metadata(name)
+		if err != nil {
+			continue
+		}
+		var md node.Metadata
+		if err := json.Unmarshal([]byte(meta), &md); err != nil {
+			continue
+		}
+		nodes[name] = md
+	}
+	return nodes
+}
+
+// workDir resolves the working directory given to `startnode`, so that it does not
+// depend on the working directory of avash when the node restarts
+func workDir(dir string) (string, error) {
+	if dir == "" {
+		return "", nil
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	if info, err := os.Stat(abs); err != nil {
+		return "", fmt.Errorf("Invalid working directory: %s", err.Error())
+	} else if !info.IsDir() {
+		return "", fmt.Errorf("Invalid working directory: %s is not a directory", abs)
+	}
+	return abs, nil
 }
 
 func init() {
 	flags = node.DefaultFlags()
-	StartnodeCmd.Flags().StringVar(&flags.ClientLocation, "client-location", flags.ClientLocation, "Path to AVA node client, defaulting to the config file's value.")
-	StartnodeCmd.Flags().StringVar(&flags.Meta, "meta", flags.Meta, "Override default metadata for the node process.")
-	StartnodeCmd.Flags().StringVar(&flags.DataDir, "data-dir", flags.DataDir, "Name of directory for the data stash.")
-
-	StartnodeCmd.Flags().BoolVar(&flags.AssertionsEnabled, "assertions-enabled", flags.AssertionsEnabled, "Turn on assertion execution.")
-	StartnodeCmd.Flags().BoolVar(&flags.Version, "version", flags.Version, "If this is `true`, print the version and quit. Defaults to `false`")
-	StartnodeCmd.Flags().UintVar(&flags.TxFee, "tx-fee", flags.TxFee, "Transaction fee, in $nAVAX.")
-
-	StartnodeCmd.Flags().StringVar(&flags.PluginDir, "plugin-dir", flags.PluginDir, "Directory to search for plugins")
-
-	StartnodeCmd.Flags().BoolVar(&flags.APIAdminEnabled, "api-admin-enabled", flags.APIAdminEnabled, "If true, this node exposes the Admin API")
-	StartnodeCmd.Flags().BoolVar(&flags.APIKeystoreEnabled, "api-keystore-enabled", flags.APIKeystoreEnabled, "If true, this node exposes the Keystore API")
-	StartnodeCmd.Flags().BoolVar(&flags.APIMetricsEnabled, "api-metrics-enabled", flags.APIMetricsEnabled, "If true, this node exposes the Metrics API")
-	StartnodeCmd.Flags().BoolVar(&flags.APIIPCsEnabled, "api-ipcs-enabled", flags.APIIPCsEnabled, "If true, IPCs can be opened")
-	StartnodeCmd.Flags().BoolVar(&flags.APIHealthEnabled, "api-health-enabled", flags.APIHealthEnabled, "If set to `true`, this node will expose the Health API. Defaults to `true`")
-	StartnodeCmd.Flags().BoolVar(&flags.APIInfoEnabled, "api-info-enabled", flags.APIInfoEnabled, "If set to `true`, this node will expose the Info API. Defaults to `true`")
-
-	StartnodeCmd.Flags().StringVar(&flags.PublicIP, "public-ip", flags.PublicIP, "Public IP of this node.")
-	StartnodeCmd.Flags().StringVar(&flags.DynamicUpdateDuration, "dynamic-update-duration", flags.DynamicUpdateDuration, "The time between poll events for `--dynamic-public-ip` or NAT traversal. The recommended minimum is 1 minute. Defaults to `5m`")
-	StartnodeCmd.Flags().StringVar(&flags.DynamicPublicIP, "dynamic-public-ip", flags.DynamicPublicIP, "Valid values if param is present: `opendns`, `ifconfigco` or `ifconfigme`. This overrides `--public-ip`. If set, will poll the remote service every `--dynamic-update-duration` and update the node’s public IP address.")
-	StartnodeCmd.Flags().StringVar(&flags.NetworkID, "network-id", flags.NetworkID, "Network ID this node will connect to.")
-	StartnodeCmd.Flags().BoolVar(&flags.SignatureVerificationEnabled, "signature-verification-enabled", flags.SignatureVerificationEnabled, "Turn on signature verification.")
-
-	StartnodeCmd.Flags().StringVar(&flags.HTTPHost, "http-host", flags.HTTPHost, "The address that HTTP APIs listen on.")
-	StartnodeCmd.Flags().UintVar(&flags.HTTPPort, "http-port", flags.HTTPPort, "Port of the HTTP server.")
-	StartnodeCmd.Flags().BoolVar(&flags.HTTPTLSEnabled, "http-tls-enabled", flags.HTTPTLSEnabled, "Upgrade the HTTP server to HTTPS.")
-	StartnodeCmd.Flags().StringVar(&flags.HTTPTLSCertFile, "http-tls-cert-file", flags.HTTPTLSCertFile, "TLS certificate file for the HTTPS server.")
-	StartnodeCmd.Flags().StringVar(&flags.HTTPTLSKeyFile, "http-tls-key-file", flags.HTTPTLSKeyFile, "TLS private key file for the HTTPS server.")
-
-	StartnodeCmd.Flags().StringVar(&flags.BootstrapIPs, "bootstrap-ips", flags.BootstrapIPs, "Comma separated list of bootstrap nodes to connect to. Example: 127.0.0.1:9630,127.0.0.1:9620")
-	StartnodeCmd.Flags().StringVar(&flags.BootstrapIDs, "bootstrap-ids", flags.BootstrapIDs, "Comma separated list of bootstrap peer ids to connect to. Example: NodeID-JR4dVmy6ffUGAKCBDkyCbeZbyHQBeDsET,NodeID-8CrVPQZ4VSqgL8zTdvL14G8HqAfrBr4z")
-
-	StartnodeCmd.Flags().BoolVar(&flags.DBEnabled, "db-enabled", flags.DBEnabled, "Turn on persistent storage.")
-	StartnodeCmd.Flags().StringVar(&flags.DBDir, "db-dir", flags.DBDir, "Database directory for Avalanche state.")
-
-	StartnodeCmd.Flags().StringVar(&flags.LogLevel, "log-level", flags.LogLevel, "Specify the log level. Should be one of {verbo, debug, info, warn, error, fatal, off}")
-	StartnodeCmd.Flags().StringVar(&flags.LogDir, "log-dir", flags.LogDir, "Name of directory for the node's logging.")
-	StartnodeCmd.Flags().StringVar(&flags.LogDisplayLevel, "log-display-level", flags.LogDisplayLevel, "{Off, Fatal, Error, Warn, Info, Debug, Verbo}. The log level determines which events to display to the screen. If left blank, will default to the value provided to `--log-level`")
-	StartnodeCmd.Flags().StringVar(&flags.LogDisplayHighlight, "log-display-highlight", flags.LogDisplayHighlight, "Whether to color/highlight display logs. Default highlights when the output is a terminal. Otherwise, should be one of {auto, plain, colors}")
-
-	StartnodeCmd.Flags().IntVar(&flags.SnowAvalancheBatchSize, "snow-avalanche-batch-size", flags.SnowAvalancheBatchSize, "Number of operations to batch in each new vertex.")
-	StartnodeCmd.Flags().IntVar(&flags.SnowAvalancheNumParents, "snow-avalanche-num-parents", flags.SnowAvalancheNumParents, "Number of vertexes for reference from each new vertex.")
-	StartnodeCmd.Flags().IntVar(&flags.SnowSampleSize, "snow-sample-size", flags.SnowSampleSize, "Number of nodes to query for each network poll.")
-	StartnodeCmd.Flags().IntVar(&flags.SnowQuorumSize, "snow-quorum-size", flags.SnowQuorumSize, "Alpha value to use for required number positive results.")
-	StartnodeCmd.Flags().IntVar(&flags.SnowVirtuousCommitThreshold, "snow-virtuous-commit-threshold", flags.SnowVirtuousCommitThreshold, "Beta value to use for virtuous transactions.")
-	StartnodeCmd.Flags().IntVar(&flags.SnowRogueCommitThreshold, "snow-rogue-commit-threshold", flags.SnowRogueCommitThreshold, "Beta value to use for rogue transactions.")
-	StartnodeCmd.Flags().IntVar(&flags.SnowEpochFirstTransition, "snow-epoch-first-transition", flags.SnowEpochFirstTransition, "Unix timestamp of the transition from epoch 0 to epoch 1. Defaults to 1609873200 which is 1/5/2021 @ 7:00pm (UTC)")
-	StartnodeCmd.Flags().StringVar(&flags.SnowEpochDuration, "snow-epoch-duration", flags.SnowEpochDuration, "Duration of each epoch. Defaults to `6h`")
-	StartnodeCmd.Flags().IntVar(&flags.SnowConcurrentRepolls, "snow-concurrent-repolls", flags.SnowConcurrentRepolls, "Snow consensus requires repolling transactions that are issued during low time of network usage. This parameter lets one define how aggressive the client will be in finalizing these pending transactions. This should only be changed after careful consideration of the tradeoffs of Snow consensus. The value must be at least `1` and at most `--snow-rogue-commit-threshold`. Defaults to `4`")
-	StartnodeCmd.Flags().IntVar(&flags.MinDelegatorStake, "min-delegator-stake", flags.MinDelegatorStake, "The minimum stake, in nAVAX, that can be delegated to a validator of the Primary Network. Defaults to `25000000000` (25 AVAX) on Main Net. Defaults to `5000000` (.005 AVAX) on Test Net.")
-	StartnodeCmd.Flags().StringVar(&flags.ConsensusShutdownTimeout, "consensus-shutdown-timeout", flags.ConsensusShutdownTimeout, "Timeout before killing an unresponsive chain. Defaults to `5s`")
-	StartnodeCmd.Flags().StringVar(&flags.ConsensusGossipFrequency, "consensus-gossip-frequency", flags.ConsensusGossipFrequency, "Time between gossiping accepted frontiers. Defaults to `10s`")
-	StartnodeCmd.Flags().IntVar(&flags.MinDelegationFee, "min-delegation-fee", flags.MinDelegationFee, "The minimum delegation fee that can be charged for delegation on the Primary Network, multiplied by `10,000` . Must be in the range `[0, 1000000]`. Defaults to `20000` (2%) on Main Net.")
-	StartnodeCmd.Flags().IntVar(&flags.MinValidatorStake, "min-validator-stake", flags.MinValidatorStake, "The minimum stake, in nAVAX, required to validate the Primary Network. Defaults to `2000000000000` (2,000 AVAX) on Main Net. Defaults to `5000000` (.005 AVAX) on Test Net.")
-	StartnodeCmd.Flags().StringVar(&flags.MaxStakeDuration, "max-stake-duration", flags.MaxStakeDuration, "The maximum staking duration, in seconds. Defaults to `8760h` (365 days) on Main Net.")
-	StartnodeCmd.Flags().IntVar(&flags.MaxValidatorStake, "max-validator-stake", flags.MaxValidatorStake, "The maximum stake, in nAVAX, that can be placed on a validator on the primary network. Defaults to `3000000000000000` (3,000,000 AVAX) on Main Net. This includes stake provided by both the validator and by delegators to the validator.")
-	StartnodeCmd.Flags().StringVar(&flags.StakeMintingPeriod, "stake-minting-period", flags.StakeMintingPeriod, "Consumption period of the staking function, in seconds. The Default on Main Net is `8760h` (365 days).")
-	StartnodeCmd.Flags().IntVar(&flags.CreationTxFee, "creation-tx-fee", flags.CreationTxFee, "Transaction fee, in nAVAX, for transactions that create new state. Defaults to `1000000` nAVAX (.001 AVAX) per transaction.")
-
-	StartnodeCmd.Flags().BoolVar(&flags.P2PTLSEnabled, "p2p-tls-enabled", flags.P2PTLSEnabled, "Require TLS to authenticate network communications")
-	StartnodeCmd.Flags().BoolVar(&flags.StakingEnabled, "staking-enabled", flags.StakingEnabled, "Enable staking. If enabled, Network TLS is required.")
-	StartnodeCmd.Flags().UintVar(&flags.StakingPort, "staking-port", flags.StakingPort, "Port of the consensus server.")
-	StartnodeCmd.Flags().IntVar(&flags.StakingDisabledWeight, "staking-disabled-weight", flags.StakingDisabledWeight, "Weight to provide to each peer when staking is disabled. Defaults to `1`")
-	StartnodeCmd.Flags().StringVar(&flags.StakingTLSCertFile, "staking-tls-cert-file", flags.StakingTLSCertFile, "TLS certificate file for staking connections. Relative to the avash binary if doesn't start with '/'. Ex: certs/keys1/staker.crt")
-	StartnodeCmd.Flags().StringVar(&flags.StakingTLSKeyFile, "staking-tls-key-file", flags.StakingTLSKeyFile, "TLS private key file for staking connections. Relative to the avash binary if doesn't start with '/'. Ex: certs/keys1/staker.key")
-
-	StartnodeCmd.Flags().BoolVar(&flags.APIAuthRequired, "api-auth-required", flags.APIAuthRequired, "If set to true, API calls require an authorization token. Defaults to `false`")
-	StartnodeCmd.Flags().StringVar(&flags.APIAuthPasswordFileKey, "api-auth-password-file", flags.APIAuthPasswordFileKey, "Password file used to initially create/validate API authorization tokens. Can be changed via API call.")
-	StartnodeCmd.Flags().StringVar(&flags.MinStakeDuration, "min-stake-duration", flags.MinStakeDuration, "Set the minimum staking duration. Ex: --min-stake-duration=5m")
-
-	StartnodeCmd.Flags().StringVar(&flags.WhitelistedSubnets, "whitelisted-subnets", flags.WhitelistedSubnets, "Comma separated list of subnets that this node would validate if added to. Defaults to empty (will only validate the Primary Network)")
-
-	StartnodeCmd.Flags().StringVar(&flags.ConfigFile, "config-file", flags.ConfigFile, "Config file specifies a JSON file to configure a node instead of specifying arguments via the command line. Command line arguments will override any options set in the config file.")
-
-	StartnodeCmd.Flags().IntVar(&flags.ConnMeterMaxConns, "conn-meter-max-conns", flags.ConnMeterMaxConns, "Upgrade at most `conn-meter-max-conns` connections from a given IP per `conn-meter-reset-duration`. If `conn-meter-reset-duration` is 0, incoming connections are not rate-limited.")
-	StartnodeCmd.Flags().StringVar(&flags.ConnMeterResetDuration, "conn-meter-reset-duration", flags.ConnMeterResetDuration, "Upgrade at most `conn-meter-max-conns` connections from a given IP per `conn-meter-reset-duration`. If `conn-meter-reset-duration` is 0, incoming connections are not rate-limited.")
-
-	StartnodeCmd.Flags().StringVar(&flags.IPCSChainIDs, "ipcs-chain-ids", flags.IPCSChainIDs, "Comma separated list of chain ids to connect to. There is no default value.")
-	StartnodeCmd.Flags().StringVar(&flags.IPCSPath, "ipcs-path", flags.IPCSPath, "The directory (Unix) or named pipe prefix (Windows) for IPC sockets. Defaults to /tmp.")
-
-	StartnodeCmd.Flags().IntVar(&flags.FDLimit, "fd-limit", flags.FDLimit, "Attempts to raise the process file descriptor limit to at least this value. Defaults to `32768`")
-
-	StartnodeCmd.Flags().StringVar(&flags.BenchlistDuration, "benchlist-duration", flags.BenchlistDuration, "Amount of time a peer is benchlisted after surpassing `--benchlist-fail-threshold`. Defaults to `1h`")
-	StartnodeCmd.Flags().IntVar(&flags.BenchlistFailThreshold, "benchlist-fail-threshold", flags.BenchlistFailThreshold, "Number of consecutive failed queries to a node before benching it (assuming all queries to it will fail). Defaults to `10`")
-	StartnodeCmd.Flags().StringVar(&flags.BenchlistMinFailingDuration, "benchlist-min-failing-duration", flags.BenchlistMinFailingDuration, "Minimum amount of time messages to a peer must be failing before the peer is benched. Defaults to `5m`")
-	StartnodeCmd.Flags().BoolVar(&flags.BenchlistPeerSummaryEnabled, "benchlist-peer-summary-enabled", flags.BenchlistPeerSummaryEnabled, "Enables peer specific query latency metrics. Defaults to `false`")
-
-	StartnodeCmd.Flags().IntVar(&flags.MaxNonStakerPendingMsgs, "max-non-staker-pending-msgs", flags.MaxNonStakerPendingMsgs, "Maximum number of messages a non-staker is allowed to have pending. Defaults to `20`")
-
-	StartnodeCmd.Flags().StringVar(&flags.NetworkInitialTimeout, "network-initial-timeout", flags.NetworkInitialTimeout, "Initial timeout value of the adaptive timeout manager, in nanoseconds. Defaults to `5s`")
-	StartnodeCmd.Flags().StringVar(&flags.NetworkMinimumTimeout, "network-minimum-timeout", flags.NetworkMinimumTimeout, "Minimum timeout value of the adaptive timeout manager, in nanoseconds. Defaults to `5s`")
-	StartnodeCmd.Flags().StringVar(&flags.NetworkMaximumTimeout, "network-maximum-timeout", flags.NetworkMaximumTimeout, "Maximum timeout value of the adaptive timeout manager, in nanoseconds. Defaults to `10s`")
-	StartnodeCmd.Flags().Float64Var(&flags.NetworkHealthMaxSendFailRateKey, "network-health-max-send-fail-rate", flags.NetworkHealthMaxSendFailRateKey, "Network layer reports unhealthy if more than this portion of attempted message sends fail")
-	StartnodeCmd.Flags().Float64Var(&flags.NetworkHealthMaxPortionSendQueueFillKey, "network-health-max-portion-send-queue-full", flags.NetworkHealthMaxPortionSendQueueFillKey, "Network layer returns unhealthy if more than this portion of the pending send queue is full")
-	StartnodeCmd.Flags().StringVar(&flags.NetworkHealthMaxTimeSinceMsgSentKey, "network-health-max-time-since-msg-sent", flags.NetworkHealthMaxTimeSinceMsgSentKey, "Network layer returns unhealthy if haven't sent a message for at least this much time")
-	StartnodeCmd.Flags().StringVar(&flags.NetworkHealthMaxTimeSinceMsgReceivedKey, "network-health-max-time-since-msg-received", flags.NetworkHealthMaxTimeSinceMsgReceivedKey, "Network layer returns unhealthy if haven't received a message for at least this much time")
-	StartnodeCmd.Flags().IntVar(&flags.NetworkHealthMinConnPeers, "network-health-min-conn-peers", flags.NetworkHealthMinConnPeers, "Network layer returns unhealthy if connected to less than this many peers")
-	StartnodeCmd.Flags().IntVar(&flags.NetworkTimeoutCoefficient, "network-timeout-coefficient", flags.NetworkTimeoutCoefficient, "Multiplied by average network response time to get the network timeout. Must be >= 1.")
-	StartnodeCmd.Flags().StringVar(&flags.NetworkTimeoutHalflife, "network-timeout-halflife", flags.NetworkTimeoutHalflife, "Halflife of average network response time. Higher value --> network timeout is less volatile. Can't be 0.")
-
-	StartnodeCmd.Flags().BoolVar(&flags.RestartOnDisconnected, "restart-on-disconnected", flags.RestartOnDisconnected, "Defaults to `false`")
-	StartnodeCmd.Flags().StringVar(&flags.DisconnectedCheckFrequency, "disconnected-check-frequency", flags.DisconnectedCheckFrequency, "Defaults to `10s`")
-	StartnodeCmd.Flags().StringVar(&flags.DisconnectedRestartTimeout, "disconnected-restart-timeout", flags.DisconnectedRestartTimeout, "Defaults to `1m`")
-
-	StartnodeCmd.Flags().Float64Var(&flags.UptimeRequirement, "uptime-requirement", flags.UptimeRequirement, "Fraction of time a validator must be online to receive rewards. Defaults to `0.6`")
-
-	StartnodeCmd.Flags().IntVar(&flags.RetryBootstrapMaxAttempts, "bootstrap-retry-max-attempts", flags.RetryBootstrapMaxAttempts, "Specifies how many times bootstrap should be retried")
-	StartnodeCmd.Flags().BoolVar(&flags.RetryBootstrap, "bootstrap-retry-enabled", flags.RetryBootstrap, "Specifies whether bootstrap should be retried")
-
-	StartnodeCmd.Flags().StringVar(&flags.HealthCheckFreqKey, "health-check-frequency", flags.HealthCheckFreqKey, "Time between health checks")
-	StartnodeCmd.Flags().StringVar(&flags.HealthCheckAveragerHalflifeKey, "health-check-averager-halflife", flags.HealthCheckAveragerHalflifeKey, "Halflife of averager when calculating a running average in a health check")
-
-	StartnodeCmd.Flags().IntVar(&flags.RouterHealthMaxOutstandingRequestsKey, "router-health-max-outstanding-requests", flags.RouterHealthMaxOutstandingRequestsKey, "Node reports unhealthy if there are more than this many outstanding consensus requests (Get, PullQuery, etc.) over all chains")
-	StartnodeCmd.Flags().Float64Var(&flags.RouterHealthMaxDropRateKey, "router-health-max-drop-rate", flags.RouterHealthMaxDropRateKey, "Node reports unhealthy if the router drops more than this portion of messages.")
-
-	StartnodeCmd.Flags().BoolVar(&flags.IndexEnabled, "index-enabled", flags.IndexEnabled, "If true, index all accepted containers and transactions and expose them via an API")
+	node.RegisterFlags(StartnodeCmd.Flags(), &flags)
+	startnodePolicy.register(StartnodeCmd.Flags())
+	startnodeLimits.register(StartnodeCmd.Flags())
+	StartnodeCmd.Flags().BoolVar(&startnodeWait, "wait", startnodeWait, "Block until the node is ready, as \"procmanager wait\" does.")
+	StartnodeCmd.Flags().StringVar(&startnodeWaitUntil, "wait-until", startnodeWaitUntil, "Readiness to wait for with --wait. Should be one of {listening, healthy, bootstrapped}.")
+	StartnodeCmd.Flags().DurationVar(&startnodeWaitTimeout, "wait-timeout", startnodeWaitTimeout, "Time to wait for the node to become ready with --wait.")
+	StartnodeCmd.Flags().StringArrayVar(&startnodeEnv, "env", startnodeEnv, "Environment variable to set for the node process as KEY=VALUE, overriding the environment of avash. May be repeated.")
+	StartnodeCmd.Flags().StringVar(&startnodeConfigMode, "config-mode", startnodeConfigMode, "How flags are given to the node. Should be one of {args, file}: file writes them into a JSON config file in the node's stash directory, passed with --config-file.")
+	StartnodeCmd.Flags().StringVar(&startnodeStakingCert, "staking-cert", startnodeStakingCert, "Directory holding the staker.crt and staker.key files to stake with, or \"auto\" (or \"auto:DIR\" for \"certs generate --out DIR\") to allocate an unused pair generated by \"certs generate\".")
+	StartnodeCmd.Flags().StringVar(&startnodeProfile, "profile", startnodeProfile, "Profile of the config file whose node flags to apply. Flags given on the command line take precedence.")
+	StartnodeCmd.Flags().StringVar(&startnodeImportConfig, "import-config", startnodeImportConfig, "JSON config file of the node client to import flags from. Flags given on the command line take precedence.")
+	StartnodeCmd.Flags().BoolVar(&startnodeAutoPorts, "auto-ports", startnodeAutoPorts, "Allocate free consecutive HTTP and staking ports from the port range.")
+	StartnodeCmd.Flags().StringVar(&startnodePortRange, "port-range", startnodePortRange, "Range of ports allocated with --auto-ports as low-high. Defaults to the config file's value.")
+	StartnodeCmd.Flags().StringVar(&startnodeWorkDir, "workdir", startnodeWorkDir, "Working directory of the node process. Defaults to the working directory of avash.")
+	StartnodeCmd.Flags().StringSliceVar(&startnodeLabels, "label", startnodeLabels, "Label to attach to the node process as key=value, for selecting it with a selector. May be repeated.")
 }
@@ -0,0 +1,149 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ava-labs/avash/cfg"
+	"github.com/ava-labs/avash/node"
+	pmgr "github.com/ava-labs/avash/processmgr"
+)
+
+// Returns the node metadata of the process
+func nodeMetadata(t *testing.T, name string) node.Metadata {
+	meta, err := pmgr.ProcManager.Metadata(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var md node.Metadata
+	if err := json.Unmarshal([]byte(meta), &md); err != nil {
+		t.Fatal(err)
+	}
+	return md
+}
+
+func TestStartnodeImportConfig(t *testing.T) {
+	defer withShell(t)()
+	path := filepath.Join(cfg.Config.DataDir, "node.json")
+	if err := ioutil.WriteFile(path, []byte(`{"log-level": "debug"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// A flag given on the command line is kept even if set to its default
+	execute(t, "startnode i1 --auto-ports --import-config="+path+" --log-level=info")
+	if md := nodeMetadata(t, "i1"); md.Loglevel != "info" {
+		t.Fatalf("startnode imported log level %s over the command line expected %s", md.Loglevel, "info")
+	}
+	execute(t, "startnode i2 --auto-ports --import-config="+path)
+	if md := nodeMetadata(t, "i2"); md.Loglevel != "debug" {
+		t.Fatalf("startnode imported log level %s expected %s", md.Loglevel, "debug")
+	}
+}
+
+func TestStartnodeFDLimit(t *testing.T) {
+	defer withShell(t)()
+	tests := []struct {
+		flags   string
+		fdLimit string
+	}{
+		{"--limit-nofile=4096", "--fd-limit=4096"},
+		{"--limit-nofile=4096 --fd-limit=1024", "--fd-limit=1024"},
+		// The default value given on the command line exceeds the cap
+		{"--limit-nofile=4096 --fd-limit=32768", ""},
+	}
+	for i, test := range tests {
+		name := fmt.Sprintf("f%d", i)
+		execute(t, "startnode "+name+" --auto-ports "+test.flags)
+		infos := pmgr.ProcManager.Processes(false, name)
+		if test.fdLimit == "" {
+			if len(infos) != 0 {
+				t.Fatalf("startnode %s added the node with --fd-limit above the cap", test.flags)
+			}
+			continue
+		}
+		if len(infos) != 1 {
+			t.Fatalf("startnode %s did not add the node", test.flags)
+		} else if !strings.Contains(infos[0].Command+" ", " "+test.fdLimit+" ") {
+			t.Fatalf("startnode %s ran %s expected %s", test.flags, infos[0].Command, test.fdLimit)
+		}
+	}
+}
+
+func TestStartnodeAutoPorts(t *testing.T) {
+	defer withShell(t)()
+	cfg.Config.Profiles = map[string]node.Profile{
+		"staker": {Flags: node.FlagsYAML{"http-port": uint(9652), "staking-port": uint(9653)}},
+	}
+	defer func() { cfg.Config.Profiles = nil }()
+
+	// Ports of the profile are replaced by the allocated ones
+	execute(t, "startnode a1 --auto-ports --profile staker")
+	if md := nodeMetadata(t, "a1"); md.HTTPport == "" || md.HTTPport == "9652" {
+		t.Fatalf("startnode --auto-ports --profile ran with HTTP port %q expected an allocated port", md.HTTPport)
+	}
+	// A port given on the command line conflicts, even if it is the default
+	execute(t, "startnode a2 --auto-ports --http-port=9650")
+	if infos := pmgr.ProcManager.Processes(false, "a2"); len(infos) != 0 {
+		t.Fatalf("startnode --auto-ports --http-port added the node")
+	}
+}
+
+func TestStartnodeStakingCertDir(t *testing.T) {
+	defer withShell(t)()
+	dir := filepath.Join(cfg.Config.DataDir, "certs")
+	execute(t, "certs generate --count 1 --out "+dir+" --output json")
+
+	execute(t, "startnode c1 --auto-ports --staking-enabled=true --staking-cert auto:"+dir)
+	if md := nodeMetadata(t, "c1"); filepath.Dir(filepath.Dir(md.StakerCertPath)) != dir {
+		t.Fatalf("startnode allocated staking certificate %s expected one of %s", md.StakerCertPath, dir)
+	}
+	// The only certificate of the directory is in use
+	execute(t, "startnode c2 --auto-ports --staking-enabled=true --staking-cert auto:"+dir)
+	if infos := pmgr.ProcManager.Processes(false, "c2"); len(infos) != 0 {
+		t.Fatalf("startnode allocated a staking certificate in use")
+	}
+}
+
+func TestMetadataNodeID(t *testing.T) {
+	defer withShell(t)()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"jsonrpc":"2.0","id":0,"result":{"nodeID":"NodeID-1"}}`)
+	}))
+	defer server.Close()
+	host, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+	meta := fmt.Sprintf(`{"public-ip":%q,"http-port":%q,"custom":"kept"}`, host, port)
+	// The client runs until stopped, and fails to report its version
+	client := filepath.Join(cfg.Config.DataDir, "client")
+	script := "#!/bin/sh\n[ \"$1\" = --version ] && exit 1\nexec sleep 60\n"
+	if err := ioutil.WriteFile(client, []byte(script), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	execute(t, "startnode m1 --auto-ports --client-location="+client+" --meta="+meta)
+	addSleep(t, "m2", false)
+	pmgr.ProcManager.SetMetadata("m2", meta)
+	pmgr.ProcManager.StartProcess("m2")
+	// A process added under the name of a removed node does not inherit its generated metadata
+	execute(t, "startnode m3 --auto-ports --client-location="+client)
+	execute(t, "procmanager remove m3")
+	addSleep(t, "m3", false)
+	pmgr.ProcManager.SetMetadata("m3", meta)
+	pmgr.ProcManager.StartProcess("m3")
+	for _, name := range []string{"m1", "m2", "m3"} {
+		waitStatus(t, name, pmgr.StateRunning.String())
+		res, _ := execute(t, "procmanager metadata "+name+" --output json")
+		md := res.Data.(map[string]interface{})["metadata"].(map[string]interface{})
+		if md["custom"] != "kept" {
+			t.Fatalf("metadata rewrote the metadata of %s to %v", name, md)
+		} else if _, ok := md["node-id"]; ok {
+			t.Fatalf("metadata recorded a NodeID in the metadata of %s", name)
+		}
+	}
+}
@@ -126,16 +126,25 @@ var VarStoreListCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		log := cfg.Config.Log
 		results := []string{}
+		data := map[string]interface{}{}
 		if len(args) >= 1 {
 			if store, err := AvashVars.Get(args[0]); err == nil {
 				results = store.List()
+				data["store"] = args[0]
 			} else {
 				log.Error("store not found:" + args[0])
+				emit(cmd, nil, err)
+				return
 			}
 		} else {
 			results = AvashVars.List()
 		}
 		radix.Sort(results)
+		if structured() {
+			data["items"] = results
+			emit(cmd, data, nil)
+			return
+		}
 		for _, v := range results {
 			log.Info(v)
 		}
@@ -150,12 +159,21 @@ var VarStorePrintCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		if len(args) >= 2 {
 			log := cfg.Config.Log
-			if store, err := AvashVars.Get(args[0]); err == nil {
-				if v, e := store.Get(args[1]); e == nil {
-					log.Info(v)
-				} else {
-					log.Info("{}")
+			store, err := AvashVars.Get(args[0])
+			var v string
+			if err == nil {
+				v, err = store.Get(args[1])
+			}
+			if structured() {
+				var data interface{}
+				if err == nil {
+					data = map[string]interface{}{"store": args[0], "variable": args[1], "value": jsonValue(v)}
 				}
+				emit(cmd, data, err)
+				return
+			}
+			if err == nil {
+				log.Info(v)
 			} else {
 				log.Info("{}")
 			}
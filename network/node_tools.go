@@ -2,6 +2,7 @@ package network
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
@@ -16,6 +17,8 @@ import (
 
 const (
 	datadir string = "./stash"
+	// ctnrdir is where the data stash is mounted into node containers by startnode.sh
+	ctnrdir string = "/data"
 )
 
 // HostAuth represents a full set of host SSH credentials
@@ -84,11 +87,16 @@ func InitAuth(deploys []DeployConfig) (map[string]HostAuth, error) {
 	return m, nil
 }
 
-// Deploy deploys nodes to hosts as specified in `config`
-func Deploy(deploys []DeployConfig, isPrompt bool) error {
+// Deploy deploys nodes to hosts as specified in `config`, giving them their
+// flags as arguments or in a config file according to `configMode`
+func Deploy(deploys []DeployConfig, isPrompt bool, configMode string) error {
 	log := cfg.Config.Log
 	const cfp string = "./startnode.sh"
 
+	if err := validateDeploys(deploys); err != nil {
+		return err
+	}
+
 	authMap, err := InitAuth(deploys)
 	if err != nil {
 		return err
@@ -130,9 +138,32 @@ func Deploy(deploys []DeployConfig, isPrompt bool) error {
 				}
 				basename := sanitize.BaseName(n.Name)
 				datapath := datadir + "/" + basename
-				flags, _ := node.FlagsToArgs(n.Flags, datapath, true)
+				flags, _ := node.FlagsToArgs(node.MountFlags(n.Flags, ctnrdir), datapath, true)
+				if configMode == node.ConfigModeFile && n.Flags.ConfigFile != "" {
+					log.Error("%s: config-file of %s cannot be combined with config mode %s", ip, n.Name, configMode)
+					return
+				} else if configMode == node.ConfigModeFile {
+					// The arguments read by startnode.sh are kept
+					config, rest := node.ConfigFromArgs(flags, "http-port", "staking-port", "data-dir")
+					if err := copyConfigFile(config, datapath, client); err != nil {
+						log.Error("%s: %s", ip, err.Error())
+						return
+					}
+					flags = append(rest, "--config-file="+ctnrdir+"/"+node.ConfigFileName)
+				}
 				args := strings.Join(flags, " ")
 				cmd := fmt.Sprintf("%s --name=%s %s", cfp, n.Name, args)
+				if len(n.ExtraArgs) > 0 {
+					for _, dup := range node.DuplicateArgs(flags, n.ExtraArgs) {
+						log.Warn("%s: client argument --%s of %s duplicates a flag set by avash, so is passed to the node twice", ip, dup, n.Name)
+					}
+					// Extra arguments follow --, and are quoted to reach the client verbatim
+					var extra []string
+					for _, arg := range n.ExtraArgs {
+						extra = append(extra, shellQuote(arg))
+					}
+					cmd += " -- " + strings.Join(extra, " ")
+				}
 				cmds = append(cmds, cmd)
 			}
 
@@ -191,6 +222,28 @@ func Remove(deploys []DeployConfig, isPrompt bool) error {
 	return nil
 }
 
+// copyConfigFile writes the config into the config file of the node's stash directory on the host
+func copyConfigFile(config map[string]interface{}, datapath string, client *SSHClient) error {
+	f, err := ioutil.TempFile("", "avash-config")
+	if err != nil {
+		return err
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+	if err := node.WriteConfigFile(f.Name(), config); err != nil {
+		return err
+	}
+	if err := client.Run([]string{"mkdir -p " + datapath}); err != nil {
+		return err
+	}
+	return client.CopyFile(f.Name(), datapath+"/"+node.ConfigFileName)
+}
+
+// shellQuote quotes the string as a single shell word
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
 func configureCLIFiles(flags *node.Flags, datadir string, client *SSHClient) error {
 	wd, _ := os.Getwd()
 	if fp := flags.HTTPTLSCertFile; fp != "" {
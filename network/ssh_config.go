@@ -3,7 +3,7 @@ package network
 import (
 	"fmt"
 	"io/ioutil"
-	"reflect"
+	"strings"
 	"github.com/ava-labs/avash/node"
 	"gopkg.in/yaml.v2"
 )
@@ -16,6 +16,8 @@ type RawConfig struct {
 	Nodes []struct{
 		Class string
 		Flags node.FlagsYAML
+		// ExtraArgs are passed to the client verbatim, unvalidated
+		ExtraArgs []string `yaml:"extra-args"`
 	}
 	Deploys []struct{
 		Host  string
@@ -33,8 +35,9 @@ type HostConfig struct {
 
 // NodeConfig is a node configuration
 type NodeConfig struct {
-	Name  string
-	Flags node.Flags
+	Name      string
+	Flags     node.Flags
+	ExtraArgs []string
 }
 
 // DeployConfig is a deploy instruction for a particular host
@@ -60,6 +63,29 @@ func InitConfig(cfgpath string) ([]DeployConfig, error) {
 	return deployCfg, nil
 }
 
+// validateDeploys validates the flags of every node, reporting every violation.
+// The ports of the nodes of a host are reserved by the nodes before them.
+func validateDeploys(deploys []DeployConfig) error {
+	var violations []string
+	for _, deploy := range deploys {
+		reserved := make(map[uint]string)
+		for _, n := range deploy.Nodes {
+			err := node.ValidateFlags(n.Flags, node.Validation{Remote: true, Reserved: reserved})
+			if verr, ok := err.(node.ValidationError); ok {
+				for _, v := range verr {
+					violations = append(violations, fmt.Sprintf("%s: node %s: %s", deploy.IP, n.Name, v))
+				}
+			}
+			reserved[n.Flags.HTTPPort] = n.Name
+			reserved[n.Flags.StakingPort] = n.Name
+		}
+	}
+	if len(violations) == 0 {
+		return nil
+	}
+	return fmt.Errorf("Invalid node flags:\n%s", strings.Join(violations, "\n"))
+}
+
 func validateConfig(cfg RawConfig, cfgpath string) error {
 	if len(cfg.Hosts) == 0 {
 		return fmt.Errorf("Config must contain at least one host: %s", cfgpath)
@@ -141,20 +167,22 @@ func buildDeploy(config RawConfig) []DeployConfig {
 		hostMap[host.Name] = HostConfig{host.User, host.IP}
 	}
 	nodeMap := make(map[string]node.FlagsYAML)
+	extraMap := make(map[string][]string)
 	for _, n := range config.Nodes {
 		nodeMap[n.Class] = n.Flags
+		extraMap[n.Class] = n.ExtraArgs
 	}
 	var deploys []DeployConfig
 	for _, deploy := range config.Deploys {
 		host := hostMap[deploy.Host]
 		var nodes []NodeConfig
 		for _, n := range deploy.Nodes {
-			flags := nodeMap[n.Class]
-			// Override node class flags with deployment flags
-			overrideFlags(&flags, n.Flags)
+			// Node class flags take precedence, deployment flags fill in those it leaves unset
+			flags := node.OverrideFlags(n.Flags, nodeMap[n.Class])
 			nodes = append(nodes, NodeConfig{
 				Name: n.Name,
 				Flags: node.ConvertYAML(flags),
+				ExtraArgs: extraMap[n.Class],
 			})
 		}
 		deploys = append(deploys, DeployConfig{
@@ -165,13 +193,3 @@ func buildDeploy(config RawConfig) []DeployConfig {
 	}
 	return deploys
 }
-
-func overrideFlags(origFlags *node.FlagsYAML, overFlags node.FlagsYAML) {
-	orig := reflect.Indirect(reflect.ValueOf(origFlags))
-	over := reflect.ValueOf(overFlags)
-	for i := 0; i < orig.NumField(); i++ {
-		if orig.Field(i).IsNil() {
-			orig.Field(i).Set(over.Field(i))
-		}
-	}
-}
\ No newline at end of file
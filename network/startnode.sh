@@ -11,104 +11,23 @@ NAME=""
 H_PORT="9650"
 S_PORT="9651"
 
-# Process flags
+# Process flags, passing arguments after -- to the client verbatim
 FLAGS=""
+EXTRA_ARGS=()
+PASSTHROUGH=""
 for arg in "$@"
 do
+    if [ -n "$PASSTHROUGH" ]; then
+        EXTRA_ARGS+=("$arg")
+        continue
+    fi
     case "$arg" in
+        --)
+            PASSTHROUGH=1
+            ;;
         --name=*)
             NAME="${arg#*=}"
             ;;
-        --assertions-enabled=*|\
-		--tx-fee=*|\
-		--network-id=*|\
-        --public-ip=*|\
-        --dynamic-update-duration=*|\
-        --dynamic-public-ip=*|\
-		--signature-verification-enabled=*|\
-        --api-admin-enabled=*|\
-		--api-ipcs-enabled=*|\
-        --api-keystore-enabled=*|\
-        --api-metrics-enabled=*|\
-		--http-tls-enabled=*|\
-		--http-tls-cert-file=*|\
-		--http-tls-key-file=*|\
-		--bootstrap-ips=*|\
-		--bootstrap-ids=*|\
-		--db-enabled=*|\
-		--log-level=*|\
-		--benchlist-duration=*|\
-		--benchlist-fail-threshold=*|\
-		--consensus-gossip-frequency=*|\
-		--benchlist-min-failing-duration=*|\
-		--benchlist-peer-summary-enabled=*|\
-		--version=*|\
-		--snow-avalanche-batch-size=*|\
-		--snow-avalanche-num-parents=*|\
-		--snow-sample-size=*|\
-		--snow-quorum-size=*|\
-		--snow-virtuous-commit-threshold=*|\
-		--snow-rogue-commit-threshold=*|\
-        --uptime-requirement=*|\
-		--min-delegator-stake=*|\
-		--consensus-shutdown-timeout=*|\
-		--min-delegation-fee=*|\
-		--min-validator-stake=*|\
-		--max-stake-duration=*|\
-		--max-validator-stake=*|\
-		--snow-concurrent-repolls=*|\
-		--stake-minting-period=*|\
-		--creation-tx-fee=*|\
-		--max-non-staker-pending-msgs=*|\
-		--network-initial-timeout=*|\
-		--network-minimum-timeout=*|\
-		--network-maximum-timeout=*|\
-		--network-health-max-send-fail-rate=*|\
-		--index-enabled=*|\
-		--network-health-max-portion-send-queue-full=*|\
-		--network-health-max-time-since-msg-sent=*|\
-		--network-health-max-time-since-msg-received=*|\
-		--network-health-min-conn-peers=*|\
-		--network-timeout-coefficient=*|\
-		--network-timeout-halflife=*|\
-		--bootstrap-retry-max-attempts=*|\
-		--bootstrap-retry-enabled=*|\
-		--health-check-averager-halflife=*|\
-		--health-check-frequency=*|\
-		--router-health-max-outstanding-requests=*|\
-		--router-health-max-drop-rate=*|\
-		--restart-on-disconnected=*|\
-		--disconnected-check-frequency=*|\
-		--disconnected-restart-timeout=*|\
-        --p2p-tls-enabled=*|\
-        --snow-epoch-first-transition=*|\
-        --snow-epoch-duration=*|\
-		--staking-enabled=*|\
-		--staking-disabled-weight=*|\
-		--staking-tls-key-file=*|\
-		--staking-tls-cert-file=*)
-            FLAGS+="${arg} "
-            ;;
-		--api-auth-required=*|\
-		--api-auth-password-file=*|\
-        --min-stake-duration=*|\
-        --whitelisted-subnets=*|\
-        --api-health-enabled=*|\
-        --config-file=*|\
-        --api-info-enabled=*|\
-        --conn-meter-max-conns=*|\
-        --conn-meter-reset-duration=*|\
-        --ipcs-chain-ids=*|\
-        --ipcs-path=*|\
-        --log-display-level=*|\
-        --log-display-highlight=*|\
-        --fd-limit=*|\
-        --http-host=*|\
-        --db-dir=*|\
-        --log-dir=*|\
-        --plugin-dir=*)
-            FLAGS+="${arg%=*}=$CTNR_DIR/${arg#*=} "
-            ;;
         --data-dir=*)
             DATA_DIR+="/${arg#*=}"
             ;;
@@ -118,9 +37,13 @@ do
         --staking-port=*)
             S_PORT="${arg#*=}"
             ;;
+        --*=*)
+            # Flags are rendered by avash, with paths already under $CTNR_DIR
+            FLAGS+="${arg} "
+            ;;
         *)
             echo
-            echo "ERROR: Unsupported flag '${arg%%=*}'"
+            echo "ERROR: Unsupported argument '${arg}'"
             echo
             exit 1
             ;;
@@ -148,4 +71,4 @@ docker run -d --name $NAME \
     -p $H_PORT:$H_PORT \
     -p $S_PORT:$S_PORT \
     avalanchego-$AVALANCHE_COMMIT \
-    /avalanchego/build/avalanche $FLAGS
+    /avalanchego/build/avalanche $FLAGS "${EXTRA_ARGS[@]}"
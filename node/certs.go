@@ -0,0 +1,169 @@
+package node
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// DefaultCertsDir is the directory of the staking certificates generated by
+// `certs generate`, allocated to nodes started with `--staking-cert auto`
+const DefaultCertsDir = "certs/generated"
+
+// Names of the files of generated staking certificates
+const (
+	// CertsIndexFile lists the certificates of the directory with their NodeIDs
+	CertsIndexFile = "index.json"
+	StakerCertFile = "staker.crt"
+	StakerKeyFile  = "staker.key"
+)
+
+// stakingKeyBits is the size of the RSA keys generated, as by the client
+var stakingKeyBits = 4096
+
+// StakingCert is a generated staking certificate and key, listed in the index
+// of its directory
+type StakingCert struct {
+	Name   string `json:"name" yaml:"name"`
+	NodeID string `json:"node-id" yaml:"node-id"`
+	// Cert and Key are the paths of the files, relative to the directory of the index
+	Cert string `json:"cert" yaml:"cert"`
+	Key  string `json:"key" yaml:"key"`
+}
+
+// Paths returns the paths of the certificate and key files in the directory
+func (c StakingCert) Paths(dir string) (string, string) {
+	return filepath.Join(dir, c.Cert), filepath.Join(dir, c.Key)
+}
+
+// GenerateStakingCert returns a new self-signed staking certificate and its
+// key, PEM encoded as the client generates them
+func GenerateStakingCert() ([]byte, []byte, error) {
+	key, err := rsa.GenerateKey(rand.Reader, stakingKeyBits)
+	if err != nil {
+		return nil, nil, fmt.Errorf("couldn't generate rsa key: %s", err.Error())
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(0),
+		NotBefore:             time.Date(2000, time.January, 0, 0, 0, 0, 0, time.UTC),
+		NotAfter:              time.Now().AddDate(100, 0, 0),
+		KeyUsage:              x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature | x509.KeyUsageDataEncipherment,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("couldn't create certificate: %s", err.Error())
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("couldn't marshal private key: %s", err.Error())
+	}
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	return cert, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), nil
+}
+
+// GenerateStakingCerts writes `count` new staking certificates and keys into
+// subdirectories of `dir` named keys1, keys2..., following those already
+// generated, and adds them to the index. It returns the new certificates.
+func GenerateStakingCerts(dir string, count int) ([]StakingCert, error) {
+	if count <= 0 {
+		return nil, fmt.Errorf("count must be greater than 0, not %d", count)
+	}
+	index, err := ReadCertsIndex(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	var generated []StakingCert
+	for n := len(index) + 1; len(generated) < count; n++ {
+		name := fmt.Sprintf("keys%d", n)
+		if _, err := os.Stat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+			continue
+		}
+		c := StakingCert{
+			Name: name,
+			Cert: filepath.Join(name, StakerCertFile),
+			Key:  filepath.Join(name, StakerKeyFile),
+		}
+		if c.NodeID, err = writeStakingCert(dir, c); err != nil {
+			return generated, err
+		}
+		generated = append(generated, c)
+		// The index is kept current, so that certificates written before a failure are listed
+		if err := writeCertsIndex(dir, append(index, generated...)); err != nil {
+			return generated, err
+		}
+	}
+	return generated, nil
+}
+
+// writeStakingCert generates the certificate and key into their files,
+// returning the NodeID of the certificate
+func writeStakingCert(dir string, c StakingCert) (string, error) {
+	cert, key, err := GenerateStakingCert()
+	if err != nil {
+		return "", err
+	}
+	certPath, keyPath := c.Paths(dir)
+	if err := os.MkdirAll(filepath.Dir(certPath), os.ModePerm); err != nil {
+		return "", err
+	}
+	if err := ioutil.WriteFile(certPath, cert, 0644); err != nil {
+		return "", err
+	}
+	if err := ioutil.WriteFile(keyPath, key, 0600); err != nil {
+		return "", err
+	}
+	return ReadCertNodeID(certPath)
+}
+
+// ReadCertsIndex returns the certificates listed in the index of the directory
+func ReadCertsIndex(dir string) ([]StakingCert, error) {
+	data, err := ioutil.ReadFile(filepath.Join(dir, CertsIndexFile))
+	if err != nil {
+		return nil, err
+	}
+	var index []StakingCert
+	if err := json.Unmarshal(data, &index); err != nil {
+		return nil, fmt.Errorf("%s: %s", filepath.Join(dir, CertsIndexFile), err.Error())
+	}
+	return index, nil
+}
+
+func writeCertsIndex(dir string, index []StakingCert) error {
+	data, err := json.MarshalIndent(index, "", "    ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, CertsIndexFile), append(data, '\n'), 0644)
+}
+
+// AllocateStakingCert returns the first certificate of the index of the
+// directory that is not in use. `used` maps the absolute paths of the
+// certificates in use to the names of the nodes using them.
+func AllocateStakingCert(dir string, used map[string]string) (StakingCert, error) {
+	index, err := ReadCertsIndex(dir)
+	if os.IsNotExist(err) {
+		return StakingCert{}, fmt.Errorf("no generated staking certificates in %s", dir)
+	} else if err != nil {
+		return StakingCert{}, err
+	}
+	for _, c := range index {
+		certPath, _ := c.Paths(dir)
+		abs, err := filepath.Abs(certPath)
+		if err != nil {
+			return StakingCert{}, err
+		}
+		if _, ok := used[abs]; !ok {
+			return c, nil
+		}
+	}
+	return StakingCert{}, fmt.Errorf("all %d generated staking certificates in %s are in use", len(index), dir)
+}
@@ -0,0 +1,74 @@
+package node
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGenerateStakingCerts(t *testing.T) {
+	// Smaller keys keep the test fast
+	defer func(bits int) { stakingKeyBits = bits }(stakingKeyBits)
+	stakingKeyBits = 1024
+	dir, err := ioutil.TempDir("", "avash-certs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if _, err := AllocateStakingCert(dir, nil); err == nil || !strings.Contains(err.Error(), "no generated staking certificates") {
+		t.Fatalf("AllocateStakingCert returned error %v expected no certificates", err)
+	}
+	generated, err := GenerateStakingCerts(dir, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	more, err := GenerateStakingCerts(dir, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	index, err := ReadCertsIndex(dir)
+	if err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(index, append(generated, more...)) {
+		t.Fatalf("ReadCertsIndex returned %v expected the generated certificates", index)
+	}
+	for i, c := range index {
+		if expected := fmt.Sprintf("keys%d", i+1); c.Name != expected {
+			t.Fatalf("GenerateStakingCerts named certificate %d %s expected %s", i, c.Name, expected)
+		}
+		certPath, keyPath := c.Paths(dir)
+		if _, err := tls.LoadX509KeyPair(certPath, keyPath); err != nil {
+			t.Fatalf("GenerateStakingCerts wrote an invalid key pair: %s", err.Error())
+		}
+		if id, err := ReadCertNodeID(certPath); err != nil || id != c.NodeID {
+			t.Fatalf("GenerateStakingCerts indexed NodeID %s expected %s, %v", c.NodeID, id, err)
+		}
+		if info, err := os.Stat(keyPath); err != nil || info.Mode().Perm() != 0600 {
+			t.Fatalf("GenerateStakingCerts wrote key %v, %v expected a file only readable by the user", info, err)
+		}
+	}
+
+	used := make(map[string]string)
+	for _, name := range []string{"keys1", "keys2", "keys3"} {
+		c, err := AllocateStakingCert(dir, used)
+		if err != nil {
+			t.Fatal(err)
+		} else if c.Name != name {
+			t.Fatalf("AllocateStakingCert returned %s expected %s", c.Name, name)
+		}
+		certPath, _ := c.Paths(dir)
+		used[certPath] = "n" + name
+	}
+	if _, err := AllocateStakingCert(dir, used); err == nil || !strings.Contains(err.Error(), "all 3 generated staking certificates") {
+		t.Fatalf("AllocateStakingCert returned error %v expected every certificate in use", err)
+	}
+	if _, err := GenerateStakingCerts(filepath.Join(dir, "keys1", StakerCertFile), 1); err == nil {
+		t.Fatalf("GenerateStakingCerts returned no error for a file")
+	}
+}
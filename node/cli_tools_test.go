@@ -0,0 +1,18 @@
+package node
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDuplicateArgs(t *testing.T) {
+	args, _ := FlagsToArgs(DefaultFlags(), "stash/n1", false)
+	extra := []string{"--http-port=9700", "-log-level", "debug", "--some-new-flag=true", "--http-tls-cert-file=c.crt"}
+	// The cert file is empty by default, so is not rendered
+	if dups := DuplicateArgs(args, extra); !reflect.DeepEqual(dups, []string{"http-port", "log-level"}) {
+		t.Fatalf("DuplicateArgs returned %v expected %v", dups, []string{"http-port", "log-level"})
+	}
+	if dups := DuplicateArgs(args, nil); dups != nil {
+		t.Fatalf("DuplicateArgs returned %v for no extra arguments", dups)
+	}
+}
@@ -0,0 +1,91 @@
+package node
+
+import (
+	"strings"
+)
+
+// FlagCompat describes the client versions supporting a flag rendered by avash
+type FlagCompat struct {
+	// Since is the first version supporting the flag, unless zero
+	Since ClientVersion
+	// Until is the first version no longer supporting the flag, unless zero
+	Until ClientVersion
+	// RenamedTo is the name of the flag from version RenamedIn on, if renamed
+	RenamedTo string
+	RenamedIn ClientVersion
+	// Values translates the values of the renamed flag, if they changed too
+	Values map[string]string
+}
+
+// FlagsCompat maps the flags that not every client version supports, by the
+// versions of the releases adding, removing or renaming them. Flags missing
+// from the map are supported by every version.
+var FlagsCompat = map[string]FlagCompat{
+	"restart-on-disconnected":                    {Since: MustParseClientVersion("1.0.4")},
+	"disconnected-check-frequency":               {Since: MustParseClientVersion("1.0.4")},
+	"disconnected-restart-timeout":               {Since: MustParseClientVersion("1.0.4")},
+	"network-health-max-send-fail-rate":          {Since: MustParseClientVersion("1.0.5")},
+	"network-health-max-portion-send-queue-full": {Since: MustParseClientVersion("1.0.5")},
+	"network-health-max-time-since-msg-sent":     {Since: MustParseClientVersion("1.0.5")},
+	"network-health-max-time-since-msg-received": {Since: MustParseClientVersion("1.0.5")},
+	"network-health-min-conn-peers":              {Since: MustParseClientVersion("1.0.5")},
+	"health-check-frequency":                     {Since: MustParseClientVersion("1.0.5")},
+	"health-check-averager-halflife":             {Since: MustParseClientVersion("1.0.5")},
+	"router-health-max-outstanding-requests":     {Since: MustParseClientVersion("1.0.5")},
+	"router-health-max-drop-rate":                {Since: MustParseClientVersion("1.0.5")},
+	"bootstrap-retry-enabled":                    {Since: MustParseClientVersion("1.0.5")},
+	"bootstrap-retry-max-attempts":               {Since: MustParseClientVersion("1.0.5")},
+	"snow-epoch-first-transition":                {Since: MustParseClientVersion("1.0.6"), Until: MustParseClientVersion("1.4.0")},
+	"snow-epoch-duration":                        {Since: MustParseClientVersion("1.0.6"), Until: MustParseClientVersion("1.4.0")},
+	"index-enabled":                              {Since: MustParseClientVersion("1.2.0")},
+	"p2p-tls-enabled":                            {Until: MustParseClientVersion("1.4.5")},
+	"max-non-staker-pending-msgs":                {Until: MustParseClientVersion("1.4.5")},
+	"conn-meter-max-conns":                       {Until: MustParseClientVersion("1.4.5")},
+	"conn-meter-reset-duration": {
+		RenamedTo: "inbound-connection-throttling-cooldown",
+		RenamedIn: MustParseClientVersion("1.4.5"),
+	},
+	"db-enabled": {
+		RenamedTo: "db-type",
+		RenamedIn: MustParseClientVersion("1.4.5"),
+		Values:    map[string]string{"true": "leveldb", "false": "memdb"},
+	},
+}
+
+// Supports returns true if the client version supports the flag
+func (c FlagCompat) Supports(v ClientVersion) bool {
+	return (c.Since.IsZero() || !v.Before(c.Since)) && (c.Until.IsZero() || v.Before(c.Until))
+}
+
+// CompatArgs adapts the arguments rendered by FlagsToArgs to the client version,
+// omitting the flags it does not support and translating the renamed ones. It
+// returns the arguments and the names of the omitted flags. The arguments are
+// returned unchanged if the version is unknown.
+func CompatArgs(args []string, v ClientVersion) ([]string, []string) {
+	if v.IsZero() {
+		return args, nil
+	}
+	var compat, omitted []string
+	for _, arg := range args {
+		name := argName(arg)
+		c, ok := FlagsCompat[name]
+		switch {
+		case !ok:
+			compat = append(compat, arg)
+		case !c.Supports(v):
+			omitted = append(omitted, name)
+		case c.RenamedTo != "" && !v.Before(c.RenamedIn):
+			var value string
+			if i := strings.Index(arg, "="); i >= 0 {
+				value = arg[i+1:]
+			}
+			if translated, ok := c.Values[value]; ok {
+				value = translated
+			}
+			compat = append(compat, "--"+c.RenamedTo+"="+value)
+		default:
+			compat = append(compat, arg)
+		}
+	}
+	return compat, omitted
+}
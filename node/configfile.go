@@ -0,0 +1,162 @@
+package node
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Config modes, of how node flags are given to the client
+const (
+	// ConfigModeArgs passes every flag as a command line argument
+	ConfigModeArgs = "args"
+	// ConfigModeFile writes the flags into a JSON config file, passed with --config-file
+	ConfigModeFile = "file"
+)
+
+// ConfigFileName is the name of the config file written into the stash of a node
+const ConfigFileName = "config.json"
+
+// ValidateConfigMode returns an error if the config mode is unknown
+func ValidateConfigMode(mode string) error {
+	if mode != ConfigModeArgs && mode != ConfigModeFile {
+		return fmt.Errorf("Invalid config mode %q: should be one of {%s, %s}", mode, ConfigModeArgs, ConfigModeFile)
+	}
+	return nil
+}
+
+// ConfigFromArgs moves the `--name=value` arguments rendered by FlagsToArgs
+// into a JSON config, keyed by flag name. The values of known flags have the
+// types of their fields. Arguments of flags named by `keep` and any other
+// arguments are returned in order.
+func ConfigFromArgs(args []string, keep ...string) (map[string]interface{}, []string) {
+	config := make(map[string]interface{})
+	var rest []string
+	for _, arg := range args {
+		name := argName(arg)
+		i := strings.Index(arg, "=")
+		if name == "" || i < 0 || contains(keep, name) {
+			rest = append(rest, arg)
+			continue
+		}
+		config[name] = configValue(name, arg[i+1:])
+	}
+	return config, rest
+}
+
+// configValue returns the value of the flag converted to the type of its field,
+// or as given if the flag is unknown or the value does not parse
+func configValue(name string, value string) interface{} {
+	s, ok := LookupFlag(name)
+	if !ok {
+		return value
+	}
+	var v interface{}
+	var err error
+	switch s.Default.(type) {
+	case bool:
+		v, err = strconv.ParseBool(value)
+	case uint:
+		var n uint64
+		n, err = strconv.ParseUint(value, 10, 0)
+		v = uint(n)
+	case int:
+		v, err = strconv.Atoi(value)
+	case float64:
+		v, err = strconv.ParseFloat(value, 64)
+	default:
+		return value
+	}
+	if err != nil {
+		return value
+	}
+	return v
+}
+
+// WriteConfigFile writes the config as JSON to the file at `path`. It is only
+// readable by the user, as it may name secrets such as password files.
+func WriteConfigFile(path string, config map[string]interface{}) error {
+	data, err := json.MarshalIndent(config, "", "    ")
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(path, append(data, '\n'), 0600); err != nil {
+		return err
+	}
+	// An existing file keeps its mode when rewritten
+	return os.Chmod(path, 0600)
+}
+
+// ImportConfig reads the flags of a client JSON config file. Values may be
+// given as JSON values or strings, as the client accepts both. Flags managed by
+// avash itself, such as data-dir, are ignored.
+func ImportConfig(path string) (FlagsYAML, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err.Error())
+	}
+	var names []string
+	for name := range raw {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	flags := make(FlagsYAML)
+	for _, name := range names {
+		s, ok := LookupFlag(name)
+		if !ok {
+			return nil, fmt.Errorf("%s: unknown node flag %q", path, name)
+		} else if s.Avash {
+			continue
+		}
+		value := reflect.New(reflect.TypeOf(s.Default))
+		literal := strings.TrimSpace(string(raw[name]))
+		if err := json.Unmarshal(raw[name], value.Interface()); err != nil {
+			var str string
+			if _, ok := s.Default.(string); ok && !strings.ContainsAny(literal[:1], `"{[`) {
+				// Numbers and booleans are taken literally for string flags, e.g. network IDs
+				flags[name] = literal
+				continue
+			}
+			if json.Unmarshal(raw[name], &str) != nil {
+				return nil, fmt.Errorf("%s: invalid value of %s: %s", path, name, err.Error())
+			}
+			v := configValue(name, str)
+			if reflect.TypeOf(v) != value.Elem().Type() {
+				return nil, fmt.Errorf("%s: invalid value of %s: %q", path, name, str)
+			}
+			value.Elem().Set(reflect.ValueOf(v))
+		}
+		flags[name] = value.Elem().Interface()
+	}
+	return flags, nil
+}
+
+// FillFlags sets the flags to the given values, except those named in `keep`,
+// such as the flags given on the command line
+func FillFlags(flags *Flags, values FlagsYAML, keep []string) {
+	for name, value := range values {
+		s, ok := LookupFlag(name)
+		if !ok || contains(keep, name) {
+			continue
+		}
+		s.value(flags).Set(reflect.ValueOf(value))
+	}
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
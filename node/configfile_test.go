@@ -0,0 +1,91 @@
+package node
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Writes the config file into a temporary directory, returning its path and a cleanup function
+func writeTestConfig(t *testing.T, data string) (string, func()) {
+	dir, err := ioutil.TempDir("", "avash-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, ConfigFileName)
+	if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return path, func() { os.RemoveAll(dir) }
+}
+
+func TestConfigFileRoundTrip(t *testing.T) {
+	flags := testFlags()
+	args, _ := FlagsToArgs(flags, "/stash/n1", true)
+	config, rest := ConfigFromArgs(append(args, "--db-type=memdb", "positional"), "http-port")
+	if !reflect.DeepEqual(rest, []string{"--http-port=9651", "positional"}) {
+		t.Fatalf("ConfigFromArgs kept %v", rest)
+	} else if config["db-type"] != "memdb" || config["staking-port"] != uint(9652) || config["snow-sample-size"] != 3 {
+		t.Fatalf("ConfigFromArgs returned %v", config)
+	}
+	delete(config, "db-type")
+
+	path, cleanup := writeTestConfig(t, "")
+	defer cleanup()
+	if err := WriteConfigFile(path, config); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Fatalf("WriteConfigFile wrote %v, %v expected a file only readable by the user", info, err)
+	}
+	imported, err := ImportConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed := DefaultFlags()
+	FillFlags(&parsed, imported, nil)
+	// The HTTP port was kept as an argument, and avash flags are not imported
+	flags.HTTPPort, flags.ClientLocation, flags.Meta, flags.DataDir = 9650, "", "", ""
+	if !reflect.DeepEqual(parsed, flags) {
+		t.Fatalf("importing ConfigFromArgs returned %+v expected %+v", parsed, flags)
+	}
+}
+
+func TestImportConfig(t *testing.T) {
+	path, cleanup := writeTestConfig(t, `{"network-id": 12345, "http-port": "9700", "staking-enabled": "true", "log-level": "debug"}`)
+	defer cleanup()
+	imported, err := ImportConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := FlagsYAML{"network-id": "12345", "http-port": uint(9700), "staking-enabled": true, "log-level": "debug"}
+	if !reflect.DeepEqual(imported, expected) {
+		t.Fatalf("ImportConfig returned %v expected %v", imported, expected)
+	}
+
+	// Kept flags are not imported, even when set to their defaults
+	flags := DefaultFlags()
+	flags.LogLevel = "warn"
+	FillFlags(&flags, imported, []string{"log-level", "staking-enabled"})
+	if flags.LogLevel != "warn" || flags.StakingEnabled || flags.HTTPPort != 9700 || flags.NetworkID != "12345" {
+		t.Fatalf("FillFlags returned %+v", flags)
+	}
+
+	for doc, msg := range map[string]string{
+		`{"max-validator-stakes": 1}`: `unknown node flag "max-validator-stakes"`,
+		`{"http-port": "port"}`:       "invalid value of http-port",
+		`{"http-port": true}`:         "invalid value of http-port",
+		`[]`:                          "cannot unmarshal",
+	} {
+		path, cleanup := writeTestConfig(t, doc)
+		_, err := ImportConfig(path)
+		cleanup()
+		if err == nil || !strings.Contains(err.Error(), msg) {
+			t.Fatalf("ImportConfig of %s returned error %v expected %q", doc, err, msg)
+		}
+	}
+}
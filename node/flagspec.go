@@ -0,0 +1,310 @@
+package node
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"reflect"
+	"sort"
+	"strconv"
+
+	"github.com/spf13/pflag"
+)
+
+// PathKind is how a relative path given to a node flag is resolved
+type PathKind int
+
+const (
+	// PathNone values are passed to the node as given
+	PathNone PathKind = iota
+	// PathData values are relative to the data stash of the node
+	PathData
+	// PathWorkDir values are relative to the working directory of avash
+	PathWorkDir
+)
+
+// FlagSpec declares a node flag. The table of specs generates the `startnode`
+// flags, the defaults, the YAML decoding of network configs and the arguments
+// rendered for the node.
+type FlagSpec struct {
+	// Name is the flag name, also its YAML key
+	Name string
+	// Field is the field of Flags holding the value
+	Field string
+	// Default is the default value, of the type of the field
+	Default interface{}
+	Usage   string
+	// Avash flags configure avash itself, so are neither rendered for the node
+	// nor decoded from YAML
+	Avash bool
+	Path  PathKind
+	// Mount flags are paths into the data stash, which is mounted into the
+	// container of remote deployments
+	Mount bool
+}
+
+// FlagSpecs declares every node flag, in the order of the fields of Flags
+var FlagSpecs = []FlagSpec{
+	// Avash metadata
+	{Name: "client-location", Field: "ClientLocation", Avash: true, Default: "", Usage: "Path to AVA node client, defaulting to the config file's value."},
+	{Name: "meta", Field: "Meta", Avash: true, Default: "", Usage: "Override default metadata for the node process."},
+	{Name: "data-dir", Field: "DataDir", Avash: true, Default: "", Usage: "Name of directory for the data stash."},
+
+	// Assertions
+	{Name: "assertions-enabled", Field: "AssertionsEnabled", Default: true, Usage: "Turn on assertion execution."},
+
+	// Version
+	{Name: "version", Field: "Version", Default: false, Usage: "If this is `true`, print the version and quit. Defaults to `false`"},
+
+	// TX fees
+	{Name: "tx-fee", Field: "TxFee", Default: uint(1000000), Usage: "Transaction fee, in $nAVAX."},
+
+	// IP
+	{Name: "public-ip", Field: "PublicIP", Default: "127.0.0.1", Usage: "Public IP of this node."},
+	{Name: "dynamic-update-duration", Field: "DynamicUpdateDuration", Default: "5m", Usage: "The time between poll events for `--dynamic-public-ip` or NAT traversal. The recommended minimum is 1 minute. Defaults to `5m`"},
+	{Name: "dynamic-public-ip", Field: "DynamicPublicIP", Default: "", Usage: "Valid values if param is present: `opendns`, `ifconfigco` or `ifconfigme`. This overrides `--public-ip`. If set, will poll the remote service every `--dynamic-update-duration` and update the node’s public IP address."},
+
+	// Network ID
+	{Name: "network-id", Field: "NetworkID", Default: "local", Usage: "Network ID this node will connect to."},
+
+	// Crypto
+	{Name: "signature-verification-enabled", Field: "SignatureVerificationEnabled", Default: true, Usage: "Turn on signature verification."},
+	{Name: "p2p-tls-enabled", Field: "P2PTLSEnabled", Default: true, Usage: "Require TLS to authenticate network communications"},
+
+	// APIs
+	{Name: "api-admin-enabled", Field: "APIAdminEnabled", Default: true, Usage: "If true, this node exposes the Admin API"},
+	{Name: "api-ipcs-enabled", Field: "APIIPCsEnabled", Default: true, Usage: "If true, IPCs can be opened"},
+	{Name: "api-keystore-enabled", Field: "APIKeystoreEnabled", Default: true, Usage: "If true, this node exposes the Keystore API"},
+	{Name: "api-metrics-enabled", Field: "APIMetricsEnabled", Default: true, Usage: "If true, this node exposes the Metrics API"},
+	{Name: "api-health-enabled", Field: "APIHealthEnabled", Default: true, Usage: "If set to `true`, this node will expose the Health API. Defaults to `true`"},
+	{Name: "api-info-enabled", Field: "APIInfoEnabled", Default: true, Usage: "If set to `true`, this node will expose the Info API. Defaults to `true`"},
+
+	// HTTP
+	{Name: "http-host", Field: "HTTPHost", Default: "127.0.0.1", Usage: "The address that HTTP APIs listen on."},
+	{Name: "http-port", Field: "HTTPPort", Default: uint(9650), Usage: "Port of the HTTP server."},
+	{Name: "http-tls-enabled", Field: "HTTPTLSEnabled", Default: false, Usage: "Upgrade the HTTP server to HTTPS."},
+	{Name: "http-tls-cert-file", Field: "HTTPTLSCertFile", Path: PathWorkDir, Default: "", Usage: "TLS certificate file for the HTTPS server."},
+	{Name: "http-tls-key-file", Field: "HTTPTLSKeyFile", Path: PathWorkDir, Default: "", Usage: "TLS private key file for the HTTPS server."},
+
+	// Bootstrapping
+	{Name: "bootstrap-ips", Field: "BootstrapIPs", Default: "", Usage: "Comma separated list of bootstrap nodes to connect to. Example: 127.0.0.1:9630,127.0.0.1:9620"},
+	{Name: "bootstrap-ids", Field: "BootstrapIDs", Default: "", Usage: "Comma separated list of bootstrap peer ids to connect to. Example: NodeID-JR4dVmy6ffUGAKCBDkyCbeZbyHQBeDsET,NodeID-8CrVPQZ4VSqgL8zTdvL14G8HqAfrBr4z"},
+
+	// Database
+	{Name: "db-enabled", Field: "DBEnabled", Default: true, Usage: "Turn on persistent storage."},
+	{Name: "db-dir", Field: "DBDir", Path: PathData, Mount: true, Default: "db", Usage: "Database directory for Avalanche state."},
+
+	// Plugins
+	{Name: "plugin-dir", Field: "PluginDir", Mount: true, Default: path.Join(os.Getenv("GOPATH"), "src", "github.com", "ava-labs", "avalanchego", "build", "plugins"), Usage: "Directory to search for plugins"},
+
+	// Logging
+	{Name: "log-level", Field: "LogLevel", Default: "info", Usage: "Specify the log level. Should be one of {verbo, debug, info, warn, error, fatal, off}"},
+	{Name: "log-dir", Field: "LogDir", Path: PathData, Mount: true, Default: "logs", Usage: "Name of directory for the node's logging."},
+	{Name: "log-display-level", Field: "LogDisplayLevel", Default: "", Usage: "{Off, Fatal, Error, Warn, Info, Debug, Verbo}. The log level determines which events to display to the screen. If left blank, will default to the value provided to `--log-level`"},
+	{Name: "log-display-highlight", Field: "LogDisplayHighlight", Default: "colors", Usage: "Whether to color/highlight display logs. Default highlights when the output is a terminal. Otherwise, should be one of {auto, plain, colors}"},
+
+	// Consensus
+	{Name: "snow-avalanche-batch-size", Field: "SnowAvalancheBatchSize", Default: 30, Usage: "Number of operations to batch in each new vertex."},
+	{Name: "snow-avalanche-num-parents", Field: "SnowAvalancheNumParents", Default: 5, Usage: "Number of vertexes for reference from each new vertex."},
+	{Name: "snow-sample-size", Field: "SnowSampleSize", Default: 2, Usage: "Number of nodes to query for each network poll."},
+	{Name: "snow-quorum-size", Field: "SnowQuorumSize", Default: 2, Usage: "Alpha value to use for required number positive results."},
+	{Name: "snow-virtuous-commit-threshold", Field: "SnowVirtuousCommitThreshold", Default: 5, Usage: "Beta value to use for virtuous transactions."},
+	{Name: "snow-rogue-commit-threshold", Field: "SnowRogueCommitThreshold", Default: 10, Usage: "Beta value to use for rogue transactions."},
+	{Name: "snow-epoch-first-transition", Field: "SnowEpochFirstTransition", Default: 1609873200, Usage: "Unix timestamp of the transition from epoch 0 to epoch 1. Defaults to 1609873200 which is 1/5/2021 @ 7:00pm (UTC)"},
+	{Name: "snow-epoch-duration", Field: "SnowEpochDuration", Default: "6h", Usage: "Duration of each epoch. Defaults to `6h`"},
+	{Name: "snow-concurrent-repolls", Field: "SnowConcurrentRepolls", Default: 4, Usage: "Snow consensus requires repolling transactions that are issued during low time of network usage. This parameter lets one define how aggressive the client will be in finalizing these pending transactions. This should only be changed after careful consideration of the tradeoffs of Snow consensus. The value must be at least `1` and at most `--snow-rogue-commit-threshold`. Defaults to `4`"},
+	{Name: "min-delegator-stake", Field: "MinDelegatorStake", Default: 5000000, Usage: "The minimum stake, in nAVAX, that can be delegated to a validator of the Primary Network. Defaults to `25000000000` (25 AVAX) on Main Net. Defaults to `5000000` (.005 AVAX) on Test Net."},
+	{Name: "consensus-shutdown-timeout", Field: "ConsensusShutdownTimeout", Default: "5s", Usage: "Timeout before killing an unresponsive chain. Defaults to `5s`"},
+	{Name: "consensus-gossip-frequency", Field: "ConsensusGossipFrequency", Default: "10s", Usage: "Time between gossiping accepted frontiers. Defaults to `10s`"},
+	{Name: "min-delegation-fee", Field: "MinDelegationFee", Default: 20000, Usage: "The minimum delegation fee that can be charged for delegation on the Primary Network, multiplied by `10,000` . Must be in the range `[0, 1000000]`. Defaults to `20000` (2%) on Main Net."},
+	{Name: "min-validator-stake", Field: "MinValidatorStake", Default: 5000000, Usage: "The minimum stake, in nAVAX, required to validate the Primary Network. Defaults to `2000000000000` (2,000 AVAX) on Main Net. Defaults to `5000000` (.005 AVAX) on Test Net."},
+	{Name: "max-stake-duration", Field: "MaxStakeDuration", Default: "8760h", Usage: "The maximum staking duration, in seconds. Defaults to `8760h` (365 days) on Main Net."},
+	{Name: "max-validator-stake", Field: "MaxValidatorStake", Default: 3000000000000000, Usage: "The maximum stake, in nAVAX, that can be placed on a validator on the primary network. Defaults to `3000000000000000` (3,000,000 AVAX) on Main Net. This includes stake provided by both the validator and by delegators to the validator."},
+	{Name: "creation-tx-fee", Field: "CreationTxFee", Default: 1000000, Usage: "Transaction fee, in nAVAX, for transactions that create new state. Defaults to `1000000` nAVAX (.001 AVAX) per transaction."},
+
+	// Staking
+	{Name: "staking-enabled", Field: "StakingEnabled", Default: false, Usage: "Enable staking. If enabled, Network TLS is required."},
+	{Name: "stake-minting-period", Field: "StakeMintingPeriod", Default: "8760h", Usage: "Consumption period of the staking function, in seconds. The Default on Main Net is `8760h` (365 days)."},
+	{Name: "staking-port", Field: "StakingPort", Default: uint(9651), Usage: "Port of the consensus server."},
+	{Name: "staking-disabled-weight", Field: "StakingDisabledWeight", Default: 1, Usage: "Weight to provide to each peer when staking is disabled. Defaults to `1`"},
+	{Name: "staking-tls-key-file", Field: "StakingTLSKeyFile", Path: PathWorkDir, Default: "", Usage: "TLS private key file for staking connections. Relative to the avash binary if doesn't start with '/'. Ex: certs/keys1/staker.key"},
+	{Name: "staking-tls-cert-file", Field: "StakingTLSCertFile", Path: PathWorkDir, Default: "", Usage: "TLS certificate file for staking connections. Relative to the avash binary if doesn't start with '/'. Ex: certs/keys1/staker.crt"},
+
+	// Auth
+	{Name: "api-auth-required", Field: "APIAuthRequired", Default: false, Usage: "If set to true, API calls require an authorization token. Defaults to `false`"},
+	{Name: "api-auth-password-file", Field: "APIAuthPasswordFileKey", Mount: true, Default: "", Usage: "Password file used to initially create/validate API authorization tokens. Can be changed via API call."},
+	{Name: "min-stake-duration", Field: "MinStakeDuration", Default: "336h", Usage: "Set the minimum staking duration. Ex: --min-stake-duration=5m"},
+
+	// Whitelisted Subnets
+	{Name: "whitelisted-subnets", Field: "WhitelistedSubnets", Default: "", Usage: "Comma separated list of subnets that this node would validate if added to. Defaults to empty (will only validate the Primary Network)"},
+
+	// Config
+	{Name: "config-file", Field: "ConfigFile", Mount: true, Default: "", Usage: "Config file specifies a JSON file to configure a node instead of specifying arguments via the command line. Command line arguments will override any options set in the config file."},
+
+	// Connection
+	{Name: "conn-meter-max-conns", Field: "ConnMeterMaxConns", Default: 5, Usage: "Upgrade at most `conn-meter-max-conns` connections from a given IP per `conn-meter-reset-duration`. If `conn-meter-reset-duration` is 0, incoming connections are not rate-limited."},
+	{Name: "conn-meter-reset-duration", Field: "ConnMeterResetDuration", Default: "", Usage: "Upgrade at most `conn-meter-max-conns` connections from a given IP per `conn-meter-reset-duration`. If `conn-meter-reset-duration` is 0, incoming connections are not rate-limited."},
+
+	// IPCS
+	{Name: "ipcs-chain-ids", Field: "IPCSChainIDs", Default: "", Usage: "Comma separated list of chain ids to connect to. There is no default value."},
+	{Name: "ipcs-path", Field: "IPCSPath", Mount: true, Default: "/tmp", Usage: "The directory (Unix) or named pipe prefix (Windows) for IPC sockets. Defaults to /tmp."},
+
+	// File Descriptor Limit
+	{Name: "fd-limit", Field: "FDLimit", Default: 32768, Usage: "Attempts to raise the process file descriptor limit to at least this value. Defaults to `32768`"},
+
+	// Benchlist
+	{Name: "benchlist-fail-threshold", Field: "BenchlistFailThreshold", Default: 10, Usage: "Number of consecutive failed queries to a node before benching it (assuming all queries to it will fail). Defaults to `10`"},
+	{Name: "benchlist-min-failing-duration", Field: "BenchlistMinFailingDuration", Default: "5m", Usage: "Minimum amount of time messages to a peer must be failing before the peer is benched. Defaults to `5m`"},
+	{Name: "benchlist-peer-summary-enabled", Field: "BenchlistPeerSummaryEnabled", Default: false, Usage: "Enables peer specific query latency metrics. Defaults to `false`"},
+	{Name: "benchlist-duration", Field: "BenchlistDuration", Default: "1h", Usage: "Amount of time a peer is benchlisted after surpassing `--benchlist-fail-threshold`. Defaults to `1h`"},
+
+	// Message Handling
+	{Name: "max-non-staker-pending-msgs", Field: "MaxNonStakerPendingMsgs", Default: 20, Usage: "Maximum number of messages a non-staker is allowed to have pending. Defaults to `20`"},
+
+	// Network Timeout
+	{Name: "network-initial-timeout", Field: "NetworkInitialTimeout", Default: "5s", Usage: "Initial timeout value of the adaptive timeout manager, in nanoseconds. Defaults to `5s`"},
+	{Name: "network-minimum-timeout", Field: "NetworkMinimumTimeout", Default: "5s", Usage: "Minimum timeout value of the adaptive timeout manager, in nanoseconds. Defaults to `5s`"},
+	{Name: "network-maximum-timeout", Field: "NetworkMaximumTimeout", Default: "10s", Usage: "Maximum timeout value of the adaptive timeout manager, in nanoseconds. Defaults to `10s`"},
+	{Name: "network-health-max-send-fail-rate", Field: "NetworkHealthMaxSendFailRateKey", Default: 0.9, Usage: "Network layer reports unhealthy if more than this portion of attempted message sends fail"},
+	{Name: "network-health-max-portion-send-queue-full", Field: "NetworkHealthMaxPortionSendQueueFillKey", Default: 0.9, Usage: "Network layer returns unhealthy if more than this portion of the pending send queue is full"},
+	{Name: "network-health-max-time-since-msg-sent", Field: "NetworkHealthMaxTimeSinceMsgSentKey", Default: "1m", Usage: "Network layer returns unhealthy if haven't sent a message for at least this much time"},
+	{Name: "network-health-max-time-since-msg-received", Field: "NetworkHealthMaxTimeSinceMsgReceivedKey", Default: "1m", Usage: "Network layer returns unhealthy if haven't received a message for at least this much time"},
+	{Name: "network-health-min-conn-peers", Field: "NetworkHealthMinConnPeers", Default: 1, Usage: "Network layer returns unhealthy if connected to less than this many peers"},
+	{Name: "network-timeout-coefficient", Field: "NetworkTimeoutCoefficient", Default: 2, Usage: "Multiplied by average network response time to get the network timeout. Must be >= 1."},
+	{Name: "network-timeout-halflife", Field: "NetworkTimeoutHalflife", Default: "5m", Usage: "Halflife of average network response time. Higher value --> network timeout is less volatile. Can't be 0."},
+
+	// Restart on Disconnect
+	{Name: "restart-on-disconnected", Field: "RestartOnDisconnected", Default: true, Usage: "Defaults to `false`"},
+	{Name: "disconnected-check-frequency", Field: "DisconnectedCheckFrequency", Default: "10s", Usage: "Defaults to `10s`"},
+	{Name: "disconnected-restart-timeout", Field: "DisconnectedRestartTimeout", Default: "1m", Usage: "Defaults to `1m`"},
+
+	// Uptime Requirement
+	{Name: "uptime-requirement", Field: "UptimeRequirement", Default: 0.6, Usage: "Fraction of time a validator must be online to receive rewards. Defaults to `0.6`"},
+
+	// Retry
+	{Name: "bootstrap-retry-max-attempts", Field: "RetryBootstrapMaxAttempts", Default: 50, Usage: "Specifies how many times bootstrap should be retried"},
+	{Name: "bootstrap-retry-enabled", Field: "RetryBootstrap", Default: true, Usage: "Specifies whether bootstrap should be retried"},
+
+	// Health
+	{Name: "health-check-averager-halflife", Field: "HealthCheckAveragerHalflifeKey", Default: "10s", Usage: "Halflife of averager when calculating a running average in a health check"},
+	{Name: "health-check-frequency", Field: "HealthCheckFreqKey", Default: "30s", Usage: "Time between health checks"},
+
+	// Router
+	{Name: "router-health-max-outstanding-requests", Field: "RouterHealthMaxOutstandingRequestsKey", Default: 1024, Usage: "Node reports unhealthy if there are more than this many outstanding consensus requests (Get, PullQuery, etc.) over all chains"},
+	{Name: "router-health-max-drop-rate", Field: "RouterHealthMaxDropRateKey", Default: 1.0, Usage: "Node reports unhealthy if the router drops more than this portion of messages."},
+
+	{Name: "index-enabled", Field: "IndexEnabled", Default: false, Usage: "If true, index all accepted containers and transactions and expose them via an API"},
+}
+
+// LookupFlag returns the spec of the named flag
+func LookupFlag(name string) (FlagSpec, bool) {
+	for _, s := range FlagSpecs {
+		if s.Name == name {
+			return s, true
+		}
+	}
+	return FlagSpec{}, false
+}
+
+// value returns the field of `flags` holding the value of the flag
+func (s FlagSpec) value(flags *Flags) reflect.Value {
+	return reflect.ValueOf(flags).Elem().FieldByName(s.Field)
+}
+
+// RegisterFlags registers every node flag on the flag set, storing the values into `flags`
+func RegisterFlags(fs *pflag.FlagSet, flags *Flags) {
+	for _, s := range FlagSpecs {
+		switch p := s.value(flags).Addr().Interface().(type) {
+		case *string:
+			fs.StringVar(p, s.Name, s.Default.(string), s.Usage)
+		case *bool:
+			fs.BoolVar(p, s.Name, s.Default.(bool), s.Usage)
+		case *uint:
+			fs.UintVar(p, s.Name, s.Default.(uint), s.Usage)
+		case *int:
+			fs.IntVar(p, s.Name, s.Default.(int), s.Usage)
+		case *float64:
+			fs.Float64Var(p, s.Name, s.Default.(float64), s.Usage)
+		default:
+			panic(fmt.Sprintf("node flag %s has unsupported type %T", s.Name, p))
+		}
+	}
+}
+
+// formatFlag renders the value of a flag as a CLI argument
+func formatFlag(v reflect.Value) string {
+	switch v.Kind() {
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool())
+	case reflect.Uint:
+		return strconv.FormatUint(v.Uint(), 10)
+	case reflect.Int:
+		return strconv.FormatInt(v.Int(), 10)
+	case reflect.Float64:
+		return fmt.Sprintf("%f", v.Float())
+	}
+	return v.String()
+}
+
+// MountFlags returns the flags with the paths of Mount flags moved under the
+// directory `dir`, at which the data stash is mounted into a container
+func MountFlags(flags Flags, dir string) Flags {
+	for _, s := range FlagSpecs {
+		if v := s.value(&flags); s.Mount && v.String() != "" {
+			v.SetString(path.Join(dir, v.String()))
+		}
+	}
+	return flags
+}
+
+// FlagsYAML holds the node flags set in a YAML document, keyed by flag name.
+// The values have the types of their fields in Flags.
+type FlagsYAML map[string]interface{}
+
+// yamlSpecs are the specs of the flags decoded from YAML, and yamlType is a
+// struct of a pointer field tagged with the name of each of them
+var yamlSpecs, yamlType = func() ([]FlagSpec, reflect.Type) {
+	var specs []FlagSpec
+	var fields []reflect.StructField
+	for _, s := range FlagSpecs {
+		if s.Avash {
+			continue
+		}
+		specs = append(specs, s)
+		fields = append(fields, reflect.StructField{
+			Name: s.Field,
+			Type: reflect.PtrTo(reflect.TypeOf(s.Default)),
+			Tag:  reflect.StructTag(fmt.Sprintf(`yaml:"%s"`, s.Name)),
+		})
+	}
+	return specs, reflect.StructOf(fields)
+}()
+
+// UnmarshalYAML decodes the flags, failing on unknown flags and values of the wrong type
+func (y *FlagsYAML) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var raw map[string]interface{}
+	if err := unmarshal(&raw); err != nil {
+		return err
+	}
+	var names []string
+	for name := range raw {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if s, ok := LookupFlag(name); !ok || s.Avash {
+			return fmt.Errorf("unknown node flag %q", name)
+		}
+	}
+	v := reflect.New(yamlType)
+	if err := unmarshal(v.Interface()); err != nil {
+		return err
+	}
+	*y = make(FlagsYAML)
+	for i, s := range yamlSpecs {
+		if f := v.Elem().Field(i); !f.IsNil() {
+			(*y)[s.Name] = f.Elem().Interface()
+		}
+	}
+	return nil
+}
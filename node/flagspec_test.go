@@ -0,0 +1,140 @@
+package node
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v2"
+)
+
+func TestFlagSpecs(t *testing.T) {
+	typ := reflect.TypeOf(Flags{})
+	if len(FlagSpecs) != typ.NumField() {
+		t.Fatalf("FlagSpecs has %d specs expected one per field of Flags (%d)", len(FlagSpecs), typ.NumField())
+	}
+	names := make(map[string]bool)
+	for i, s := range FlagSpecs {
+		field := typ.Field(i)
+		if s.Field != field.Name {
+			t.Fatalf("FlagSpecs[%d] is for field %s expected %s", i, s.Field, field.Name)
+		} else if reflect.TypeOf(s.Default) != field.Type {
+			t.Fatalf("flag %s has a default of type %T expected %s", s.Name, s.Default, field.Type)
+		} else if names[s.Name] {
+			t.Fatalf("flag %s is declared twice", s.Name)
+		}
+		names[s.Name] = true
+	}
+}
+
+// Returns flags whose values all differ from their defaults
+func testFlags() Flags {
+	var flags Flags
+	for _, s := range FlagSpecs {
+		v := s.value(&flags)
+		switch v.Kind() {
+		case reflect.String:
+			v.SetString("/" + s.Name)
+		case reflect.Bool:
+			v.SetBool(!s.Default.(bool))
+		case reflect.Uint:
+			v.SetUint(uint64(s.Default.(uint)) + 1)
+		case reflect.Int:
+			v.SetInt(int64(s.Default.(int)) + 1)
+		case reflect.Float64:
+			v.SetFloat(0.25)
+		}
+	}
+	return flags
+}
+
+func TestFlagsArgsRoundTrip(t *testing.T) {
+	var parsed Flags
+	fs := pflag.NewFlagSet("startnode", pflag.ContinueOnError)
+	RegisterFlags(fs, &parsed)
+	if !reflect.DeepEqual(parsed, DefaultFlags()) {
+		t.Fatalf("RegisterFlags set %+v expected %+v", parsed, DefaultFlags())
+	}
+
+	flags := testFlags()
+	args, md := FlagsToArgs(flags, "/stash/n1", true)
+	if err := fs.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	// Avash flags are not rendered, besides the data stash
+	flags.ClientLocation, flags.Meta, flags.DataDir = "", "", "/stash/n1"
+	if !reflect.DeepEqual(parsed, flags) {
+		t.Fatalf("parsing FlagsToArgs returned %+v expected %+v", parsed, flags)
+	}
+	if md.HTTPport != "9651" || md.Dbdir != "/db-dir" || md.StakerCertPath != "/staking-tls-cert-file" {
+		t.Fatalf("FlagsToArgs returned metadata %+v", md)
+	}
+}
+
+func TestFlagsToArgsPaths(t *testing.T) {
+	flags := DefaultFlags()
+	flags.StakingTLSCertFile = "certs/staker.crt"
+	args, md := FlagsToArgs(flags, "stash/n1", false)
+	if md.Dbdir != "stash/n1/db" || md.Logsdir != "stash/n1/logs" {
+		t.Fatalf("FlagsToArgs returned metadata %+v", md)
+	}
+	if !strings.HasSuffix(md.StakerCertPath, "/certs/staker.crt") || md.StakerCertPath[0] != '/' {
+		t.Fatalf("FlagsToArgs resolved cert file to %s", md.StakerCertPath)
+	}
+	for _, arg := range args {
+		if strings.HasSuffix(arg, "=") || strings.HasPrefix(arg, "--meta") || strings.HasPrefix(arg, "--data-dir") {
+			t.Fatalf("FlagsToArgs rendered %s", arg)
+		}
+	}
+
+	mounted := MountFlags(flags, "/data")
+	if mounted.DBDir != "/data/db" || mounted.StakingTLSCertFile != flags.StakingTLSCertFile || mounted.HTTPTLSKeyFile != "" {
+		t.Fatalf("MountFlags returned %+v", mounted)
+	}
+}
+
+func TestFlagsYAMLRoundTrip(t *testing.T) {
+	flags := testFlags()
+	doc := make(map[string]interface{})
+	for _, s := range FlagSpecs {
+		if !s.Avash {
+			doc[s.Name] = s.value(&flags).Interface()
+		}
+	}
+	data, err := yaml.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var y FlagsYAML
+	if err := yaml.Unmarshal(data, &y); err != nil {
+		t.Fatal(err)
+	}
+	flags.ClientLocation, flags.Meta, flags.DataDir = "", "", ""
+	if converted := ConvertYAML(y); !reflect.DeepEqual(converted, flags) {
+		t.Fatalf("ConvertYAML returned %+v expected %+v", converted, flags)
+	}
+
+	if converted := ConvertYAML(nil); !reflect.DeepEqual(converted, DefaultFlags()) {
+		t.Fatalf("ConvertYAML returned %+v expected the defaults", converted)
+	}
+}
+
+func TestFlagsYAMLErrors(t *testing.T) {
+	tests := []struct {
+		doc string
+		err string
+	}{
+		{"max-stake-duration: 8760h\nmax-validator-stakes: 5", `unknown node flag "max-validator-stakes"`},
+		{"meta: '{}'", `unknown node flag "meta"`},
+		{"http-port: -1", "cannot unmarshal"},
+		{"staking-enabled: maybe", "cannot unmarshal"},
+	}
+	for _, test := range tests {
+		var y FlagsYAML
+		err := yaml.Unmarshal([]byte(test.doc), &y)
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Fatalf("decoding %q returned error %v expected %q", test.doc, err, test.err)
+		}
+	}
+}
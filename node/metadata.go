@@ -14,4 +14,22 @@ type Metadata struct {
 	StakingEnabled bool   `json:"staking-enabled"`
 	StakerCertPath string `json:"staking-tls-cert-file"`
 	StakerKeyPath  string `json:"staking-tls-key-file"`
+	// Limits are the resource limits applied to the node process, if any
+	Limits map[string]string `json:"limits,omitempty"`
+	// Env are the environment variable overrides of the node process, as KEY=VALUE
+	Env []string `json:"env,omitempty"`
+	// ReservedPorts are the ports reserved by the node, which other nodes may not use
+	ReservedPorts []uint `json:"reserved-ports,omitempty"`
+	// ConfigFile is the config file the flags were written into with --config-mode=file, if any
+	ConfigFile string `json:"config-file,omitempty"`
+	// ClientVersion is the version of the client detected when the node started, if any
+	ClientVersion string `json:"client-version,omitempty"`
+	// NetworkID is the network the node connects to
+	NetworkID string `json:"network-id,omitempty"`
+	// NodeID is derived from the staking certificate, or else reported by the running node
+	NodeID string `json:"node-id,omitempty"`
+	// ExtraArgs are the arguments passed through to the node verbatim, unvalidated by avash
+	ExtraArgs []string `json:"unvalidated-args,omitempty"`
+	// WorkDir is the working directory of the node process, if not avash's
+	WorkDir string `json:"workdir,omitempty"`
 }
@@ -0,0 +1,51 @@
+package node
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/avalanchego/utils/constants"
+	"github.com/ava-labs/avalanchego/utils/hashing"
+)
+
+// CertNodeID returns the NodeID of a node staking with the DER encoded certificate
+func CertNodeID(der []byte) (string, error) {
+	id, err := ids.ToShortID(hashing.PubkeyBytesToAddress(der))
+	if err != nil {
+		return "", err
+	}
+	return id.PrefixedString(constants.NodeIDPrefix), nil
+}
+
+// ReadCertNodeID returns the NodeID of a node staking with the PEM encoded
+// certificate file at `path`, as the client derives it
+func ReadCertNodeID(path string) (string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return "", fmt.Errorf("%s: no PEM encoded certificate", path)
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return "", fmt.Errorf("%s: %s", path, err.Error())
+	}
+	return CertNodeID(cert.Raw)
+}
+
+// QueryNodeID returns the NodeID reported by the info API of the running node
+// described by `md`
+func QueryNodeID(md Metadata) (string, error) {
+	var res struct {
+		NodeID string
+	}
+	if err := probeCall(apiBase(md)+"/ext/info", "info.getNodeID", struct{}{}, &res); err != nil {
+		return "", fmt.Errorf("info API unavailable: %s", err.Error())
+	}
+	return res.NodeID, nil
+}
@@ -0,0 +1,31 @@
+package node
+
+import (
+	"strings"
+	"testing"
+)
+
+// testNodeID is the NodeID of the staking certificate of certs/keys1
+const testNodeID = "NodeID-7Xhw2mDxuDS44j42TCB6U5579esbSt3Lg"
+
+func TestReadCertNodeID(t *testing.T) {
+	if id, err := ReadCertNodeID("../certs/keys1/staker.crt"); err != nil || id != testNodeID {
+		t.Fatalf("ReadCertNodeID returned %s, %v expected %s", id, err, testNodeID)
+	}
+	if _, err := ReadCertNodeID("../certs/keys1/staker.key"); err == nil {
+		t.Fatalf("ReadCertNodeID returned no error for a private key")
+	}
+	path, cleanup := writeTestConfig(t, "cert")
+	defer cleanup()
+	if _, err := ReadCertNodeID(path); err == nil || !strings.Contains(err.Error(), "no PEM encoded certificate") {
+		t.Fatalf("ReadCertNodeID returned error %v expected no certificate", err)
+	}
+}
+
+func TestQueryNodeID(t *testing.T) {
+	server := newTestNode(true, nil)
+	defer server.Close()
+	if id, err := QueryNodeID(testMetadata(t, server.URL)); err != nil || id != testNodeID {
+		t.Fatalf("QueryNodeID returned %s, %v expected %s", id, err, testNodeID)
+	}
+}
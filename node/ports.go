@@ -0,0 +1,84 @@
+package node
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// PortRange is an inclusive range of TCP ports
+type PortRange struct {
+	Low, High uint
+}
+
+// ParsePortRange parses a range of the form `low-high`
+func ParsePortRange(s string) (PortRange, error) {
+	bounds := strings.SplitN(s, "-", 2)
+	if len(bounds) != 2 {
+		return PortRange{}, fmt.Errorf("invalid port range %q: should be of the form low-high", s)
+	}
+	low, lerr := strconv.ParseUint(strings.TrimSpace(bounds[0]), 10, 16)
+	high, herr := strconv.ParseUint(strings.TrimSpace(bounds[1]), 10, 16)
+	if lerr != nil || herr != nil || low == 0 || low > high {
+		return PortRange{}, fmt.Errorf("invalid port range %q: should be of the form low-high with 0 < low <= high <= 65535", s)
+	}
+	return PortRange{uint(low), uint(high)}, nil
+}
+
+func (r PortRange) String() string {
+	return fmt.Sprintf("%d-%d", r.Low, r.High)
+}
+
+// PortHeld returns true if a listener holds the TCP port
+func PortHeld(port uint) bool {
+	l, err := net.Listen("tcp", net.JoinHostPort("", strconv.FormatUint(uint64(port), 10)))
+	if err != nil {
+		return true
+	}
+	l.Close()
+	return false
+}
+
+// AllocatePorts returns the first pair of consecutive HTTP and staking ports of
+// the range that are neither reserved nor held by a listener. `reserved` maps
+// ports to the names of the nodes reserving them.
+func AllocatePorts(r PortRange, reserved map[uint]string) (uint, uint, error) {
+	for port := r.Low; port < r.High; port++ {
+		if CheckPorts([]uint{port, port + 1}, reserved) == nil {
+			return port, port + 1, nil
+		}
+	}
+	return 0, 0, fmt.Errorf("no free consecutive ports in range %s", r)
+}
+
+// CheckPorts returns an error describing every port reserved by another node
+// or held by a listener, or nil if all of them are free
+func CheckPorts(ports []uint, reserved map[uint]string) error {
+	var conflicts []string
+	for _, port := range ports {
+		if owner, ok := reserved[port]; ok {
+			conflicts = append(conflicts, fmt.Sprintf("port %d is reserved by node %s", port, owner))
+		} else if PortHeld(port) {
+			conflicts = append(conflicts, fmt.Sprintf("port %d is held by another listener", port))
+		}
+	}
+	if len(conflicts) == 0 {
+		return nil
+	}
+	return fmt.Errorf("port conflict: %s", strings.Join(conflicts, ", "))
+}
+
+// Ports returns the ports reserved by the node
+func (md Metadata) Ports() []uint {
+	if len(md.ReservedPorts) > 0 {
+		return md.ReservedPorts
+	}
+	var ports []uint
+	for _, s := range []string{md.HTTPport, md.Stakingport} {
+		if port, err := strconv.ParseUint(s, 10, 16); err == nil {
+			ports = append(ports, uint(port))
+		}
+	}
+	return ports
+}
@@ -0,0 +1,88 @@
+package node
+
+import (
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParsePortRange(t *testing.T) {
+	tests := []struct {
+		in  string
+		out PortRange
+		ok  bool
+	}{
+		{"9650-9999", PortRange{9650, 9999}, true},
+		{"9650 - 9651", PortRange{9650, 9651}, true},
+		{"9650", PortRange{}, false},
+		{"9999-9650", PortRange{}, false},
+		{"0-10", PortRange{}, false},
+		{"9650-70000", PortRange{}, false},
+	}
+	for _, test := range tests {
+		r, err := ParsePortRange(test.in)
+		if (err == nil) != test.ok {
+			t.Fatalf("ParsePortRange(%q) returned error %v", test.in, err)
+		} else if r != test.out {
+			t.Fatalf("ParsePortRange(%q) returned %s expected %s", test.in, r, test.out)
+		}
+	}
+}
+
+// Listens on a free port, returning it and a function to release it
+func holdPort(t *testing.T) (uint, func()) {
+	l, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return uint(l.Addr().(*net.TCPAddr).Port), func() { l.Close() }
+}
+
+func TestAllocatePorts(t *testing.T) {
+	held, release := holdPort(t)
+	defer release()
+	if !PortHeld(held) {
+		t.Fatalf("PortHeld(%d) returned false for a held port", held)
+	}
+
+	// The first pair overlaps the held port, the second a reserved one
+	r := PortRange{held - 1, held + 10}
+	reserved := map[uint]string{held + 2: "n1"}
+	http, staking, err := AllocatePorts(r, reserved)
+	if err != nil {
+		t.Skipf("ports near %d in use: %s", held, err)
+	}
+	if http <= held+2 || staking != http+1 {
+		t.Fatalf("AllocatePorts returned %d, %d overlapping %d or %d", http, staking, held, held+2)
+	}
+
+	if _, _, err := AllocatePorts(PortRange{held, held + 1}, nil); err == nil {
+		t.Fatalf("AllocatePorts returned no error for a range without a free pair")
+	}
+}
+
+func TestCheckPorts(t *testing.T) {
+	held, release := holdPort(t)
+	defer release()
+	err := CheckPorts([]uint{held, held + 1000}, map[uint]string{held + 1000: "n1"})
+	if err == nil {
+		t.Fatalf("CheckPorts returned no error for conflicting ports")
+	}
+	for _, s := range []string{"held by another listener", "reserved by node n1"} {
+		if !strings.Contains(err.Error(), s) {
+			t.Fatalf("CheckPorts returned %q expected it to report %q", err, s)
+		}
+	}
+}
+
+func TestMetadataPorts(t *testing.T) {
+	md := Metadata{HTTPport: "9650", Stakingport: "9651"}
+	if ports := md.Ports(); !reflect.DeepEqual(ports, []uint{9650, 9651}) {
+		t.Fatalf("MD.Ports returned %v expected %v", ports, []uint{9650, 9651})
+	}
+	md.ReservedPorts = []uint{9700, 9701}
+	if ports := md.Ports(); !reflect.DeepEqual(ports, []uint{9700, 9701}) {
+		t.Fatalf("MD.Ports returned %v expected %v", ports, []uint{9700, 9701})
+	}
+}
@@ -0,0 +1,129 @@
+package node
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ybbus/jsonrpc"
+)
+
+// Readiness is a stage of node startup, each implying the previous ones
+type Readiness int
+
+// Enum ...
+const (
+	// Listening nodes accept connections on their HTTP port
+	Listening Readiness = iota
+	// Healthy nodes report healthy through the health API
+	Healthy
+	// Bootstrapped nodes have bootstrapped every chain of BootstrapChains
+	Bootstrapped
+)
+
+// ToReadiness ...
+func ToReadiness(s string) (Readiness, error) {
+	switch strings.ToLower(s) {
+	case "listening":
+		return Listening, nil
+	case "healthy":
+		return Healthy, nil
+	case "bootstrapped":
+		return Bootstrapped, nil
+	default:
+		return Listening, fmt.Errorf("unknown readiness: %s. Should be one of {listening, healthy, bootstrapped}", s)
+	}
+}
+
+func (r Readiness) String() string {
+	switch r {
+	case Listening:
+		return "listening"
+	case Healthy:
+		return "healthy"
+	case Bootstrapped:
+		return "bootstrapped"
+	default:
+		return "?????"
+	}
+}
+
+// BootstrapChains are the chains checked by the Bootstrapped probe
+var BootstrapChains = []string{"P", "X", "C"}
+
+// probeTimeout bounds every request of a probe
+const probeTimeout = 5 * time.Second
+
+// probeClient skips certificate verification, as local nodes serve self-signed certificates
+var probeClient = &http.Client{
+	Timeout: probeTimeout,
+	Transport: &http.Transport{
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	},
+}
+
+// Probe returns nil if the node described by `md` has reached readiness `until`,
+// or else the reason it has not
+func Probe(md Metadata, until Readiness) error {
+	addr := net.JoinHostPort(md.Serverhost, md.HTTPport)
+	conn, err := net.DialTimeout("tcp", addr, probeTimeout)
+	if err != nil {
+		return fmt.Errorf("HTTP port not accepting connections: %s", err.Error())
+	}
+	conn.Close()
+	if until < Healthy {
+		return nil
+	}
+
+	base := apiBase(md)
+	var health struct {
+		Healthy bool
+	}
+	if err := probeCall(base+"/ext/health", "health.getLiveness", struct{}{}, &health); err != nil {
+		return fmt.Errorf("health API unavailable: %s", err.Error())
+	} else if !health.Healthy {
+		return fmt.Errorf("health API reports unhealthy")
+	}
+	if until < Bootstrapped {
+		return nil
+	}
+
+	for _, chain := range BootstrapChains {
+		var res struct {
+			IsBootstrapped bool
+		}
+		params := struct {
+			Chain string `json:"chain"`
+		}{chain}
+		if err := probeCall(base+"/ext/info", "info.isBootstrapped", params, &res); err != nil {
+			return fmt.Errorf("info API unavailable: %s", err.Error())
+		} else if !res.IsBootstrapped {
+			return fmt.Errorf("chain %s not bootstrapped", chain)
+		}
+	}
+	return nil
+}
+
+// apiBase returns the base URL of the APIs of the node
+func apiBase(md Metadata) string {
+	addr := net.JoinHostPort(md.Serverhost, md.HTTPport)
+	if md.HTTPTLS {
+		return "https://" + addr
+	}
+	return "http://" + addr
+}
+
+// probeCall issues the JSON RPC, decoding its result into `res`
+func probeCall(endpoint, method string, params, res interface{}) error {
+	client := jsonrpc.NewClientWithOpts(endpoint, &jsonrpc.RPCClientOpts{HTTPClient: probeClient})
+	response, err := client.Call(method, params)
+	if err != nil {
+		return err
+	} else if response.Error != nil {
+		return fmt.Errorf("%d, %s", response.Error.Code, response.Error.Message)
+	}
+	return response.GetObject(res)
+}
@@ -0,0 +1,94 @@
+package node
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// Serves the health and info APIs with the given health and bootstrapped chains
+func newTestNode(healthy bool, bootstrapped map[string]bool) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     int
+			Method string
+			Params struct {
+				Chain string
+			}
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		var result interface{}
+		switch {
+		case r.URL.Path == "/ext/health" && req.Method == "health.getLiveness":
+			result = map[string]interface{}{"healthy": healthy}
+		case r.URL.Path == "/ext/info" && req.Method == "info.isBootstrapped":
+			result = map[string]interface{}{"isBootstrapped": bootstrapped[req.Params.Chain]}
+		case r.URL.Path == "/ext/info" && req.Method == "info.getNodeID":
+			result = map[string]interface{}{"nodeID": testNodeID}
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"jsonrpc": "2.0", "id": req.ID, "result": result})
+	}))
+}
+
+func testMetadata(t *testing.T, url string) Metadata {
+	host, port, err := net.SplitHostPort(strings.TrimPrefix(url, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Metadata{Serverhost: host, HTTPport: port}
+}
+
+func TestProbe(t *testing.T) {
+	all := map[string]bool{"P": true, "X": true, "C": true}
+	tests := []struct {
+		name         string
+		healthy      bool
+		bootstrapped map[string]bool
+		until        Readiness
+		reason       string
+	}{
+		{"Listening", false, nil, Listening, ""},
+		{"Unhealthy", false, nil, Healthy, "unhealthy"},
+		{"Healthy", true, nil, Healthy, ""},
+		{"Bootstrapping", true, map[string]bool{"P": true}, Bootstrapped, "chain X not bootstrapped"},
+		{"Bootstrapped", true, all, Bootstrapped, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			srv := newTestNode(test.healthy, test.bootstrapped)
+			defer srv.Close()
+			err := Probe(testMetadata(t, srv.URL), test.until)
+			if test.reason == "" && err != nil {
+				t.Fatalf("Probe returned %s expected ready", err)
+			} else if test.reason != "" && (err == nil || !strings.Contains(err.Error(), test.reason)) {
+				t.Fatalf("Probe returned %v expected %q", err, test.reason)
+			}
+		})
+	}
+
+	t.Run("Closed", func(t *testing.T) {
+		srv := newTestNode(true, all)
+		md := testMetadata(t, srv.URL)
+		srv.Close()
+		if err := Probe(md, Listening); err == nil || !strings.Contains(err.Error(), "not accepting connections") {
+			t.Fatalf("Probe returned %v for a closed port", err)
+		}
+	})
+}
+
+func TestToReadiness(t *testing.T) {
+	for _, r := range []Readiness{Listening, Healthy, Bootstrapped} {
+		if parsed, err := ToReadiness(r.String()); err != nil || parsed != r {
+			t.Fatalf("ToReadiness returned %s, %v expected %s", parsed, err, r)
+		}
+	}
+	if _, err := ToReadiness("ready"); err == nil {
+		t.Fatalf("ToReadiness returned no error for %q", "ready")
+	}
+}
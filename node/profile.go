@@ -0,0 +1,113 @@
+package node
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// ProfileInheritsKey is the key of a profile naming the profile it inherits from
+const ProfileInheritsKey = "inherits"
+
+// Profile is a named set of node flags, defined in the avash config file
+type Profile struct {
+	// Inherits is the name of the profile whose flags this one overrides, if any
+	Inherits string    `json:"inherits,omitempty" yaml:"inherits,omitempty"`
+	Flags    FlagsYAML `json:"flags" yaml:"flags"`
+}
+
+// OverrideFlags returns the flags with those of `over` taking precedence
+func OverrideFlags(flags FlagsYAML, over FlagsYAML) FlagsYAML {
+	result := make(FlagsYAML, len(flags)+len(over))
+	for name, value := range flags {
+		result[name] = value
+	}
+	for name, value := range over {
+		result[name] = value
+	}
+	return result
+}
+
+// PickFlags returns the values of the named node flags
+func PickFlags(flags Flags, names []string) FlagsYAML {
+	result := make(FlagsYAML)
+	for _, name := range names {
+		if s, ok := LookupFlag(name); ok && !s.Avash {
+			result[name] = s.value(&flags).Interface()
+		}
+	}
+	return result
+}
+
+// ParseProfile decodes a profile from its value in the config file, failing
+// on unknown flags and values of the wrong type
+func ParseProfile(raw map[string]interface{}) (Profile, error) {
+	var p Profile
+	values := make(map[string]interface{})
+	for key, value := range raw {
+		if key != ProfileInheritsKey {
+			values[key] = value
+			continue
+		}
+		parent, ok := value.(string)
+		if !ok {
+			return Profile{}, fmt.Errorf("%s should name a profile, not %v", ProfileInheritsKey, value)
+		}
+		p.Inherits = parent
+	}
+	data, err := yaml.Marshal(values)
+	if err != nil {
+		return Profile{}, err
+	}
+	if err := yaml.Unmarshal(data, &p.Flags); err != nil {
+		return Profile{}, err
+	}
+	return p, nil
+}
+
+// ProfileChain returns the names of the profiles the named one inherits from,
+// from the named profile to its furthest ancestor
+func ProfileChain(profiles map[string]Profile, name string) ([]string, error) {
+	var chain []string
+	seen := make(map[string]bool)
+	for n := name; n != ""; n = profiles[n].Inherits {
+		if seen[n] {
+			return nil, fmt.Errorf("profile %s inherits from itself: %s", n, strings.Join(append(chain, n), " -> "))
+		}
+		if _, ok := profiles[n]; !ok {
+			if n == name {
+				return nil, fmt.Errorf("unknown profile %q", n)
+			}
+			return nil, fmt.Errorf("profile %s inherits from unknown profile %q", chain[len(chain)-1], n)
+		}
+		seen[n] = true
+		chain = append(chain, n)
+	}
+	return chain, nil
+}
+
+// ResolveProfile returns the flags of the named profile, overriding those of
+// the profiles it inherits from
+func ResolveProfile(profiles map[string]Profile, name string) (FlagsYAML, error) {
+	chain, err := ProfileChain(profiles, name)
+	if err != nil {
+		return nil, err
+	}
+	var flags FlagsYAML
+	for i := len(chain) - 1; i >= 0; i-- {
+		flags = OverrideFlags(flags, profiles[chain[i]].Flags)
+	}
+	return flags, nil
+}
+
+// ProfileNames returns the sorted names of the profiles
+func ProfileNames(profiles map[string]Profile) []string {
+	var names []string
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,75 @@
+package node
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestResolveProfile(t *testing.T) {
+	raw := map[string]map[string]interface{}{
+		"staker":  {"staking-enabled": true, "http-port": 9700, "log-level": "debug"},
+		"archive": {"inherits": "staker", "http-port": 9710, "index-enabled": true},
+	}
+	profiles := make(map[string]Profile)
+	for name, r := range raw {
+		p, err := ParseProfile(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		profiles[name] = p
+	}
+	if profiles["archive"].Inherits != "staker" || profiles["staker"].Flags["http-port"] != uint(9700) {
+		t.Fatalf("ParseProfile returned %+v", profiles)
+	}
+
+	flags, err := ResolveProfile(profiles, "archive")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := FlagsYAML{"staking-enabled": true, "http-port": uint(9710), "log-level": "debug", "index-enabled": true}
+	if !reflect.DeepEqual(flags, expected) {
+		t.Fatalf("ResolveProfile returned %v expected %v", flags, expected)
+	}
+
+	// Flags given on the command line override the profile
+	cli := DefaultFlags()
+	cli.HTTPPort, cli.LogLevel = 9652, "warn"
+	merged := ConvertYAML(OverrideFlags(flags, PickFlags(cli, []string{"http-port", "client-location"})))
+	if merged.HTTPPort != 9652 || merged.LogLevel != "debug" || !merged.IndexEnabled || merged.StakingPort != DefaultFlags().StakingPort {
+		t.Fatalf("overriding the profile returned %+v", merged)
+	}
+	if flags["http-port"] != uint(9710) {
+		t.Fatalf("OverrideFlags modified the profile flags")
+	}
+}
+
+func TestProfileErrors(t *testing.T) {
+	for _, test := range []struct {
+		raw map[string]interface{}
+		msg string
+	}{
+		{map[string]interface{}{"http-prot": 9650}, `unknown node flag "http-prot"`},
+		{map[string]interface{}{"data-dir": "/tmp"}, `unknown node flag "data-dir"`},
+		{map[string]interface{}{"inherits": 1}, "should name a profile"},
+	} {
+		if _, err := ParseProfile(test.raw); err == nil || !strings.Contains(err.Error(), test.msg) {
+			t.Fatalf("ParseProfile(%v) returned error %v expected %q", test.raw, err, test.msg)
+		}
+	}
+
+	profiles := map[string]Profile{
+		"a":      {Inherits: "b"},
+		"b":      {Inherits: "a"},
+		"orphan": {Inherits: "missing"},
+	}
+	for name, msg := range map[string]string{
+		"a":       "profile a inherits from itself: a -> b -> a",
+		"orphan":  `profile orphan inherits from unknown profile "missing"`,
+		"unknown": `unknown profile "unknown"`,
+	} {
+		if _, err := ResolveProfile(profiles, name); err == nil || err.Error() != msg {
+			t.Fatalf("ResolveProfile(%s) returned error %v expected %q", name, err, msg)
+		}
+	}
+}
@@ -0,0 +1,308 @@
+package node
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FlagRule constrains the values of a node flag. Empty string values are not
+// passed to the node, so are not checked.
+type FlagRule struct {
+	// Duration values parse as durations, or integers of nanoseconds
+	Duration bool
+	// Positive durations and numbers are greater than zero
+	Positive bool
+	// Min and Max bound numbers, unless nil
+	Min, Max *float64
+	// Values are the accepted values, compared case-insensitively, unless empty
+	Values []string
+	// File values name a readable file
+	File bool
+}
+
+func bound(v float64) *float64 {
+	return &v
+}
+
+var logLevels = []string{"verbo", "debug", "info", "warn", "error", "fatal", "off"}
+
+// FlagRules maps the node flags with constrained values to their rules. The
+// constraints between flags are checked by ValidateFlags.
+var FlagRules = map[string]FlagRule{
+	"dynamic-update-duration":                    {Duration: true, Positive: true},
+	"dynamic-public-ip":                          {Values: []string{"opendns", "ifconfigco", "ifconfigme"}},
+	"http-port":                                  {Min: bound(1), Max: bound(65535)},
+	"http-tls-cert-file":                         {File: true},
+	"http-tls-key-file":                          {File: true},
+	"log-level":                                  {Values: logLevels},
+	"log-display-level":                          {Values: logLevels},
+	"log-display-highlight":                      {Values: []string{"auto", "plain", "colors"}},
+	"snow-avalanche-batch-size":                  {Positive: true},
+	"snow-avalanche-num-parents":                 {Positive: true},
+	"snow-epoch-duration":                        {Duration: true, Positive: true},
+	"snow-concurrent-repolls":                    {Min: bound(1)},
+	"min-delegator-stake":                        {Min: bound(0)},
+	"consensus-shutdown-timeout":                 {Duration: true, Positive: true},
+	"consensus-gossip-frequency":                 {Duration: true, Positive: true},
+	"min-delegation-fee":                         {Min: bound(0), Max: bound(1000000)},
+	"min-validator-stake":                        {Min: bound(0)},
+	"max-stake-duration":                         {Duration: true, Positive: true},
+	"max-validator-stake":                        {Min: bound(0)},
+	"creation-tx-fee":                            {Min: bound(0)},
+	"stake-minting-period":                       {Duration: true, Positive: true},
+	"staking-port":                               {Min: bound(1), Max: bound(65535)},
+	"staking-disabled-weight":                    {Min: bound(0)},
+	"staking-tls-key-file":                       {File: true},
+	"staking-tls-cert-file":                      {File: true},
+	"api-auth-password-file":                     {File: true},
+	"min-stake-duration":                         {Duration: true, Positive: true},
+	"config-file":                                {File: true},
+	"conn-meter-max-conns":                       {Min: bound(0)},
+	"conn-meter-reset-duration":                  {Duration: true},
+	"fd-limit":                                   {Positive: true},
+	"benchlist-fail-threshold":                   {Positive: true},
+	"benchlist-min-failing-duration":             {Duration: true},
+	"benchlist-duration":                         {Duration: true},
+	"max-non-staker-pending-msgs":                {Min: bound(0)},
+	"network-initial-timeout":                    {Duration: true, Positive: true},
+	"network-minimum-timeout":                    {Duration: true, Positive: true},
+	"network-maximum-timeout":                    {Duration: true, Positive: true},
+	"network-health-max-send-fail-rate":          {Min: bound(0), Max: bound(1)},
+	"network-health-max-portion-send-queue-full": {Min: bound(0), Max: bound(1)},
+	"network-health-max-time-since-msg-sent":     {Duration: true, Positive: true},
+	"network-health-max-time-since-msg-received": {Duration: true, Positive: true},
+	"network-health-min-conn-peers":              {Min: bound(0)},
+	"network-timeout-coefficient":                {Min: bound(1)},
+	"network-timeout-halflife":                   {Duration: true, Positive: true},
+	"disconnected-check-frequency":               {Duration: true, Positive: true},
+	"disconnected-restart-timeout":               {Duration: true, Positive: true},
+	"uptime-requirement":                         {Min: bound(0), Max: bound(1)},
+	"bootstrap-retry-max-attempts":               {Min: bound(0)},
+	"health-check-averager-halflife":             {Duration: true, Positive: true},
+	"health-check-frequency":                     {Duration: true, Positive: true},
+	"router-health-max-outstanding-requests":     {Positive: true},
+	"router-health-max-drop-rate":                {Min: bound(0), Max: bound(1)},
+}
+
+// Validation is the context in which node flags are validated
+type Validation struct {
+	// WorkDir is the working directory of the node, that of avash if empty
+	WorkDir string
+	// Remote nodes are deployed to another host. Of their files, only those
+	// copied there from the working directory of avash are checked.
+	Remote bool
+	// Reserved maps the ports reserved by other nodes to the names of the nodes
+	Reserved map[uint]string
+}
+
+// Violation is a node flag with an invalid value
+type Violation struct {
+	Flag    string `json:"flag" yaml:"flag"`
+	Message string `json:"message" yaml:"message"`
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("--%s %s", v.Flag, v.Message)
+}
+
+// ValidationError lists every violation found by ValidateFlags
+type ValidationError []Violation
+
+func (e ValidationError) Error() string {
+	lines := make([]string, len(e))
+	for i, v := range e {
+		lines[i] = v.String()
+	}
+	return "Invalid node flags:\n" + strings.Join(lines, "\n")
+}
+
+// ValidateFlags checks the flags against their rules, the constraints between
+// flags and the reserved ports, returning a ValidationError of every
+// violation or nil if the flags are valid
+func ValidateFlags(flags Flags, v Validation) error {
+	var violations ValidationError
+	report := func(flag string, format string, args ...interface{}) {
+		violations = append(violations, Violation{Flag: flag, Message: fmt.Sprintf(format, args...)})
+	}
+	durations := make(map[string]time.Duration)
+	for _, s := range FlagSpecs {
+		rule, ok := FlagRules[s.Name]
+		if !ok || s.Avash {
+			continue
+		}
+		value := s.value(&flags).Interface()
+		if str, ok := value.(string); ok && str == "" {
+			continue
+		}
+		if rule.Duration {
+			d, err := parseDuration(value.(string))
+			if err != nil {
+				report(s.Name, "is not a duration: %q", value)
+				continue
+			}
+			durations[s.Name] = d
+			if rule.Positive && d <= 0 {
+				report(s.Name, "must be greater than 0, not %s", value)
+			}
+			continue
+		}
+		if len(rule.Values) > 0 && !containsFold(rule.Values, value.(string)) {
+			report(s.Name, "must be one of {%s}, not %q", strings.Join(rule.Values, ", "), value)
+		}
+		if rule.File && (!v.Remote || s.Path == PathWorkDir) {
+			if err := checkFile(flagPath(s, value.(string), v.WorkDir)); err != nil {
+				report(s.Name, "%s", err.Error())
+			}
+		}
+		if n, ok := number(value); ok {
+			switch {
+			case rule.Positive && n <= 0:
+				report(s.Name, "must be greater than 0, not %v", value)
+			case rule.Min != nil && n < *rule.Min:
+				report(s.Name, "must be at least %s, not %v", strconv.FormatFloat(*rule.Min, 'f', -1, 64), value)
+			case rule.Max != nil && n > *rule.Max:
+				report(s.Name, "must be at most %s, not %v", strconv.FormatFloat(*rule.Max, 'f', -1, 64), value)
+			}
+		}
+	}
+
+	// Consensus parameters
+	k, alpha := flags.SnowSampleSize, flags.SnowQuorumSize
+	beta1, beta2 := flags.SnowVirtuousCommitThreshold, flags.SnowRogueCommitThreshold
+	if k <= 0 {
+		report("snow-sample-size", "must be greater than 0, not %d", k)
+	}
+	if alpha > k {
+		report("snow-quorum-size", "must be at most --snow-sample-size (%d), not %d", k, alpha)
+	}
+	if k/2 >= alpha {
+		report("snow-quorum-size", "must be greater than half of --snow-sample-size (%d), not %d", k, alpha)
+	}
+	if beta1 <= 0 {
+		report("snow-virtuous-commit-threshold", "must be greater than 0, not %d", beta1)
+	}
+	if beta1 > beta2 {
+		report("snow-rogue-commit-threshold", "must be at least --snow-virtuous-commit-threshold (%d), not %d", beta1, beta2)
+	}
+	if repolls := flags.SnowConcurrentRepolls; repolls > beta2 {
+		report("snow-concurrent-repolls", "must be at most --snow-rogue-commit-threshold (%d), not %d", beta2, repolls)
+	}
+
+	// Staking
+	if flags.MinValidatorStake > flags.MaxValidatorStake {
+		report("min-validator-stake", "must be at most --max-validator-stake (%d), not %d", flags.MaxValidatorStake, flags.MinValidatorStake)
+	}
+	if min, max, ok := durationPair(durations, "min-stake-duration", "max-stake-duration"); ok && min > max {
+		report("min-stake-duration", "must be at most --max-stake-duration (%s), not %s", max, min)
+	}
+	if flags.StakingEnabled && (flags.StakingTLSCertFile == "" || flags.StakingTLSKeyFile == "") {
+		report("staking-enabled", "requires --staking-tls-cert-file and --staking-tls-key-file")
+	} else if (flags.StakingTLSCertFile == "") != (flags.StakingTLSKeyFile == "") {
+		report("staking-tls-cert-file", "and --staking-tls-key-file must be given together")
+	}
+
+	// HTTP
+	if flags.HTTPTLSEnabled && (flags.HTTPTLSCertFile == "" || flags.HTTPTLSKeyFile == "") {
+		report("http-tls-enabled", "requires --http-tls-cert-file and --http-tls-key-file")
+	}
+	if flags.APIAuthRequired && flags.APIAuthPasswordFileKey == "" {
+		report("api-auth-required", "requires --api-auth-password-file")
+	}
+
+	// Network timeouts
+	if min, initial, ok := durationPair(durations, "network-minimum-timeout", "network-initial-timeout"); ok && min > initial {
+		report("network-initial-timeout", "must be at least --network-minimum-timeout (%s), not %s", min, initial)
+	}
+	if initial, max, ok := durationPair(durations, "network-initial-timeout", "network-maximum-timeout"); ok && initial > max {
+		report("network-initial-timeout", "must be at most --network-maximum-timeout (%s), not %s", max, initial)
+	}
+
+	// Ports
+	if flags.HTTPPort == flags.StakingPort {
+		report("staking-port", "must differ from --http-port (%d)", flags.HTTPPort)
+	}
+	if owner, ok := v.Reserved[flags.HTTPPort]; ok {
+		report("http-port", "%d is reserved by node %s", flags.HTTPPort, owner)
+	}
+	if owner, ok := v.Reserved[flags.StakingPort]; ok {
+		report("staking-port", "%d is reserved by node %s", flags.StakingPort, owner)
+	}
+
+	if len(violations) == 0 {
+		return nil
+	}
+	return violations
+}
+
+// parseDuration parses a duration as the client does, as an integer of
+// nanoseconds or a string such as `5m`
+func parseDuration(s string) (time.Duration, error) {
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Duration(n), nil
+	}
+	return time.ParseDuration(s)
+}
+
+// durationPair returns the parsed durations of the two flags, if both parsed
+func durationPair(durations map[string]time.Duration, a, b string) (time.Duration, time.Duration, bool) {
+	da, aok := durations[a]
+	db, bok := durations[b]
+	return da, db, aok && bok
+}
+
+// number returns the value of a numeric flag as a float
+func number(value interface{}) (float64, bool) {
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.Float64:
+		return v.Float(), true
+	}
+	return 0, false
+}
+
+// flagPath returns the path of the file named by the flag, as resolved by
+// FlagsToArgs or else by the node from its working directory
+func flagPath(s FlagSpec, path string, workdir string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	if s.Path == PathWorkDir || workdir == "" {
+		wd, _ := os.Getwd()
+		return filepath.Join(wd, path)
+	}
+	return filepath.Join(workdir, path)
+}
+
+// checkFile returns an error unless the path names a readable file
+func checkFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("names a missing file: %s", path)
+		}
+		return fmt.Errorf("names an unreadable file: %s", err.Error())
+	}
+	defer f.Close()
+	if info, err := f.Stat(); err == nil && info.IsDir() {
+		return fmt.Errorf("names a directory, not a file: %s", path)
+	}
+	return nil
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,68 @@
+package node
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestFlagRules(t *testing.T) {
+	for name, rule := range FlagRules {
+		s, ok := LookupFlag(name)
+		if !ok || s.Avash {
+			t.Fatalf("FlagRules has %s, not a node flag", name)
+		}
+		if _, isString := s.Default.(string); (rule.Duration || rule.File || len(rule.Values) > 0) != isString {
+			t.Fatalf("FlagRules has a rule of %s not applying to its type", name)
+		}
+	}
+	if err := ValidateFlags(DefaultFlags(), Validation{}); err != nil {
+		t.Fatalf("ValidateFlags of the default flags returned %v", err)
+	}
+}
+
+func TestValidateFlags(t *testing.T) {
+	cert, cleanup := writeTestConfig(t, "cert")
+	defer cleanup()
+	dir := filepath.Dir(cert)
+
+	flags := DefaultFlags()
+	flags.NetworkInitialTimeout = "5 seconds"
+	flags.NetworkMaximumTimeout = "1s"
+	flags.MinDelegationFee = 1000001
+	flags.LogLevel = "loud"
+	flags.SnowConcurrentRepolls = 11
+	flags.StakingEnabled = true
+	flags.StakingTLSCertFile = cert
+	flags.APIAuthPasswordFileKey = "password"
+	flags.StakingPort = 9700
+	err := ValidateFlags(flags, Validation{WorkDir: dir, Reserved: map[uint]string{9650: "n1"}})
+	expected := ValidationError{
+		{"log-level", `must be one of {verbo, debug, info, warn, error, fatal, off}, not "loud"`},
+		{"min-delegation-fee", "must be at most 1000000, not 1000001"},
+		{"api-auth-password-file", "names a missing file: " + filepath.Join(dir, "password")},
+		{"network-initial-timeout", `is not a duration: "5 seconds"`},
+		{"snow-concurrent-repolls", "must be at most --snow-rogue-commit-threshold (10), not 11"},
+		{"staking-enabled", "requires --staking-tls-cert-file and --staking-tls-key-file"},
+		{"http-port", "9650 is reserved by node n1"},
+	}
+	if !reflect.DeepEqual(err, expected) {
+		t.Fatalf("ValidateFlags returned %v expected %v", err, expected)
+	}
+
+	// The files of remote nodes are checked only if copied from the working directory of avash
+	flags = DefaultFlags()
+	flags.NetworkMinimumTimeout, flags.NetworkInitialTimeout = "6s", "5000000000"
+	flags.StakingPort = flags.HTTPPort
+	flags.APIAuthRequired, flags.APIAuthPasswordFileKey = true, "/data/password"
+	flags.HTTPTLSCertFile = filepath.Join(dir, "missing.crt")
+	err = ValidateFlags(flags, Validation{Remote: true})
+	expected = ValidationError{
+		{"http-tls-cert-file", "names a missing file: " + filepath.Join(dir, "missing.crt")},
+		{"network-initial-timeout", "must be at least --network-minimum-timeout (6s), not 5s"},
+		{"staking-port", "must differ from --http-port (9650)"},
+	}
+	if !reflect.DeepEqual(err, expected) {
+		t.Fatalf("ValidateFlags returned %v expected %v", err, expected)
+	}
+}
@@ -0,0 +1,126 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ProbeVersionTimeout bounds the run of the client printing its version
+const ProbeVersionTimeout = 5 * time.Second
+
+// ClientVersion is the version of an avalanchego client, zero if unknown
+type ClientVersion struct {
+	Major, Minor, Patch int
+}
+
+var versionPattern = regexp.MustCompile(`(\d+)\.(\d+)\.(\d+)`)
+
+// ParseClientVersion parses the first version in the output of `--version`,
+// e.g. `avalanche/1.0.3`
+func ParseClientVersion(s string) (ClientVersion, error) {
+	m := versionPattern.FindStringSubmatch(s)
+	if m == nil {
+		return ClientVersion{}, fmt.Errorf("no client version in %q", s)
+	}
+	var parts [3]int
+	for i := range parts {
+		n, err := strconv.Atoi(m[i+1])
+		if err != nil {
+			return ClientVersion{}, fmt.Errorf("invalid client version %q: %s", m[0], err.Error())
+		}
+		parts[i] = n
+	}
+	return ClientVersion{parts[0], parts[1], parts[2]}, nil
+}
+
+// MustParseClientVersion parses the version like ParseClientVersion, panicking if it is invalid
+func MustParseClientVersion(s string) ClientVersion {
+	v, err := ParseClientVersion(s)
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+func (v ClientVersion) String() string {
+	if v.IsZero() {
+		return "unknown"
+	}
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+// IsZero returns true if the version is unknown
+func (v ClientVersion) IsZero() bool {
+	return v == ClientVersion{}
+}
+
+// Before returns true if the version precedes `o`
+func (v ClientVersion) Before(o ClientVersion) bool {
+	if v.Major != o.Major {
+		return v.Major < o.Major
+	}
+	if v.Minor != o.Minor {
+		return v.Minor < o.Minor
+	}
+	return v.Patch < o.Patch
+}
+
+// probed caches the versions of the clients by path, as long as they are unmodified
+var probed = struct {
+	sync.Mutex
+	versions map[string]probedVersion
+}{versions: make(map[string]probedVersion)}
+
+type probedVersion struct {
+	modTime time.Time
+	version ClientVersion
+}
+
+// ProbeClientVersion runs the client at `location` with `--version` from the
+// directory `dir` with the environment overrides `env`, and returns the
+// version it prints
+func ProbeClientVersion(location string, dir string, env []string) (ClientVersion, error) {
+	path := location
+	if !filepath.IsAbs(path) && filepath.Base(path) != path {
+		path = filepath.Join(dir, path)
+	}
+	if lp, err := exec.LookPath(path); err == nil {
+		path = lp
+	}
+	info, statErr := os.Stat(path)
+	if statErr == nil {
+		probed.Lock()
+		cached, ok := probed.versions[path]
+		probed.Unlock()
+		if ok && cached.modTime.Equal(info.ModTime()) {
+			return cached.version, nil
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), ProbeVersionTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, path, "--version")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), env...)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return ClientVersion{}, fmt.Errorf("unable to probe the version of %s: %s", location, err.Error())
+	}
+	v, err := ParseClientVersion(string(out))
+	if err != nil {
+		return ClientVersion{}, err
+	}
+	if statErr == nil {
+		probed.Lock()
+		probed.versions[path] = probedVersion{info.ModTime(), v}
+		probed.Unlock()
+	}
+	return v, nil
+}
@@ -0,0 +1,85 @@
+package node
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseClientVersion(t *testing.T) {
+	tests := []struct {
+		in  string
+		out ClientVersion
+		ok  bool
+	}{
+		{"avalanche/1.0.3\n", ClientVersion{1, 0, 3}, true},
+		{"avalanche/1.4.10 [database=v1.0.0, commit=abc]", ClientVersion{1, 4, 10}, true},
+		{"flag provided but not defined: -version", ClientVersion{}, false},
+	}
+	for _, test := range tests {
+		v, err := ParseClientVersion(test.in)
+		if (err == nil) != test.ok {
+			t.Fatalf("ParseClientVersion(%q) returned error %v", test.in, err)
+		} else if v != test.out {
+			t.Fatalf("ParseClientVersion(%q) returned %s expected %s", test.in, v, test.out)
+		}
+	}
+	if v := (ClientVersion{1, 4, 5}); !v.Before(ClientVersion{1, 10, 0}) || v.Before(ClientVersion{1, 4, 5}) {
+		t.Fatalf("CV.Before misorders %s", v)
+	}
+}
+
+func TestProbeClientVersion(t *testing.T) {
+	dir, err := ioutil.TempDir("", "avash-version")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	client := filepath.Join(dir, "avalanchego")
+	script := "#!/bin/sh\n[ \"$1\" = --version ] && echo \"avalanche/$CLIENT_VERSION\"\n"
+	if err := ioutil.WriteFile(client, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	// A relative location is resolved against the working directory
+	v, err := ProbeClientVersion("./avalanchego", dir, []string{"CLIENT_VERSION=1.0.3"})
+	if err != nil {
+		t.Fatal(err)
+	} else if v != (ClientVersion{1, 0, 3}) {
+		t.Fatalf("ProbeClientVersion returned %s expected 1.0.3", v)
+	}
+	// The version of the unmodified client is cached
+	if v, err := ProbeClientVersion(client, "", nil); err != nil || v != (ClientVersion{1, 0, 3}) {
+		t.Fatalf("ProbeClientVersion returned %s, %v expected the cached version", v, err)
+	}
+	if _, err := ProbeClientVersion(filepath.Join(dir, "missing"), "", nil); err == nil {
+		t.Fatalf("ProbeClientVersion returned no error for a missing client")
+	}
+}
+
+func TestFlagsCompat(t *testing.T) {
+	for name := range FlagsCompat {
+		if s, ok := LookupFlag(name); !ok || s.Avash {
+			t.Fatalf("FlagsCompat has %s, not a node flag", name)
+		}
+	}
+
+	args := []string{"--http-port=9650", "--p2p-tls-enabled=true", "--restart-on-disconnected=true", "--db-enabled=false"}
+	tests := []struct {
+		version ClientVersion
+		args    []string
+		omitted []string
+	}{
+		{ClientVersion{}, args, nil},
+		{ClientVersion{1, 0, 3}, []string{"--http-port=9650", "--p2p-tls-enabled=true", "--db-enabled=false"}, []string{"restart-on-disconnected"}},
+		{ClientVersion{1, 4, 5}, []string{"--http-port=9650", "--restart-on-disconnected=true", "--db-type=memdb"}, []string{"p2p-tls-enabled"}},
+	}
+	for _, test := range tests {
+		compat, omitted := CompatArgs(args, test.version)
+		if !reflect.DeepEqual(compat, test.args) || !reflect.DeepEqual(omitted, test.omitted) {
+			t.Fatalf("CompatArgs for %s returned %v, %v expected %v, %v", test.version, compat, omitted, test.args, test.omitted)
+		}
+	}
+}
@@ -0,0 +1,394 @@
+/*
+Copyright © 2019 AVA Labs <collin@avalabs.org>
+*/
+
+package processmgr
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/ava-labs/avash/cfg"
+	"github.com/ava-labs/avash/utils/logging"
+	"github.com/kennygrant/sanitize"
+	"github.com/olekukonko/tablewriter"
+)
+
+// ArtifactsDir is the directory of the data stash holding crash artifact bundles
+const ArtifactsDir = "artifacts"
+
+// DefaultArtifactsKept is the number of most recent bundles kept per process
+const DefaultArtifactsKept = 10
+
+// artifactTimeFormat stamps bundle names, sorting them by time
+const artifactTimeFormat = "20060102T150405.000"
+
+// Artifact is a crash artifact bundle of a failed run
+type Artifact struct {
+	Name string    `json:"name" yaml:"name"`
+	Path string    `json:"path" yaml:"path"`
+	Time time.Time `json:"time" yaml:"time"`
+	Size int64     `json:"size" yaml:"size"`
+}
+
+// crashReport describes a failed run in its artifact bundle. Its environment is
+// only the overrides of the process, as the environment of avash may hold credentials.
+type crashReport struct {
+	Name      string      `json:"name"`
+	Type      string      `json:"type"`
+	Command   []string    `json:"command"`
+	Dir       string      `json:"dir,omitempty"`
+	Env       []string    `json:"env"`
+	Metadata  interface{} `json:"metadata"`
+	ExitCode  int         `json:"exitCode"`
+	Signal    string      `json:"signal,omitempty"`
+	Reason    string      `json:"reason,omitempty"`
+	Error     string      `json:"error,omitempty"`
+	Restarts  int         `json:"restarts"`
+	StartedAt time.Time   `json:"startedAt,omitempty"`
+	FailedAt  time.Time   `json:"failedAt"`
+}
+
+// crash is a snapshot of a failed run, taken when it fails
+type crash struct {
+	// stash is the data directory the bundle is written to, none if empty
+	stash     string
+	log       logging.Log
+	report    crashReport
+	stdout    []string
+	stderr    []string
+	artifacts []string
+}
+
+// SetArtifactPaths sets the files and directories of the process at the name,
+// such as its logs, collected into its crash artifact bundles
+func (pm *ProcessManager) SetArtifactPaths(name string, paths []string) error {
+	p, err := pm.get(name, "set artifact paths")
+	if err != nil {
+		return err
+	}
+	p.lock.Lock()
+	p.artifacts = append([]string(nil), paths...)
+	p.lock.Unlock()
+	return nil
+}
+
+// snapshotCrash records the failed run ended by `err`.
+// Must be called with the process lock held.
+func (p *Process) snapshotCrash(err error) crash {
+	c := crash{
+		stash: cfg.Config.DataDir,
+		log:   cfg.Config.Log,
+		report: crashReport{
+			Name:      p.name,
+			Type:      p.proctype,
+			Command:   append([]string{p.cmdstr}, p.args...),
+			Dir:       p.dir,
+			Env:       append([]string{}, p.env...),
+			Metadata:  p.metadata,
+			ExitCode:  p.exitCode,
+			Signal:    exitSignal(err),
+			Reason:    p.reason,
+			Error:     errString(err),
+			Restarts:  p.restarts,
+			StartedAt: p.startedAt,
+			FailedAt:  p.failedAt,
+		},
+		artifacts: append([]string(nil), p.artifacts...),
+	}
+	var md interface{}
+	if err := json.Unmarshal([]byte(p.metadata), &md); err == nil {
+		c.report.Metadata = md
+	}
+	if p.stdout != nil {
+		c.stdout = p.stdout.Tail(0)
+	}
+	if p.stderr != nil {
+		c.stderr = p.stderr.Tail(0)
+	}
+	return c
+}
+
+// collectCrash saves the artifact bundle of the failed run ended by `err` in the
+// background, tracked by the process manager. Must be called with the process lock held.
+func (p *Process) collectCrash(err error) {
+	c := p.snapshotCrash(err)
+	if p.crashes == nil {
+		go saveCrash(c)
+		return
+	}
+	p.crashes.Add(1)
+	go func() {
+		defer p.crashes.Done()
+		saveCrash(c)
+	}()
+}
+
+// saveCrash collects the artifact bundle of the crash, logging where it is saved
+func saveCrash(c crash) {
+	log := c.log
+	bundle, err := collectArtifacts(c)
+	if err != nil {
+		log.Error("Unable to collect crash artifacts of %s: %s", c.report.Name, err.Error())
+	} else if bundle != "" {
+		log.Info("Crash artifacts of %s saved to %s", c.report.Name, bundle)
+	}
+}
+
+// exitSignal returns the name of the signal that terminated the run ended by `err`, if any
+func exitSignal(err error) string {
+	exitErr, ok := err.(*exec.ExitError)
+	if !ok {
+		return ""
+	}
+	if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return signalName(status.Signal())
+	}
+	return ""
+}
+
+// collectArtifacts writes the artifact bundle of the crash to the stash,
+// returning its path, and prunes the oldest bundles of the process
+func collectArtifacts(c crash) (string, error) {
+	if c.stash == "" {
+		return "", nil
+	}
+	dir := filepath.Join(c.stash, ArtifactsDir)
+	// The stash itself is not created, in case it was removed since the crash
+	if err := os.Mkdir(dir, os.ModePerm); err != nil && !os.IsExist(err) {
+		return "", err
+	}
+	base := sanitize.BaseName(c.report.Name) + "-" + c.report.FailedAt.Format(artifactTimeFormat)
+	bundle := filepath.Join(dir, base+".tar.gz")
+	// The bundle is written aside and renamed into place, so it is only listed once complete
+	f, err := ioutil.TempFile(dir, "."+base+"-*.tmp")
+	if err != nil {
+		return "", err
+	}
+	if err := writeBundle(f, base, c); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	if err := os.Rename(f.Name(), bundle); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	pruneArtifacts(c.stash, c.report.Name, DefaultArtifactsKept)
+	return bundle, nil
+}
+
+// writeBundle writes the crash into a tar.gz archive to `f` under the directory
+// `base`, closing it
+func writeBundle(f *os.File, base string, c crash) error {
+	defer f.Close()
+	if err := f.Chmod(0644); err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+
+	report, err := json.MarshalIndent(c.report, "", "    ")
+	if err != nil {
+		return err
+	}
+	files := []struct {
+		name string
+		data []byte
+	}{
+		{"report.json", report},
+		{"command.txt", []byte(strings.Join(c.report.Command, " ") + "\n")},
+		{"env.txt", []byte(joinLines(c.report.Env))},
+		{"stdout.log", []byte(joinLines(c.stdout))},
+		{"stderr.log", []byte(joinLines(c.stderr))},
+	}
+	for _, file := range files {
+		hdr := &tar.Header{
+			Name:    path.Join(base, file.name),
+			Mode:    0644,
+			Size:    int64(len(file.data)),
+			ModTime: c.report.FailedAt,
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := tw.Write(file.data); err != nil {
+			return err
+		}
+	}
+	for _, p := range c.artifacts {
+		if err := addArtifact(tw, path.Join(base, "artifacts", filepath.Base(p)), p); err != nil {
+			c.log.Warn("Unable to collect %s into crash bundle of %s: %s", p, c.report.Name, err.Error())
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// addArtifact adds the regular files of the file or directory `src` to the archive under `dst`.
+// Files removed while being collected, such as rotated logs, are skipped.
+func addArtifact(tw *tar.Writer, dst string, src string) error {
+	return filepath.Walk(src, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			if file == src {
+				return err
+			}
+			return nil
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(src, file)
+		if err != nil {
+			return err
+		}
+		hdr, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		hdr.Name = path.Join(dst, filepath.ToSlash(rel))
+		data, err := snapshotFile(file, info.Size())
+		if err != nil {
+			return nil
+		}
+		hdr.Size = int64(len(data))
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		_, err = tw.Write(data)
+		return err
+	})
+}
+
+// snapshotFile reads up to `size` bytes of the file. Logs may grow or be truncated
+// while being read, so the archive entry is sized by what was read.
+func snapshotFile(file string, size int64) ([]byte, error) {
+	r, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(io.LimitReader(r, size))
+}
+
+// joinLines joins output lines into text
+func joinLines(lines []string) string {
+	if len(lines) == 0 {
+		return ""
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// Artifacts returns the crash artifact bundles in the stash of the processes
+// whose names match the glob `pattern`, or of every process if it is empty,
+// sorted by name and then time
+func (pm *ProcessManager) Artifacts(pattern string) ([]Artifact, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("Invalid name pattern %q: %s", pattern, err.Error())
+	}
+	all, err := listArtifacts(cfg.Config.DataDir)
+	if err != nil || pattern == "" {
+		return all, err
+	}
+	var artifacts []Artifact
+	for _, a := range all {
+		if ok, _ := path.Match(pattern, a.Name); ok {
+			artifacts = append(artifacts, a)
+		}
+	}
+	return artifacts, nil
+}
+
+// listArtifacts returns every bundle in the stash, sorted by name and then time
+func listArtifacts(stash string) ([]Artifact, error) {
+	if stash == "" {
+		return nil, nil
+	}
+	dir := filepath.Join(stash, ArtifactsDir)
+	files, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var artifacts []Artifact
+	for _, f := range files {
+		if a, ok := parseArtifact(dir, f); ok {
+			artifacts = append(artifacts, a)
+		}
+	}
+	sort.Slice(artifacts, func(i, j int) bool {
+		if artifacts[i].Name != artifacts[j].Name {
+			return artifacts[i].Name < artifacts[j].Name
+		}
+		return artifacts[i].Time.Before(artifacts[j].Time)
+	})
+	return artifacts, nil
+}
+
+// parseArtifact returns the bundle of the stash file, or false if it is not one
+func parseArtifact(dir string, f os.FileInfo) (Artifact, bool) {
+	const ext = ".tar.gz"
+	name := f.Name()
+	stamp := len(name) - len(ext) - len(artifactTimeFormat)
+	if f.IsDir() || !strings.HasSuffix(name, ext) || stamp < 2 || name[stamp-1] != '-' {
+		return Artifact{}, false
+	}
+	t, err := time.ParseInLocation(artifactTimeFormat, name[stamp:len(name)-len(ext)], time.Local)
+	if err != nil {
+		return Artifact{}, false
+	}
+	return Artifact{
+		Name: name[:stamp-1],
+		Path: filepath.Join(dir, name),
+		Time: t,
+		Size: f.Size(),
+	}, true
+}
+
+// pruneArtifacts removes the oldest bundles of the process beyond the `keep` most recent
+func pruneArtifacts(stash string, name string, keep int) {
+	all, err := listArtifacts(stash)
+	if err != nil {
+		return
+	}
+	var bundles []Artifact
+	for _, a := range all {
+		if a.Name == sanitize.BaseName(name) {
+			bundles = append(bundles, a)
+		}
+	}
+	for i := 0; i < len(bundles)-keep; i++ {
+		os.Remove(bundles[i].Path)
+	}
+}
+
+// ArtifactsTable returns a formatted table of the bundles
+func ArtifactsTable(table *tablewriter.Table, artifacts []Artifact) *tablewriter.Table {
+	setTableStyle(table, []string{"Name", "Time", "Size", "Path"})
+	for _, a := range artifacts {
+		table.Append([]string{
+			a.Name,
+			a.Time.Format(time.RFC3339),
+			formatBytes(a.Size),
+			a.Path,
+		})
+	}
+	return table
+}
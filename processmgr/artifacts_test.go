@@ -0,0 +1,191 @@
+package processmgr
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ava-labs/avash/cfg"
+)
+
+// Reads the entries of a tar.gz bundle
+func readBundle(t *testing.T, bundle string) map[string]string {
+	f, err := os.Open(bundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := make(map[string]string)
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err != nil {
+			break
+		}
+		data, err := ioutil.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries[hdr.Name] = string(data)
+	}
+	return entries
+}
+
+// Waits for the named process to have `n` bundles
+func waitArtifacts(t *testing.T, pm *ProcessManager, name string, n int) []Artifact {
+	for i := 0; ; i++ {
+		artifacts, err := pm.Artifacts(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(artifacts) == n {
+			return artifacts
+		} else if i == 100 {
+			t.Fatalf("PM.Artifacts returned %d bundles expected %d", len(artifacts), n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestCrashArtifacts(t *testing.T) {
+	defer withDataDir(t)()
+	logs := filepath.Join(cfg.Config.DataDir, "logs")
+	if err := os.MkdirAll(filepath.Join(logs, "C"), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(logs, "C", "main.log"), []byte("FATAL crash\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	pm := ProcessManager{
+		processes: make(map[string]*Process),
+	}
+	pm.AddProcess("sh", "sh", []string{"-c", "echo out; echo err >&2; exit 3"}, "p", `{"log-dir": "logs"}`, nil, nil, nil)
+	if err := pm.SetArtifactPaths("p", []string{logs}); err != nil {
+		t.Fatal(err)
+	}
+	if err := pm.SetEnvironment("p", []string{"NODE_ENV=test"}, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := pm.SetArtifactPaths("missing", nil); err == nil {
+		t.Fatalf("PM.SetArtifactPaths returned no error for a missing process")
+	}
+	p := pm.processes["p"]
+	d := make(chan bool)
+	wg := syncStart(p, d)
+	<-d
+	wg.Wait()
+
+	artifacts := waitArtifacts(t, &pm, "p", 1)
+	if a := artifacts[0]; a.Name != "p" || a.Size == 0 {
+		t.Fatalf("PM.Artifacts returned %+v", a)
+	}
+	pm.WaitArtifacts()
+	if files, _ := ioutil.ReadDir(filepath.Dir(artifacts[0].Path)); len(files) != 1 {
+		t.Fatalf("crash artifacts left %d files expected the bundle only", len(files))
+	}
+	base := "p-" + artifacts[0].Time.Format(artifactTimeFormat) + "/"
+	entries := readBundle(t, artifacts[0].Path)
+	expected := map[string]string{
+		"command.txt":               "sh -c echo out; echo err >&2; exit 3\n",
+		"stdout.log":                "out\n",
+		"stderr.log":                "err\n",
+		"env.txt":                   "NODE_ENV=test\n",
+		"artifacts/logs/C/main.log": "FATAL crash\n",
+	}
+	for name, data := range expected {
+		if entries[base+name] != data {
+			t.Fatalf("bundle entry %s is %q expected %q", name, entries[base+name], data)
+		}
+	}
+	var report crashReport
+	if err := json.Unmarshal([]byte(entries[base+"report.json"]), &report); err != nil {
+		t.Fatal(err)
+	}
+	if report.ExitCode != 3 || report.Name != "p" || len(report.Env) != 1 {
+		t.Fatalf("bundle report %+v", report)
+	} else if md, ok := report.Metadata.(map[string]interface{}); !ok || md["log-dir"] != "logs" {
+		t.Fatalf("bundle report metadata %v", report.Metadata)
+	}
+
+	if artifacts, err := pm.Artifacts("q*"); err != nil || len(artifacts) != 0 {
+		t.Fatalf("PM.Artifacts returned %v, %v for no matching process", artifacts, err)
+	}
+	if _, err := pm.Artifacts("["); err == nil {
+		t.Fatalf("PM.Artifacts returned no error for an invalid pattern")
+	}
+}
+
+func TestCrashArtifactsSignal(t *testing.T) {
+	defer withDataDir(t)()
+	pm := ProcessManager{
+		processes: make(map[string]*Process),
+	}
+	pm.AddProcess("sh", "sh", []string{"-c", "kill -9 $$"}, "p", "", nil, nil, nil)
+	p := pm.processes["p"]
+	d := make(chan bool)
+	wg := syncStart(p, d)
+	<-d
+	wg.Wait()
+
+	artifacts := waitArtifacts(t, &pm, "p", 1)
+	entries := readBundle(t, artifacts[0].Path)
+	var report crashReport
+	base := "p-" + artifacts[0].Time.Format(artifactTimeFormat) + "/"
+	if err := json.Unmarshal([]byte(entries[base+"report.json"]), &report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Signal != "SIGKILL" {
+		t.Fatalf("bundle report signal %q expected %q", report.Signal, "SIGKILL")
+	}
+}
+
+func TestSnapshotFile(t *testing.T) {
+	defer withDataDir(t)()
+	file := filepath.Join(cfg.Config.DataDir, "main.log")
+	if err := ioutil.WriteFile(file, []byte("line\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// The file grew, or was truncated, since its size was read
+	for size, expected := range map[int64]string{2: "li", 100: "line\n"} {
+		if data, err := snapshotFile(file, size); err != nil || string(data) != expected {
+			t.Fatalf("snapshotFile returned %q, %v expected %q", data, err, expected)
+		}
+	}
+	if _, err := snapshotFile(file+".1", 100); err == nil {
+		t.Fatalf("snapshotFile returned no error for a rotated file")
+	}
+}
+
+func TestPruneArtifacts(t *testing.T) {
+	defer withDataDir(t)()
+	dir := filepath.Join(cfg.Config.DataDir, ArtifactsDir)
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.Local)
+	for i := 0; i < 5; i++ {
+		for _, name := range []string{"p", "p-2"} {
+			stamp := start.Add(time.Duration(i) * time.Second).Format(artifactTimeFormat)
+			if err := ioutil.WriteFile(filepath.Join(dir, name+"-"+stamp+".tar.gz"), nil, 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	pruneArtifacts(cfg.Config.DataDir, "p", 2)
+
+	var pm ProcessManager
+	if artifacts := waitArtifacts(t, &pm, "p", 2); !artifacts[0].Time.Equal(start.Add(3 * time.Second)) {
+		t.Fatalf("pruneArtifacts kept %+v expected the most recent", artifacts)
+	}
+	waitArtifacts(t, &pm, "p-2", 5)
+}
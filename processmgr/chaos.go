@@ -0,0 +1,415 @@
+/*
+Copyright © 2019 AVA Labs <collin@avalabs.org>
+*/
+
+package processmgr
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ava-labs/avash/cfg"
+)
+
+// Actions of chaos events
+const (
+	ChaosKill  = "kill"
+	ChaosStart = "start"
+	ChaosSkip  = "skip"
+)
+
+// ChaosConfig describes a chaos campaign killing and restarting random targets
+type ChaosConfig struct {
+	Targets []string `json:"targets"`
+	// KillRate is the mean rate of kills, such as 1/5m
+	KillRate string `json:"killRate"`
+	// Downtime is how long a killed target stays down, such as 30s or 30s-2m
+	Downtime string `json:"downtime"`
+	Seed     int64  `json:"seed"`
+	// MaxDown is the number of targets that may be down at the same time
+	MaxDown int `json:"maxDown"`
+	// Duration ends the campaign after it has run this long, if positive
+	Duration time.Duration `json:"duration,omitempty"`
+}
+
+// ChaosEvent is a fault injected, or skipped, by a chaos campaign
+type ChaosEvent struct {
+	// At is the time of the event since the start of the campaign
+	At     time.Duration `json:"at"`
+	Time   time.Time     `json:"time"`
+	Action string        `json:"action"`
+	Target string        `json:"target,omitempty"`
+	// Downtime is how long a killed target is kept down
+	Downtime time.Duration `json:"downtime,omitempty"`
+	// Reason is why a kill was skipped
+	Reason string `json:"reason,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// ChaosTimeline records a chaos campaign, so that its faults can be replayed
+type ChaosTimeline struct {
+	Config  ChaosConfig  `json:"config"`
+	Started time.Time    `json:"started"`
+	Events  []ChaosEvent `json:"events"`
+}
+
+// ParseKillRate parses a rate of the form `n/interval`, returning the mean time
+// between kills. A bare interval means one kill per interval.
+func ParseKillRate(s string) (time.Duration, error) {
+	count, interval := "1", s
+	if i := strings.Index(s, "/"); i >= 0 {
+		count, interval = s[:i], s[i+1:]
+	}
+	n, err := strconv.Atoi(count)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid kill rate %q: count must be a positive integer", s)
+	}
+	d, err := time.ParseDuration(interval)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid kill rate %q: interval must be a positive duration", s)
+	}
+	return d / time.Duration(n), nil
+}
+
+// ParseDowntime parses a duration or a range of durations `min-max`
+func ParseDowntime(s string) (time.Duration, time.Duration, error) {
+	lo, hi := s, s
+	if i := strings.Index(s, "-"); i >= 0 {
+		lo, hi = s[:i], s[i+1:]
+	}
+	min, err := time.ParseDuration(lo)
+	if err != nil || min < 0 {
+		return 0, 0, fmt.Errorf("invalid downtime %q: should be a duration or a range such as 30s-2m", s)
+	}
+	max, err := time.ParseDuration(hi)
+	if err != nil || max < min {
+		return 0, 0, fmt.Errorf("invalid downtime %q: should be a duration or a range such as 30s-2m", s)
+	}
+	return min, max, nil
+}
+
+// quorum is the number of targets that must stay up out of `n`
+func quorum(n int) int {
+	return n/2 + 1
+}
+
+// chaos is a running chaos campaign or replay
+type chaos struct {
+	pm       *ProcessManager
+	path     string
+	stop     chan struct{}
+	done     chan struct{}
+	lock     sync.Mutex
+	timeline ChaosTimeline
+	// down holds the restart timers of the targets killed and not yet restarted
+	down map[string]*time.Timer
+}
+
+// StartChaos starts a chaos campaign, recording its timeline at `path`. At the
+// mean kill rate, a random running target is killed and restarted after a
+// random downtime. A kill is skipped if it would leave more than MaxDown
+// targets down, or fewer than a majority of the targets running.
+func (pm *ProcessManager) StartChaos(c ChaosConfig, path string) error {
+	mean, err := ParseKillRate(c.KillRate)
+	if err != nil {
+		return err
+	}
+	min, max, err := ParseDowntime(c.Downtime)
+	if err != nil {
+		return err
+	}
+	if len(c.Targets) == 0 {
+		return fmt.Errorf("Chaos campaign has no targets")
+	}
+	for _, name := range c.Targets {
+		if _, err := pm.get(name, "target chaos"); err != nil {
+			return err
+		}
+	}
+	if c.MaxDown < 1 {
+		return fmt.Errorf("max-down must be at least 1")
+	}
+	if n := len(c.Targets); c.MaxDown > n-quorum(n) {
+		return fmt.Errorf("max-down %d would leave fewer than a quorum of %d of %d targets running", c.MaxDown, quorum(n), n)
+	}
+	ch, err := pm.newChaos(ChaosTimeline{Config: c}, path)
+	if err != nil {
+		return err
+	}
+	go ch.campaign(mean, min, max)
+	return nil
+}
+
+// ReplayChaos reproduces the kills and restarts of a recorded timeline at the
+// same times since its start, ignoring the skipped kills
+func (pm *ProcessManager) ReplayChaos(t ChaosTimeline) error {
+	events := make([]ChaosEvent, 0, len(t.Events))
+	for _, e := range t.Events {
+		if e.Action == ChaosKill || e.Action == ChaosStart {
+			events = append(events, e)
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].At < events[j].At
+	})
+	ch, err := pm.newChaos(ChaosTimeline{Config: t.Config}, "")
+	if err != nil {
+		return err
+	}
+	go ch.replay(events)
+	return nil
+}
+
+// newChaos registers a campaign, failing if one is already running
+func (pm *ProcessManager) newChaos(t ChaosTimeline, path string) (*chaos, error) {
+	pm.lock.Lock()
+	defer pm.lock.Unlock()
+	if pm.chaos != nil {
+		return nil, fmt.Errorf("A chaos campaign is already running")
+	}
+	t.Started = time.Now()
+	t.Events = []ChaosEvent{}
+	ch := &chaos{
+		pm:       pm,
+		path:     path,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+		timeline: t,
+		down:     make(map[string]*time.Timer),
+	}
+	if err := ch.save(); err != nil {
+		return nil, err
+	}
+	pm.chaos = ch
+	return ch, nil
+}
+
+// StopChaos ends the running chaos campaign, restarting the targets it left down
+func (pm *ProcessManager) StopChaos() error {
+	pm.lock.RLock()
+	ch := pm.chaos
+	pm.lock.RUnlock()
+	if ch == nil {
+		return fmt.Errorf("No chaos campaign is running")
+	}
+	select {
+	case <-ch.stop:
+	default:
+		close(ch.stop)
+	}
+	<-ch.done
+	return nil
+}
+
+// ChaosTimeline returns the timeline of the running chaos campaign, or false if none is running
+func (pm *ProcessManager) ChaosTimeline() (ChaosTimeline, bool) {
+	pm.lock.RLock()
+	ch := pm.chaos
+	pm.lock.RUnlock()
+	if ch == nil {
+		return ChaosTimeline{}, false
+	}
+	ch.lock.Lock()
+	defer ch.lock.Unlock()
+	t := ch.timeline
+	t.Events = append([]ChaosEvent(nil), t.Events...)
+	return t, true
+}
+
+// campaign injects faults until stopped or the duration has elapsed. Every
+// random choice is made here in sequence, so that a seed reproduces the same
+// choices given the same states of the targets.
+func (ch *chaos) campaign(mean, min, max time.Duration) {
+	defer ch.finish()
+	c := ch.timeline.Config
+	rng := rand.New(rand.NewSource(c.Seed))
+	var end <-chan time.Time
+	if c.Duration > 0 {
+		end = time.After(c.Duration)
+	}
+	for {
+		wait := time.Duration(rng.ExpFloat64() * float64(mean))
+		select {
+		case <-ch.stop:
+			return
+		case <-end:
+			return
+		case <-time.After(wait):
+		}
+		downtime := min
+		if max > min {
+			downtime += time.Duration(rng.Int63n(int64(max - min)))
+		}
+		up, reason := ch.candidates()
+		if len(up) == 0 {
+			ch.record(ChaosEvent{Action: ChaosSkip, Reason: reason})
+			continue
+		}
+		ch.kill(up[rng.Intn(len(up))], downtime)
+	}
+}
+
+// candidates returns the running targets that may be killed while keeping the
+// safety bounds, or the reason none may be
+func (ch *chaos) candidates() ([]string, string) {
+	targets := ch.timeline.Config.Targets
+	var up []string
+	for _, name := range targets {
+		if p, err := ch.pm.get(name, "target chaos"); err == nil && p.State() == StateRunning {
+			up = append(up, name)
+		}
+	}
+	down := len(targets) - len(up)
+	switch {
+	case down >= ch.timeline.Config.MaxDown:
+		return nil, fmt.Sprintf("%d of %d targets down, max-down is %d", down, len(targets), ch.timeline.Config.MaxDown)
+	case len(up)-1 < quorum(len(targets)):
+		return nil, fmt.Sprintf("%d of %d targets running, a quorum is %d", len(up), len(targets), quorum(len(targets)))
+	}
+	return up, ""
+}
+
+// kill kills the target and schedules its restart after `downtime`
+func (ch *chaos) kill(name string, downtime time.Duration) {
+	err := ch.pm.KillProcess(name)
+	ch.record(ChaosEvent{Action: ChaosKill, Target: name, Downtime: downtime, Error: errString(err)})
+	if err != nil {
+		return
+	}
+	ch.lock.Lock()
+	defer ch.lock.Unlock()
+	ch.down[name] = time.AfterFunc(downtime, func() { ch.restart(name) })
+}
+
+// restart starts the killed target, unless the campaign already restarted it
+func (ch *chaos) restart(name string) {
+	ch.lock.Lock()
+	_, ok := ch.down[name]
+	delete(ch.down, name)
+	ch.lock.Unlock()
+	if !ok {
+		return
+	}
+	err := ch.pm.StartProcess(name)
+	ch.record(ChaosEvent{Action: ChaosStart, Target: name, Error: errString(err)})
+}
+
+// replay runs the events at their times since the start of the replay
+func (ch *chaos) replay(events []ChaosEvent) {
+	defer ch.finish()
+	for _, e := range events {
+		select {
+		case <-ch.stop:
+			return
+		case <-time.After(time.Until(ch.timeline.Started.Add(e.At))):
+		}
+		switch e.Action {
+		case ChaosKill:
+			err := ch.pm.KillProcess(e.Target)
+			ch.record(ChaosEvent{Action: ChaosKill, Target: e.Target, Downtime: e.Downtime, Error: errString(err)})
+			if err == nil {
+				ch.lock.Lock()
+				ch.down[e.Target] = nil
+				ch.lock.Unlock()
+			}
+		case ChaosStart:
+			ch.restart(e.Target)
+		}
+	}
+}
+
+// finish restarts the targets left down and unregisters the campaign
+func (ch *chaos) finish() {
+	ch.lock.Lock()
+	var names []string
+	for name, timer := range ch.down {
+		if timer != nil {
+			timer.Stop()
+		}
+		names = append(names, name)
+	}
+	ch.lock.Unlock()
+	sort.Strings(names)
+	for _, name := range names {
+		ch.restart(name)
+	}
+	ch.pm.lock.Lock()
+	ch.pm.chaos = nil
+	ch.pm.lock.Unlock()
+	cfg.Config.Log.Info("Chaos campaign ended")
+	close(ch.done)
+}
+
+// record appends the event to the timeline and saves it
+func (ch *chaos) record(e ChaosEvent) {
+	log := cfg.Config.Log
+	ch.lock.Lock()
+	defer ch.lock.Unlock()
+	e.Time = time.Now()
+	e.At = e.Time.Sub(ch.timeline.Started)
+	ch.timeline.Events = append(ch.timeline.Events, e)
+	switch {
+	case e.Error != "":
+		log.Error("Chaos %s of %s failed: %s", e.Action, e.Target, e.Error)
+	case e.Action == ChaosKill:
+		log.Info("Chaos killed %s for %s", e.Target, e.Downtime)
+	case e.Action == ChaosStart:
+		log.Info("Chaos restarted %s", e.Target)
+	case e.Action == ChaosSkip:
+		log.Info("Chaos skipped a kill: %s", e.Reason)
+	}
+	if err := ch.save(); err != nil {
+		log.Error("Unable to save chaos timeline: %s", err.Error())
+	}
+}
+
+// save writes the timeline to its file, if any. Must be called with the lock
+// held, or before the campaign starts.
+func (ch *chaos) save() error {
+	if ch.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(ch.timeline, "", "    ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(ch.path), os.ModePerm); err != nil {
+		return err
+	}
+	// Written whole and renamed into place, so that the file is always complete
+	tmp := ch.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, ch.path)
+}
+
+// ReadChaosTimeline reads a timeline recorded by a chaos campaign
+func ReadChaosTimeline(path string) (ChaosTimeline, error) {
+	var t ChaosTimeline
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return t, err
+	}
+	if err := json.Unmarshal(data, &t); err != nil {
+		return t, fmt.Errorf("Invalid chaos timeline %s: %s", path, err.Error())
+	}
+	return t, nil
+}
+
+// errString returns the message of `err`, or "" if it is nil
+func errString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
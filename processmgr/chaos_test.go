@@ -0,0 +1,193 @@
+package processmgr
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseKillRate(t *testing.T) {
+	tests := []struct {
+		in   string
+		mean time.Duration
+		ok   bool
+	}{
+		{"1/5m", 5 * time.Minute, true},
+		{"3/1m", 20 * time.Second, true},
+		{"10s", 10 * time.Second, true},
+		{"0/5m", 0, false},
+		{"x/5m", 0, false},
+		{"1/x", 0, false},
+		{"1/-5m", 0, false},
+	}
+	for _, test := range tests {
+		mean, err := ParseKillRate(test.in)
+		if (err == nil) != test.ok {
+			t.Fatalf("ParseKillRate(%q) returned error %v", test.in, err)
+		} else if mean != test.mean {
+			t.Fatalf("ParseKillRate(%q) returned %s expected %s", test.in, mean, test.mean)
+		}
+	}
+}
+
+func TestParseDowntime(t *testing.T) {
+	tests := []struct {
+		in       string
+		min, max time.Duration
+		ok       bool
+	}{
+		{"30s-2m", 30 * time.Second, 2 * time.Minute, true},
+		{"30s", 30 * time.Second, 30 * time.Second, true},
+		{"2m-30s", 0, 0, false},
+		{"x-2m", 0, 0, false},
+		{"", 0, 0, false},
+	}
+	for _, test := range tests {
+		min, max, err := ParseDowntime(test.in)
+		if (err == nil) != test.ok {
+			t.Fatalf("ParseDowntime(%q) returned error %v", test.in, err)
+		} else if min != test.min || max != test.max {
+			t.Fatalf("ParseDowntime(%q) returned %s-%s expected %s-%s", test.in, min, max, test.min, test.max)
+		}
+	}
+}
+
+func newChaosTestPM(t *testing.T, names ...string) *ProcessManager {
+	pm := newSchedulerTestPM(names...)
+	for _, name := range names {
+		if err := pm.StartProcess(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return pm
+}
+
+func TestStartChaosBounds(t *testing.T) {
+	pm := newSchedulerTestPM("p1", "p2", "p3")
+	c := ChaosConfig{Targets: []string{"p1", "p2", "p3"}, KillRate: "1/1m", Downtime: "1s", MaxDown: 2}
+	if err := pm.StartChaos(c, ""); err == nil {
+		t.Fatalf("PM.StartChaos returned no error for max-down breaking quorum")
+	}
+	c.MaxDown = 0
+	if err := pm.StartChaos(c, ""); err == nil {
+		t.Fatalf("PM.StartChaos returned no error for max-down 0")
+	}
+	c.MaxDown, c.Targets = 1, []string{"p1", "missing"}
+	if err := pm.StartChaos(c, ""); err == nil {
+		t.Fatalf("PM.StartChaos returned no error for a missing target")
+	}
+}
+
+func TestChaosCampaign(t *testing.T) {
+	names := []string{"p1", "p2", "p3"}
+	pm := newChaosTestPM(t, names...)
+	defer pm.KillAllProcesses()
+	dir, err := ioutil.TempDir("", "chaos")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "timeline.json")
+
+	c := ChaosConfig{Targets: names, KillRate: "1/20ms", Downtime: "30ms-60ms", Seed: 42, MaxDown: 1}
+	if err := pm.StartChaos(c, path); err != nil {
+		t.Fatal(err)
+	}
+	if err := pm.StartChaos(c, path); err == nil {
+		t.Fatalf("PM.StartChaos returned no error with a campaign running")
+	}
+	time.Sleep(400 * time.Millisecond)
+	if err := pm.StopChaos(); err != nil {
+		t.Fatal(err)
+	}
+	if err := pm.StopChaos(); err == nil {
+		t.Fatalf("PM.StopChaos returned no error without a campaign running")
+	}
+
+	tl, err := ReadChaosTimeline(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tl.Config.Seed != 42 || len(tl.Config.Targets) != 3 {
+		t.Fatalf("timeline recorded config %+v", tl.Config)
+	}
+	kills, down := 0, 0
+	for _, e := range tl.Events {
+		if e.Error != "" {
+			t.Fatalf("timeline recorded failed event %+v", e)
+		}
+		switch e.Action {
+		case ChaosKill:
+			kills++
+			down++
+		case ChaosStart:
+			down--
+		}
+		if down > c.MaxDown {
+			t.Fatalf("campaign had %d targets down, max-down is %d", down, c.MaxDown)
+		}
+	}
+	if kills == 0 {
+		t.Fatalf("campaign recorded no kills: %+v", tl.Events)
+	} else if down != 0 {
+		t.Fatalf("campaign left %d targets down", down)
+	}
+	for _, name := range names {
+		if p, _ := pm.get(name, "test"); p.State() != StateRunning {
+			t.Fatalf("%s is %s after the campaign ended", name, p.State())
+		}
+	}
+}
+
+func TestReplayChaos(t *testing.T) {
+	pm := newChaosTestPM(t, "p1", "p2", "p3")
+	defer pm.KillAllProcesses()
+	tl := ChaosTimeline{
+		Config: ChaosConfig{Targets: []string{"p1", "p2", "p3"}},
+		Events: []ChaosEvent{
+			{At: 10 * time.Millisecond, Action: ChaosKill, Target: "p2"},
+			{At: 20 * time.Millisecond, Action: ChaosSkip, Reason: "test"},
+			{At: 60 * time.Millisecond, Action: ChaosStart, Target: "p2"},
+			{At: 80 * time.Millisecond, Action: ChaosKill, Target: "p3"},
+		},
+	}
+	if err := pm.ReplayChaos(tl); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(40 * time.Millisecond)
+	if p, _ := pm.get("p2", "test"); p.State() != StateStopped {
+		t.Fatalf("p2 is %s during its replayed downtime", p.State())
+	}
+	// The replay ends after its last event, restarting p3
+	time.Sleep(200 * time.Millisecond)
+	if _, ok := pm.ChaosTimeline(); ok {
+		t.Fatalf("replay still running after its last event")
+	}
+	for _, name := range []string{"p1", "p2", "p3"} {
+		if p, _ := pm.get(name, "test"); p.State() != StateRunning {
+			t.Fatalf("%s is %s after the replay ended", name, p.State())
+		}
+	}
+}
+
+func TestChaosRestartFailure(t *testing.T) {
+	pm := newChaosTestPM(t, "p1")
+	defer pm.KillAllProcesses()
+	if err := pm.KillProcess("p1"); err != nil {
+		t.Fatal(err)
+	}
+	p, _ := pm.get("p1", "test")
+	p.lock.Lock()
+	p.cmdstr = "fake-command"
+	p.lock.Unlock()
+
+	ch := &chaos{pm: pm, down: map[string]*time.Timer{"p1": nil}}
+	ch.restart("p1")
+	if len(ch.timeline.Events) != 1 {
+		t.Fatalf("restart recorded %+v expected one event", ch.timeline.Events)
+	} else if e := ch.timeline.Events[0]; e.Action != ChaosStart || e.Error == "" {
+		t.Fatalf("restart recorded %+v expected a failed start", e)
+	}
+}
@@ -0,0 +1,48 @@
+/*
+Copyright © 2019 AVA Labs <collin@avalabs.org>
+*/
+
+package processmgr
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ParseEnv validates environment variable overrides given as KEY=VALUE
+func ParseEnv(pairs []string) ([]string, error) {
+	env := make([]string, 0, len(pairs))
+	for _, pair := range pairs {
+		if strings.IndexByte(pair, '=') <= 0 {
+			return nil, fmt.Errorf("Invalid environment variable %q, expected KEY=VALUE", pair)
+		}
+		env = append(env, pair)
+	}
+	return env, nil
+}
+
+// environ returns the environment of avash with the overrides in `env` applied,
+// or nil to inherit it unchanged if there are none
+func environ(env []string) []string {
+	if len(env) == 0 {
+		return nil
+	}
+	// exec.Cmd keeps the last value of duplicated keys, so overrides win
+	return append(os.Environ(), env...)
+}
+
+// SetEnvironment sets the environment variable overrides and the working directory
+// of the process at the name, used whenever it starts. An empty `dir` runs the
+// process in the working directory of avash.
+func (pm *ProcessManager) SetEnvironment(name string, env []string, dir string) error {
+	p, err := pm.get(name, "set environment")
+	if err != nil {
+		return err
+	}
+	p.lock.Lock()
+	p.env = append([]string(nil), env...)
+	p.dir = dir
+	p.lock.Unlock()
+	return nil
+}
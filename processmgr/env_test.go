@@ -0,0 +1,64 @@
+package processmgr
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseEnv(t *testing.T) {
+	env, err := ParseEnv([]string{"GOPATH=/go", "EMPTY=", "OPTS=a=b,c"})
+	if err != nil {
+		t.Fatal(err)
+	} else if expected := []string{"GOPATH=/go", "EMPTY=", "OPTS=a=b,c"}; !reflect.DeepEqual(env, expected) {
+		t.Fatalf("ParseEnv returned %v expected %v", env, expected)
+	}
+	for _, pair := range []string{"GOPATH", "=value", ""} {
+		if _, err := ParseEnv([]string{pair}); err == nil {
+			t.Fatalf("ParseEnv returned no error for %q", pair)
+		}
+	}
+	if env := environ(nil); env != nil {
+		t.Fatalf("environ returned %v expected to inherit the environment", env)
+	}
+}
+
+func TestProcessEnvironment(t *testing.T) {
+	dir, err := ioutil.TempDir("", "avash-env")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	// The working directory may be reached through a symlink, e.g. on macOS
+	if dir, err = filepath.EvalSymlinks(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	pm := ProcessManager{
+		processes: make(map[string]*Process),
+	}
+	pm.AddProcess("sh", "sh", []string{"-c", "echo $AVASH_TEST; pwd"}, "p", "", nil, nil, nil)
+	os.Setenv("AVASH_TEST", "inherited")
+	defer os.Unsetenv("AVASH_TEST")
+	if err := pm.SetEnvironment("p", []string{"AVASH_TEST=override"}, dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := pm.SetEnvironment("missing", nil, ""); err == nil {
+		t.Fatalf("PM.SetEnvironment returned no error for a missing process")
+	}
+
+	p := pm.processes["p"]
+	d := make(chan bool)
+	wg := syncStart(p, d)
+	<-d
+	wg.Wait()
+
+	if out, expected := p.stdout.Tail(0), []string{"override", dir}; !reflect.DeepEqual(out, expected) {
+		t.Fatalf("process output %v expected %v", out, expected)
+	}
+	if info := p.info(); info.WorkDir != dir || !reflect.DeepEqual(info.Env, []string{"AVASH_TEST=override"}) {
+		t.Fatalf("P.info returned env %v workdir %s", info.Env, info.WorkDir)
+	}
+}
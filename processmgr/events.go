@@ -0,0 +1,201 @@
+/*
+Copyright © 2019 AVA Labs <collin@avalabs.org>
+*/
+
+package processmgr
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EventKind is the kind of a process lifecycle event
+type EventKind int
+
+// Enum ...
+const (
+	EventStarted EventKind = iota
+	EventStopped
+	EventFailed
+	EventRestarting
+	EventPaused
+	EventResumed
+)
+
+// ToEventKind ...
+func ToEventKind(s string) (EventKind, error) {
+	switch strings.ToLower(s) {
+	case "started":
+		return EventStarted, nil
+	case "stopped":
+		return EventStopped, nil
+	case "failed":
+		return EventFailed, nil
+	case "restarting":
+		return EventRestarting, nil
+	case "paused":
+		return EventPaused, nil
+	case "resumed":
+		return EventResumed, nil
+	default:
+		return EventStarted, fmt.Errorf("unknown event kind: %s", s)
+	}
+}
+
+func (k EventKind) String() string {
+	switch k {
+	case EventStarted:
+		return "started"
+	case EventStopped:
+		return "stopped"
+	case EventFailed:
+		return "failed"
+	case EventRestarting:
+		return "restarting"
+	case EventPaused:
+		return "paused"
+	case EventResumed:
+		return "resumed"
+	default:
+		return "?????"
+	}
+}
+
+// Event is a lifecycle event published by a process
+type Event struct {
+	Kind     EventKind
+	Time     time.Time
+	Name     string
+	ExitCode int
+	// Signal is the signal sent by avash that ended a stopped process, if any
+	Signal string
+	// Reason is the resource limit that terminated a failed process, if any
+	Reason string
+	Err    error
+}
+
+func (e Event) String() string {
+	s := fmt.Sprintf("%s %s %s", e.Time.Format(time.RFC3339), e.Kind, e.Name)
+	if e.Kind == EventStopped || e.Kind == EventFailed {
+		s += fmt.Sprintf(" (exit code %d)", e.ExitCode)
+	}
+	if e.Signal != "" {
+		s += " by " + e.Signal
+	}
+	if e.Reason != "" {
+		s += ": " + e.Reason
+	}
+	if e.Err != nil {
+		s += ": " + e.Err.Error()
+	}
+	return s
+}
+
+// maxEventHistory is the number of past events retained by an EventBus
+const maxEventHistory = 1000
+
+// subscriptionBuffer is the number of events a subscription holds until received
+const subscriptionBuffer = 256
+
+// EventBus fans out published events to subscribers and retains a bounded history.
+// The zero value is ready to use.
+type EventBus struct {
+	lock    sync.Mutex
+	history []Event
+	subs    map[*Subscription]struct{}
+}
+
+// Subscription receives the events published after it was created. Events
+// published while its buffer is full are dropped and counted.
+type Subscription struct {
+	// Events receives every new event, until the subscription is closed
+	Events <-chan Event
+	events chan Event
+	bus    *EventBus
+	// dropped is the number of events dropped since last taken, guarded by the bus lock
+	dropped int
+	once    sync.Once
+}
+
+// Publish records `e` and sends it to every subscriber without blocking
+func (eb *EventBus) Publish(e Event) {
+	eb.lock.Lock()
+	defer eb.lock.Unlock()
+	eb.history = append(eb.history, e)
+	if len(eb.history) > maxEventHistory {
+		eb.history = eb.history[len(eb.history)-maxEventHistory:]
+	}
+	for sub := range eb.subs {
+		select {
+		case sub.events <- e:
+		default:
+			sub.dropped++
+		}
+	}
+}
+
+// History returns the retained past events, oldest first
+func (eb *EventBus) History() []Event {
+	eb.lock.Lock()
+	defer eb.lock.Unlock()
+	return append([]Event(nil), eb.history...)
+}
+
+// Subscribe returns a subscription receiving every new event
+func (eb *EventBus) Subscribe() *Subscription {
+	events := make(chan Event, subscriptionBuffer)
+	sub := &Subscription{Events: events, events: events, bus: eb}
+	eb.lock.Lock()
+	if eb.subs == nil {
+		eb.subs = make(map[*Subscription]struct{})
+	}
+	eb.subs[sub] = struct{}{}
+	eb.lock.Unlock()
+	return sub
+}
+
+// Dropped returns the number of events dropped because the subscriber fell
+// behind, since the previous call
+func (s *Subscription) Dropped() int {
+	s.bus.lock.Lock()
+	defer s.bus.lock.Unlock()
+	dropped := s.dropped
+	s.dropped = 0
+	return dropped
+}
+
+// Close unsubscribes, closing the events channel
+func (s *Subscription) Close() {
+	s.once.Do(func() {
+		s.bus.lock.Lock()
+		delete(s.bus.subs, s)
+		s.bus.lock.Unlock()
+		close(s.events)
+	})
+}
+
+// publish sends an event of `kind` for the process to its event bus, if any.
+// Must be called with the process lock held.
+func (p *Process) publish(kind EventKind, code int, err error) {
+	if p.events == nil {
+		return
+	}
+	var signal, reason string
+	if kind == EventStopped && p.endSignal != 0 {
+		signal = signalName(p.endSignal)
+	}
+	if kind == EventFailed {
+		reason = p.reason
+	}
+	p.events.Publish(Event{
+		Kind:     kind,
+		Time:     time.Now(),
+		Name:     p.name,
+		ExitCode: code,
+		Signal:   signal,
+		Reason:   reason,
+		Err:      err,
+	})
+}
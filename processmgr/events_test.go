@@ -0,0 +1,94 @@
+package processmgr
+
+import (
+	"testing"
+)
+
+func TestToEventKind(t *testing.T) {
+	for _, k := range []EventKind{EventStarted, EventStopped, EventFailed, EventRestarting, EventPaused, EventResumed} {
+		if res, err := ToEventKind(k.String()); err != nil {
+			t.Fatalf("ToEventKind returned error %v for %s", err, k)
+		} else if res != k {
+			t.Fatalf("ToEventKind returned %s expected %s", res, k)
+		}
+	}
+	if _, err := ToEventKind("exploded"); err == nil {
+		t.Fatalf("ToEventKind returned %v expected error", err)
+	}
+}
+
+func TestEventBus(t *testing.T) {
+	t.Run("History", func(t *testing.T) {
+		var eb EventBus
+		for i := 0; i < maxEventHistory+1; i++ {
+			eb.Publish(Event{Kind: EventStarted, ExitCode: i})
+		}
+		history := eb.History()
+		if len(history) != maxEventHistory {
+			t.Fatalf("EB.History returned %d events expected %d", len(history), maxEventHistory)
+		} else if history[0].ExitCode != 1 {
+			t.Fatalf("EB.History oldest event %d expected %d", history[0].ExitCode, 1)
+		}
+	})
+	t.Run("Subscribe", func(t *testing.T) {
+		var eb EventBus
+		sub := eb.Subscribe()
+		eb.Publish(Event{Kind: EventFailed, Name: "node"})
+		sub.Close()
+
+		if e := <-sub.Events; e.Kind != EventFailed || e.Name != "node" {
+			t.Fatalf("EB.Subscribe returned %s expected %s event for %s", e, EventFailed, "node")
+		} else if _, ok := <-sub.Events; ok {
+			t.Fatalf("EB.Subscribe channel open after close")
+		}
+	})
+	t.Run("Overflow", func(t *testing.T) {
+		var eb EventBus
+		sub := eb.Subscribe()
+		defer sub.Close()
+		for i := 0; i < subscriptionBuffer+10; i++ {
+			eb.Publish(Event{Kind: EventStarted, ExitCode: i})
+		}
+		if dropped := sub.Dropped(); dropped != 10 {
+			t.Fatalf("S.Dropped returned %d expected %d", dropped, 10)
+		} else if dropped := sub.Dropped(); dropped != 0 {
+			t.Fatalf("S.Dropped returned %d after being taken expected %d", dropped, 0)
+		}
+		for i := 0; i < subscriptionBuffer; i++ {
+			if e := <-sub.Events; e.ExitCode != i {
+				t.Fatalf("S.Events returned event %d expected %d", e.ExitCode, i)
+			}
+		}
+		eb.Publish(Event{Kind: EventFailed})
+		if e := <-sub.Events; e.Kind != EventFailed {
+			t.Fatalf("S.Events returned %s after draining expected %s", e.Kind, EventFailed)
+		} else if dropped := sub.Dropped(); dropped != 0 {
+			t.Fatalf("S.Dropped returned %d after draining expected %d", dropped, 0)
+		}
+	})
+}
+
+func TestProcessEvents(t *testing.T) {
+	var eb EventBus
+	p := &Process{
+		cmdstr: "sh",
+		args:   []string{"-c", "exit 3"},
+		name:   "p",
+		stdout: newOutputBuffer(DefaultOutputLines, nil),
+		stderr: newOutputBuffer(DefaultOutputLines, nil),
+		events: &eb,
+	}
+	d := make(chan bool)
+	wg := syncStart(p, d)
+	<-d
+	wg.Wait()
+
+	history := eb.History()
+	if len(history) != 2 {
+		t.Fatalf("P.Start published %v expected 2 events", history)
+	} else if history[0].Kind != EventStarted {
+		t.Fatalf("P.Start published %s expected %s", history[0].Kind, EventStarted)
+	} else if history[1].Kind != EventFailed || history[1].ExitCode != 3 {
+		t.Fatalf("P.Start published %s expected %s with exit code %d", history[1], EventFailed, 3)
+	}
+}
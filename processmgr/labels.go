@@ -0,0 +1,151 @@
+/*
+Copyright © 2019 AVA Labs <collin@avalabs.org>
+*/
+
+package processmgr
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Labels are key/value pairs attached to a process to select it by
+type Labels map[string]string
+
+// ParseLabels parses "key=value" pairs into labels
+func ParseLabels(pairs []string) (Labels, error) {
+	labels := make(Labels)
+	for _, pair := range pairs {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("Invalid label, expected key=value: %s", pair)
+		}
+		key, value := strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1])
+		if err := validateLabelKey(key); err != nil {
+			return nil, err
+		}
+		labels[key] = value
+	}
+	return labels, nil
+}
+
+func validateLabelKey(key string) error {
+	if key == "" || strings.ContainsAny(key, "=!, \t") {
+		return fmt.Errorf("Invalid label key: %q", key)
+	}
+	return nil
+}
+
+func (l Labels) String() string {
+	pairs := make([]string, 0, len(l))
+	for k, v := range l {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// requirement is a single "key=value" or "key!=value" term of a selector
+type requirement struct {
+	key, value string
+	equal      bool
+}
+
+// Selector matches processes by their labels. The empty selector matches every process.
+type Selector []requirement
+
+// ParseSelector parses a comma separated list of "key=value" and "key!=value" terms,
+// all of which must hold for a process to match
+func ParseSelector(s string) (Selector, error) {
+	var sel Selector
+	if strings.TrimSpace(s) == "" {
+		return sel, nil
+	}
+	for _, term := range strings.Split(s, ",") {
+		req := requirement{equal: true}
+		kv := strings.SplitN(term, "!=", 2)
+		if len(kv) == 2 {
+			req.equal = false
+		} else if kv = strings.SplitN(term, "=", 2); len(kv) != 2 {
+			return nil, fmt.Errorf("Invalid selector term, expected key=value or key!=value: %s", term)
+		}
+		req.key, req.value = strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1])
+		if err := validateLabelKey(req.key); err != nil {
+			return nil, err
+		}
+		sel = append(sel, req)
+	}
+	return sel, nil
+}
+
+// Matches returns true if the labels satisfy every term of the selector
+func (s Selector) Matches(labels Labels) bool {
+	for _, req := range s {
+		if value, ok := labels[req.key]; (ok && value == req.value) != req.equal {
+			return false
+		}
+	}
+	return true
+}
+
+// isGlob returns true if the process name pattern contains glob metacharacters
+func isGlob(pattern string) bool {
+	return strings.ContainsAny(pattern, `*?[\`)
+}
+
+// Select returns the sorted names of the processes whose names match the glob
+// `pattern` and whose labels match `selector`. An empty pattern matches every name.
+// A plain name without a selector is returned as is, so that operations on it
+// report a missing process themselves.
+func (pm *ProcessManager) Select(pattern string, selector string) ([]string, error) {
+	sel, err := ParseSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+	if pattern != "" && !isGlob(pattern) && len(sel) == 0 {
+		return []string{pattern}, nil
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("Invalid name pattern %q: %s", pattern, err.Error())
+	}
+	var names []string
+	for _, p := range pm.list() {
+		if pattern != "" {
+			if ok, _ := path.Match(pattern, p.name); !ok {
+				continue
+			}
+		}
+		if sel.Matches(p.Labels()) {
+			names = append(names, p.name)
+		}
+	}
+	return names, nil
+}
+
+// Labels returns a copy of the labels of the process
+func (p *Process) Labels() Labels {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	labels := make(Labels, len(p.labels))
+	for k, v := range p.labels {
+		labels[k] = v
+	}
+	return labels
+}
+
+// SetLabels replaces the labels of the process at the name
+func (pm *ProcessManager) SetLabels(name string, labels Labels) error {
+	p, err := pm.get(name, "set labels")
+	if err != nil {
+		return err
+	}
+	p.lock.Lock()
+	p.labels = make(Labels, len(labels))
+	for k, v := range labels {
+		p.labels[k] = v
+	}
+	p.lock.Unlock()
+	return nil
+}
@@ -0,0 +1,82 @@
+package processmgr
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseLabels(t *testing.T) {
+	labels, err := ParseLabels([]string{"role=validator", "zone = a", "empty="})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := (Labels{"role": "validator", "zone": "a", "empty": ""}); !reflect.DeepEqual(labels, expected) {
+		t.Fatalf("ParseLabels returned %v expected %v", labels, expected)
+	} else if s, expected := labels.String(), "empty=,role=validator,zone=a"; s != expected {
+		t.Fatalf("Labels.String returned %s expected %s", s, expected)
+	}
+	for _, invalid := range []string{"role", "=validator", "ro le=validator"} {
+		if _, err := ParseLabels([]string{invalid}); err == nil {
+			t.Fatalf("ParseLabels returned %v expected error for %s", err, invalid)
+		}
+	}
+}
+
+func TestSelector(t *testing.T) {
+	labels := Labels{"role": "validator", "zone": "a"}
+	tests := map[string]bool{
+		"":                       true,
+		"role=validator":         true,
+		"role=api":               false,
+		"role=validator,zone=a":  true,
+		"role=validator,zone!=a": false,
+		"tier!=gold":             true,
+		"tier=":                  false,
+	}
+	for selector, expected := range tests {
+		sel, err := ParseSelector(selector)
+		if err != nil {
+			t.Fatalf("ParseSelector returned error %v for %s", err, selector)
+		}
+		if matches := sel.Matches(labels); matches != expected {
+			t.Fatalf("Selector.Matches returned %t expected %t for %s", matches, expected, selector)
+		}
+	}
+	if _, err := ParseSelector("role"); err == nil {
+		t.Fatalf("ParseSelector returned %v expected error", err)
+	}
+}
+
+func TestSelect(t *testing.T) {
+	pm := ProcessManager{
+		processes: make(map[string]*Process),
+	}
+	for name, labels := range map[string]Labels{
+		"validator1": {"role": "validator"},
+		"validator2": {"role": "validator"},
+		"api1":       {"role": "api"},
+	} {
+		pm.AddProcess("cmd", "fake-cmd", []string{"arg"}, name, "data", nil, nil, nil)
+		pm.SetLabels(name, labels)
+	}
+
+	tests := []struct {
+		pattern, selector string
+		expected          []string
+	}{
+		{"", "role=validator", []string{"validator1", "validator2"}},
+		{"*1", "", []string{"api1", "validator1"}},
+		{"*1", "role=validator", []string{"validator1"}},
+		{"missing", "", []string{"missing"}},
+		{"missing", "role=api", nil},
+	}
+	for _, test := range tests {
+		names, err := pm.Select(test.pattern, test.selector)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(names, test.expected) {
+			t.Fatalf("PM.Select returned %v expected %v for %q %q", names, test.expected, test.pattern, test.selector)
+		}
+	}
+}
@@ -0,0 +1,238 @@
+/*
+Copyright © 2019 AVA Labs <collin@avalabs.org>
+*/
+
+package processmgr
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// IOClass is an I/O scheduling class
+type IOClass int
+
+// Enum ...
+const (
+	IONone IOClass = iota
+	IORealtime
+	IOBestEffort
+	IOIdle
+)
+
+// ToIOClass ...
+func ToIOClass(s string) (IOClass, error) {
+	switch strings.ToLower(s) {
+	case "":
+		return IONone, nil
+	case "realtime":
+		return IORealtime, nil
+	case "best-effort":
+		return IOBestEffort, nil
+	case "idle":
+		return IOIdle, nil
+	default:
+		return IONone, fmt.Errorf("unknown I/O class: %s", s)
+	}
+}
+
+func (c IOClass) String() string {
+	switch c {
+	case IONone:
+		return ""
+	case IORealtime:
+		return "realtime"
+	case IOBestEffort:
+		return "best-effort"
+	case IOIdle:
+		return "idle"
+	default:
+		return "?????"
+	}
+}
+
+// Limits are resource limits applied to a process whenever it starts.
+// Zero values leave the resource unlimited or unchanged.
+type Limits struct {
+	// AddressSpace caps the virtual memory of the process in bytes (RLIMIT_AS)
+	AddressSpace uint64
+	// NoFile caps the number of open file descriptors (RLIMIT_NOFILE)
+	NoFile uint64
+	// CPUTime caps the CPU time of the process, in whole seconds (RLIMIT_CPU)
+	CPUTime time.Duration
+	// Nice is the scheduling priority, from -20 (highest) to 19 (lowest)
+	Nice int
+	// IOClass and IOLevel are the I/O scheduling class and the priority
+	// within it, from 0 (highest) to 7 (lowest)
+	IOClass IOClass
+	IOLevel int
+}
+
+// ParseLimits validates and builds limits from their textual forms, where `ionice`
+// is "class[:level]" and sizes accept binary suffixes (e.g. 4GiB or 512M)
+func ParseLimits(addressSpace string, noFile uint64, cpuTime time.Duration, nice int, ionice string) (Limits, error) {
+	l := Limits{NoFile: noFile, CPUTime: cpuTime, Nice: nice}
+	var err error
+	if l.AddressSpace, err = ParseSize(addressSpace); err != nil {
+		return Limits{}, err
+	}
+	if cpuTime < 0 {
+		return Limits{}, fmt.Errorf("CPU time limit cannot be negative: %s", cpuTime)
+	}
+	if nice < -20 || nice > 19 {
+		return Limits{}, fmt.Errorf("nice must be between -20 and 19: %d", nice)
+	}
+	class := ionice
+	if i := strings.IndexByte(ionice, ':'); i >= 0 {
+		class = ionice[:i]
+		if l.IOLevel, err = strconv.Atoi(ionice[i+1:]); err != nil || l.IOLevel < 0 || l.IOLevel > 7 {
+			return Limits{}, fmt.Errorf("I/O priority level must be between 0 and 7: %s", ionice[i+1:])
+		}
+	} else if class != "" {
+		// The default level of the kernel
+		l.IOLevel = 4
+	}
+	if l.IOClass, err = ToIOClass(class); err != nil {
+		return Limits{}, err
+	}
+	return l, nil
+}
+
+// ParseSize parses a byte size with an optional binary suffix (K, M, G, T, optionally
+// followed by "B" or "iB"). The empty string is 0.
+func ParseSize(s string) (uint64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+	num := strings.TrimRight(strings.ToUpper(s), "IB")
+	shift := uint(0)
+	if n := len(num); n > 0 {
+		if i := strings.IndexByte("KMGT", num[n-1]); i >= 0 {
+			shift = uint(i+1) * 10
+			num = num[:n-1]
+		}
+	}
+	v, err := strconv.ParseUint(strings.TrimSpace(num), 10, 64)
+	if err != nil || v > (1<<64-1)>>shift {
+		return 0, fmt.Errorf("invalid size: %s", s)
+	}
+	return v << shift, nil
+}
+
+// IsZero returns true if no limit is set
+func (l Limits) IsZero() bool {
+	return l == Limits{}
+}
+
+// Map describes the set limits by name, for recording them in metadata
+func (l Limits) Map() map[string]string {
+	m := make(map[string]string)
+	if l.AddressSpace > 0 {
+		m["address-space"] = formatBytes(int64(l.AddressSpace))
+	}
+	if l.NoFile > 0 {
+		m["nofile"] = strconv.FormatUint(l.NoFile, 10)
+	}
+	if l.CPUTime > 0 {
+		m["cpu-time"] = l.cpuSeconds().String()
+	}
+	if l.Nice != 0 {
+		m["nice"] = strconv.Itoa(l.Nice)
+	}
+	if l.IOClass != IONone {
+		m["ionice"] = fmt.Sprintf("%s:%d", l.IOClass, l.IOLevel)
+	}
+	return m
+}
+
+func (l Limits) String() string {
+	m := l.Map()
+	if len(m) == 0 {
+		return "none"
+	}
+	var pairs []string
+	for _, k := range []string{"address-space", "nofile", "cpu-time", "nice", "ionice"} {
+		if v, ok := m[k]; ok {
+			pairs = append(pairs, k+"="+v)
+		}
+	}
+	return strings.Join(pairs, ", ")
+}
+
+// cpuSeconds returns the CPU time limit rounded up to whole seconds
+func (l Limits) cpuSeconds() time.Duration {
+	return (l.CPUTime + time.Second - 1).Truncate(time.Second)
+}
+
+// rlimits returns the resource limits to set, keyed by resource
+func (l Limits) rlimits() map[int]uint64 {
+	rlimits := make(map[int]uint64)
+	if l.AddressSpace > 0 {
+		rlimits[syscall.RLIMIT_AS] = l.AddressSpace
+	}
+	if l.NoFile > 0 {
+		rlimits[syscall.RLIMIT_NOFILE] = l.NoFile
+	}
+	if l.CPUTime > 0 {
+		rlimits[syscall.RLIMIT_CPU] = uint64(l.cpuSeconds() / time.Second)
+	}
+	return rlimits
+}
+
+// Failure reasons of runs terminated by a resource limit. Exceeding the open
+// file limit fails the calls opening descriptors instead of ending the process,
+// so it has no failure reason of its own.
+const (
+	ReasonAddressSpace = "address space limit exceeded"
+	ReasonCPUTime      = "CPU time limit exceeded"
+)
+
+// exceeded returns the reason a run ending with `err` was terminated by one of
+// the limits, or "" if it was not. The CPU time limit is told by the signal it
+// sends. A failed allocation is only told by its ending together with the
+// message of the failure in the last lines of `stderr`, as a Go panic exits
+// with the same status. This remains a heuristic.
+func (l Limits) exceeded(err error, stderr []string) string {
+	var ee *exec.ExitError
+	if !errors.As(err, &ee) {
+		return ""
+	}
+	ws, _ := ee.Sys().(syscall.WaitStatus)
+	if l.CPUTime > 0 && ws.Signaled() {
+		// The soft limit sends SIGXCPU, and the hard limit SIGKILL
+		switch {
+		case ws.Signal() == syscall.SIGXCPU:
+			return ReasonCPUTime
+		case ws.Signal() == syscall.SIGKILL && ee.UserTime()+ee.SystemTime() >= l.cpuSeconds():
+			return ReasonCPUTime
+		}
+	}
+	// Failed allocations end Go programs with the exit status of a fatal
+	// runtime error, and others by aborting or by faulting on their stack
+	crashed := (ws.Exited() && ws.ExitStatus() == 2) ||
+		(ws.Signaled() && (ws.Signal() == syscall.SIGABRT || ws.Signal() == syscall.SIGSEGV || ws.Signal() == syscall.SIGBUS))
+	if l.AddressSpace > 0 && crashed && allocationFailed(stderr) {
+		return ReasonAddressSpace
+	}
+	return ""
+}
+
+// allocationFailed returns true if the output lines report a failed memory allocation
+func allocationFailed(lines []string) bool {
+	for _, line := range lines {
+		line = strings.ToLower(line)
+		if strings.Contains(line, "out of memory") || strings.Contains(line, "cannot allocate memory") {
+			return true
+		}
+	}
+	return false
+}
+
+// limitOutputLines is the number of last lines of stderr searched for failed allocations
+const limitOutputLines = 50
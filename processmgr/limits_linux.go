@@ -0,0 +1,130 @@
+/*
+Copyright © 2019 AVA Labs <collin@avalabs.org>
+*/
+
+package processmgr
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"runtime"
+	"syscall"
+)
+
+// ioprio_set(2) constants
+const (
+	ioprioWhoProcess = 1
+	ioprioClassShift = 13
+)
+
+// limitsEnv passes the limits of a command to the launcher running it. The
+// launcher is avash itself, started with the command path and arguments.
+const limitsEnv = "AVASH_LIMITS"
+
+// launcherStatusFd is the descriptor the launcher reports its failure on.
+// It is closed on exec, so the end of file tells that the command runs.
+const launcherStatusFd = 3
+
+func init() {
+	if spec, ok := os.LookupEnv(limitsEnv); ok {
+		launch(spec, os.Args[1:])
+	}
+}
+
+// limitCommand makes `cmd` start through the launcher, so that the limits hold
+// from the first instruction of the command. The returned function takes the
+// error of starting `cmd` and waits for the launcher to execute the command.
+func limitCommand(cmd *exec.Cmd, l Limits) (func(error) error, error) {
+	if l.IsZero() {
+		return func(err error) error { return err }, nil
+	}
+	self, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("Unable to find the resource limits launcher: %s", err.Error())
+	}
+	spec, _ := json.Marshal(l)
+	status, w, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	env := cmd.Env
+	if env == nil {
+		env = os.Environ()
+	}
+	cmd.Env = append(env, limitsEnv+"="+string(spec))
+	cmd.Args = append([]string{self, cmd.Path}, cmd.Args...)
+	cmd.Path = self
+	cmd.ExtraFiles = []*os.File{w}
+	return func(err error) error {
+		w.Close()
+		defer status.Close()
+		if err != nil {
+			return err
+		}
+		msg, _ := ioutil.ReadAll(status)
+		if len(msg) > 0 {
+			cmd.Wait()
+			return errors.New(string(msg))
+		}
+		return nil
+	}, nil
+}
+
+// launch applies the limits of `spec` to the current process, then executes
+// the command of `args`, its path followed by its arguments. It never returns.
+func launch(spec string, args []string) {
+	os.Unsetenv(limitsEnv)
+	status := os.NewFile(launcherStatusFd, "status")
+	fail := func(err error) {
+		fmt.Fprint(status, err.Error())
+		os.Exit(127)
+	}
+	var l Limits
+	if err := json.Unmarshal([]byte(spec), &l); err != nil {
+		fail(fmt.Errorf("Invalid resource limits: %s", err.Error()))
+	}
+	if len(args) < 2 {
+		fail(errors.New("No command to launch"))
+	}
+	syscall.CloseOnExec(launcherStatusFd)
+	// Scheduling priorities belong to threads, and the thread executing the
+	// command becomes its main thread
+	runtime.LockOSThread()
+	if err := applyLimits(l); err != nil {
+		fail(fmt.Errorf("Unable to apply resource limits (%s): %s", l, err.Error()))
+	}
+	err := syscall.Exec(args[0], args[1:], os.Environ())
+	fail(fmt.Errorf("exec %s: %s", args[0], err.Error()))
+}
+
+// applyLimits sets the limits on the current thread and process
+func applyLimits(l Limits) error {
+	tid := syscall.Gettid()
+	if l.Nice != 0 {
+		if err := syscall.Setpriority(syscall.PRIO_PROCESS, tid, l.Nice); err != nil {
+			return fmt.Errorf("setpriority failed: %s", err.Error())
+		}
+	}
+	if l.IOClass != IONone {
+		prio := int(l.IOClass)<<ioprioClassShift | l.IOLevel
+		_, _, errno := syscall.RawSyscall(syscall.SYS_IOPRIO_SET, ioprioWhoProcess, uintptr(tid), uintptr(prio))
+		if errno != 0 {
+			return fmt.Errorf("ioprio_set failed: %s", errno.Error())
+		}
+	}
+	// The address space limit is set last, as the allocations following it may fail
+	for _, resource := range []int{syscall.RLIMIT_NOFILE, syscall.RLIMIT_CPU, syscall.RLIMIT_AS} {
+		value, ok := l.rlimits()[resource]
+		if !ok {
+			continue
+		}
+		if err := syscall.Setrlimit(resource, &syscall.Rlimit{Cur: value, Max: value}); err != nil {
+			return fmt.Errorf("setrlimit failed for resource %d: %s", resource, err.Error())
+		}
+	}
+	return nil
+}
@@ -0,0 +1,21 @@
+//go:build !linux
+// +build !linux
+
+/*
+Copyright © 2019 AVA Labs <collin@avalabs.org>
+*/
+
+package processmgr
+
+import (
+	"fmt"
+	"os/exec"
+)
+
+// limitCommand makes `cmd` start with the limits, which are only supported on Linux
+func limitCommand(cmd *exec.Cmd, l Limits) (func(error) error, error) {
+	if !l.IsZero() {
+		return nil, fmt.Errorf("Resource limits are only supported on Linux")
+	}
+	return func(err error) error { return err }, nil
+}
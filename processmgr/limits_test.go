@@ -0,0 +1,142 @@
+package processmgr
+
+import (
+	"os/exec"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSize(t *testing.T) {
+	tests := map[string]uint64{
+		"":     0,
+		"512":  512,
+		"4K":   4 << 10,
+		"8GiB": 8 << 30,
+		"16mb": 16 << 20,
+		" 1T ": 1 << 40,
+		"0KiB": 0,
+		"100B": 100,
+	}
+	for s, expected := range tests {
+		if n, err := ParseSize(s); err != nil {
+			t.Fatal(err)
+		} else if n != expected {
+			t.Fatalf("ParseSize returned %d expected %d for %q", n, expected, s)
+		}
+	}
+	for _, s := range []string{"G", "1.5G", "-1", "1P", "99999999999999999999T"} {
+		if _, err := ParseSize(s); err == nil {
+			t.Fatalf("ParseSize returned no error for %q", s)
+		}
+	}
+}
+
+func TestParseLimits(t *testing.T) {
+	l, err := ParseLimits("1GiB", 4096, 90*time.Second+1, 10, "best-effort")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Limits{AddressSpace: 1 << 30, NoFile: 4096, CPUTime: 90*time.Second + 1, Nice: 10, IOClass: IOBestEffort, IOLevel: 4}
+	if l != expected {
+		t.Fatalf("ParseLimits returned %+v expected %+v", l, expected)
+	}
+	if s := l.String(); s != "address-space=1.0 GiB, nofile=4096, cpu-time=1m31s, nice=10, ionice=best-effort:4" {
+		t.Fatalf("Limits.String returned %s", s)
+	}
+	if l, err := ParseLimits("", 0, 0, 0, ""); err != nil || !l.IsZero() || l.String() != "none" {
+		t.Fatalf("ParseLimits returned %+v, %v expected no limits", l, err)
+	}
+	if l, err := ParseLimits("", 0, 0, 0, "idle:7"); err != nil || l.IOClass != IOIdle || l.IOLevel != 7 {
+		t.Fatalf("ParseLimits returned %+v, %v expected idle:7", l, err)
+	}
+
+	invalid := []struct {
+		as     string
+		cpu    time.Duration
+		nice   int
+		ionice string
+	}{
+		{as: "lots"},
+		{cpu: -time.Second},
+		{nice: 20},
+		{nice: -21},
+		{ionice: "fast"},
+		{ionice: "idle:8"},
+		{ionice: "realtime:"},
+	}
+	for _, test := range invalid {
+		if _, err := ParseLimits(test.as, 0, test.cpu, test.nice, test.ionice); err == nil {
+			t.Fatalf("ParseLimits returned no error for %+v", test)
+		}
+	}
+}
+
+// Returns the error of running the shell script
+func runScript(script string) error {
+	return exec.Command("sh", "-c", script).Run()
+}
+
+func TestLimitsExceeded(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("resource limits require Linux")
+	}
+	l := Limits{AddressSpace: 1 << 30, NoFile: 64, CPUTime: time.Hour}
+	oom := []string{"fatal error: runtime: out of memory"}
+	panicked := []string{"panic: runtime error: index out of range"}
+	tests := []struct {
+		script string
+		stderr []string
+		limits Limits
+		reason string
+	}{
+		{"kill -XCPU $$", nil, l, ReasonCPUTime},
+		{"exit 2", oom, l, ReasonAddressSpace},
+		{"kill -SEGV $$", []string{"mmap: Cannot allocate memory"}, l, ReasonAddressSpace},
+		{"kill -ABRT $$", oom, l, ReasonAddressSpace},
+		// A panic exits with the status of an allocation failure
+		{"exit 2", panicked, l, ""},
+		{"kill -SEGV $$", nil, l, ""},
+		{"exit 1", oom, l, ""},
+		{"kill -KILL $$", nil, l, ""},
+		{"kill -TERM $$", oom, l, ""},
+		{"kill -XCPU $$", nil, Limits{}, ""},
+		{"exit 2", oom, Limits{NoFile: 64}, ""},
+		{"exit 0", oom, l, ""},
+	}
+	for _, test := range tests {
+		if r := test.limits.exceeded(runScript(test.script), test.stderr); r != test.reason {
+			t.Fatalf("Limits.exceeded returned %q expected %q for %q with limits %s", r, test.reason, test.script, test.limits)
+		}
+	}
+}
+
+func TestProcessLimits(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("resource limits require Linux")
+	}
+	p, d := newTestProcess(0)
+	// The limits are read as the command starts, so they must be set before it executes
+	p.cmdstr, p.args = "sh", []string{"-c", "ulimit -n; cut -d ' ' -f 19 /proc/$$/stat; exit 1"}
+	p.stdout, p.stderr = newOutputBuffer(DefaultOutputLines, nil), newOutputBuffer(DefaultOutputLines, nil)
+	p.limits = Limits{NoFile: 64, Nice: 5}
+	wg := syncStart(p, d)
+	<-d
+	wg.Wait()
+
+	if out := strings.Join(p.stdout.Tail(0), "\n"); out != "64\n5" {
+		t.Fatalf("process ran with nofile limit and nice %q expected 64 and 5", out)
+	}
+	if p.state != StateFailed || p.exitCode != 1 || p.reason != "" {
+		t.Fatalf("process ended %s with exit code %d (%s) expected failed with exit code 1", p.state, p.exitCode, p.reason)
+	}
+
+	p, d = newTestProcess(1)
+	p.limits = Limits{NoFile: 64}
+	wg = syncStart(p, d)
+	if <-d {
+		t.Fatalf("process started with limits for a missing command")
+	}
+	wg.Wait()
+}
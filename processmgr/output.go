@@ -0,0 +1,146 @@
+/*
+Copyright © 2019 AVA Labs <collin@avalabs.org>
+*/
+
+package processmgr
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"sync"
+)
+
+// DefaultOutputLines is the number of output lines retained in memory per stream
+const DefaultOutputLines = 1000
+
+// outputBuffer is a bounded, line-oriented ring buffer for a process output stream.
+// Every write is also appended to an optional backing file and forwarded to an
+// optional handler and any followers.
+type outputBuffer struct {
+	lock    sync.Mutex
+	lines   []string
+	start   int
+	count   int
+	partial bytes.Buffer
+	file    *os.File
+	handler OutputHandler
+	subs    map[chan string]struct{}
+}
+
+func newOutputBuffer(size int, handler OutputHandler) *outputBuffer {
+	if size <= 0 {
+		size = DefaultOutputLines
+	}
+	return &outputBuffer{
+		lines:   make([]string, size),
+		handler: handler,
+		subs:    make(map[chan string]struct{}),
+	}
+}
+
+// Write implements io.Writer
+func (ob *outputBuffer) Write(p []byte) (int, error) {
+	ob.lock.Lock()
+	defer ob.lock.Unlock()
+	if ob.file != nil {
+		ob.file.Write(p)
+	}
+	if ob.handler != nil {
+		var b bytes.Buffer
+		b.Write(p)
+		ob.handler(b)
+	}
+	ob.partial.Write(p)
+	for {
+		data := ob.partial.Bytes()
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			break
+		}
+		line := strings.TrimRight(string(data[:i]), "\r")
+		ob.partial.Next(i + 1)
+		ob.push(line)
+	}
+	return len(p), nil
+}
+
+// push appends a line, evicting the oldest one once the buffer is full
+func (ob *outputBuffer) push(line string) {
+	size := len(ob.lines)
+	if ob.count < size {
+		ob.lines[(ob.start+ob.count)%size] = line
+		ob.count++
+	} else {
+		ob.lines[ob.start] = line
+		ob.start = (ob.start + 1) % size
+	}
+	for sub := range ob.subs {
+		// Never block the process on a slow follower
+		select {
+		case sub <- line:
+		default:
+		}
+	}
+}
+
+// Tail returns the last `n` lines held in the buffer, or all of them if `n` <= 0
+func (ob *outputBuffer) Tail(n int) []string {
+	ob.lock.Lock()
+	defer ob.lock.Unlock()
+	if n <= 0 || n > ob.count {
+		n = ob.count
+	}
+	res := make([]string, 0, n)
+	size := len(ob.lines)
+	for i := ob.count - n; i < ob.count; i++ {
+		res = append(res, ob.lines[(ob.start+i)%size])
+	}
+	return res
+}
+
+// Follow returns a channel receiving every new line and a function to stop following
+func (ob *outputBuffer) Follow() (<-chan string, func()) {
+	sub := make(chan string, 256)
+	ob.lock.Lock()
+	ob.subs[sub] = struct{}{}
+	ob.lock.Unlock()
+	var once sync.Once
+	return sub, func() {
+		once.Do(func() {
+			ob.lock.Lock()
+			delete(ob.subs, sub)
+			ob.lock.Unlock()
+			close(sub)
+		})
+	}
+}
+
+// Attach sets the backing file for the buffer, opening it in append mode
+func (ob *outputBuffer) Attach(path string) error {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	ob.lock.Lock()
+	defer ob.lock.Unlock()
+	if ob.file != nil {
+		ob.file.Close()
+	}
+	ob.file = f
+	return nil
+}
+
+// Detach flushes any partial line and closes the backing file
+func (ob *outputBuffer) Detach() {
+	ob.lock.Lock()
+	defer ob.lock.Unlock()
+	if ob.partial.Len() > 0 {
+		ob.push(strings.TrimRight(ob.partial.String(), "\r"))
+		ob.partial.Reset()
+	}
+	if ob.file != nil {
+		ob.file.Close()
+		ob.file = nil
+	}
+}
@@ -0,0 +1,68 @@
+package processmgr
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestOutputBuffer(t *testing.T) {
+	t.Run("Lines", func(t *testing.T) {
+		ob := newOutputBuffer(3, nil)
+		ob.Write([]byte("one\ntwo\nthr"))
+		ob.Write([]byte("ee\n"))
+
+		if lines, expected := ob.Tail(0), []string{"one", "two", "three"}; !reflect.DeepEqual(lines, expected) {
+			t.Fatalf("OB.Tail returned %v expected %v", lines, expected)
+		}
+	})
+	t.Run("Wrap", func(t *testing.T) {
+		ob := newOutputBuffer(2, nil)
+		ob.Write([]byte("one\ntwo\nthree\n"))
+
+		if lines, expected := ob.Tail(0), []string{"two", "three"}; !reflect.DeepEqual(lines, expected) {
+			t.Fatalf("OB.Tail returned %v expected %v", lines, expected)
+		} else if lines, expected := ob.Tail(1), []string{"three"}; !reflect.DeepEqual(lines, expected) {
+			t.Fatalf("OB.Tail returned %v expected %v", lines, expected)
+		}
+	})
+	t.Run("Detach", func(t *testing.T) {
+		ob := newOutputBuffer(2, nil)
+		ob.Write([]byte("partial"))
+		ob.Detach()
+
+		if lines, expected := ob.Tail(0), []string{"partial"}; !reflect.DeepEqual(lines, expected) {
+			t.Fatalf("OB.Tail returned %v expected %v", lines, expected)
+		}
+	})
+	t.Run("Follow", func(t *testing.T) {
+		ob := newOutputBuffer(2, nil)
+		lines, stop := ob.Follow()
+		ob.Write([]byte("one\n"))
+		stop()
+
+		if line := <-lines; line != "one" {
+			t.Fatalf("OB.Follow returned %s expected %s", line, "one")
+		} else if _, ok := <-lines; ok {
+			t.Fatalf("OB.Follow channel open after stop")
+		}
+	})
+}
+
+func TestProcessOutput(t *testing.T) {
+	p := &Process{
+		cmdstr: "sh",
+		args:   []string{"-c", "echo out; echo err 1>&2"},
+		stdout: newOutputBuffer(DefaultOutputLines, nil),
+		stderr: newOutputBuffer(DefaultOutputLines, nil),
+	}
+	d := make(chan bool)
+	wg := syncStart(p, d)
+	<-d
+	wg.Wait()
+
+	if lines, expected := p.stdout.Tail(0), []string{"out"}; !reflect.DeepEqual(lines, expected) {
+		t.Fatalf("P.Stdout returned %v expected %v", lines, expected)
+	} else if lines, expected := p.stderr.Tail(0), []string{"err"}; !reflect.DeepEqual(lines, expected) {
+		t.Fatalf("P.Stderr returned %v expected %v", lines, expected)
+	}
+}
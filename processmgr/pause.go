@@ -0,0 +1,114 @@
+/*
+Copyright © 2019 AVA Labs <collin@avalabs.org>
+*/
+
+package processmgr
+
+import (
+	"fmt"
+	"syscall"
+	"time"
+
+	"github.com/ava-labs/avash/cfg"
+)
+
+// Pause suspends the running process with SIGSTOP, leaving it alive but
+// unresponsive. If `resume` is positive, the process is resumed after it.
+func (p *Process) Pause(resume time.Duration) error {
+	log := cfg.Config.Log
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.state != StateRunning || p.proc == nil {
+		return fmt.Errorf("Process is not running, cannot pause: %s", p.name)
+	}
+	if err := p.proc.Signal(syscall.SIGSTOP); err != nil {
+		return fmt.Errorf("Unable to pause process %s: %s", p.name, err.Error())
+	}
+	p.transition(StatePaused, StateRunning)
+	p.publish(EventPaused, 0, nil)
+	if resume <= 0 {
+		log.Info("Process paused: %s", p.name)
+		return nil
+	}
+	log.Info("Process paused for %s: %s", resume, p.name)
+	var timer *time.Timer
+	timer = time.AfterFunc(resume, func() {
+		p.lock.Lock()
+		defer p.lock.Unlock()
+		// The resume was cancelled or superseded while waiting on the lock
+		if p.resume != timer {
+			return
+		}
+		if err := p.cont(); err != nil {
+			log.Error(err.Error())
+			return
+		}
+		log.Info("Process resumed after %s: %s", resume, p.name)
+	})
+	p.resume = timer
+	return nil
+}
+
+// Resume continues the paused process with SIGCONT
+func (p *Process) Resume() error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.state != StatePaused {
+		return fmt.Errorf("Process is not paused, cannot resume: %s", p.name)
+	}
+	if err := p.cont(); err != nil {
+		return err
+	}
+	cfg.Config.Log.Info("Process resumed: %s", p.name)
+	return nil
+}
+
+// cont continues the paused process, cancelling any pending resume.
+// Must be called with the process lock held.
+func (p *Process) cont() error {
+	p.cancelResume()
+	if p.proc != nil {
+		if err := p.proc.Signal(syscall.SIGCONT); err != nil {
+			return fmt.Errorf("Unable to resume process %s: %s", p.name, err.Error())
+		}
+	}
+	p.transition(StateRunning, StatePaused)
+	p.publish(EventResumed, 0, nil)
+	return nil
+}
+
+// cancelResume cancels a pending resume. Must be called with the process lock held.
+func (p *Process) cancelResume() {
+	if p.resume != nil {
+		p.resume.Stop()
+		p.resume = nil
+	}
+}
+
+// wake sends SIGCONT to the process being stopped, so that it handles the
+// signals sent while it was paused
+func (p *Process) wake() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.proc != nil {
+		p.proc.Signal(syscall.SIGCONT)
+	}
+}
+
+// PauseProcess pauses the process at the name, resuming it after `resume` if positive
+func (pm *ProcessManager) PauseProcess(name string, resume time.Duration) error {
+	p, err := pm.get(name, "pause")
+	if err != nil {
+		return err
+	}
+	return p.Pause(resume)
+}
+
+// ResumeProcess resumes the paused process at the name
+func (pm *ProcessManager) ResumeProcess(name string) error {
+	p, err := pm.get(name, "resume")
+	if err != nil {
+		return err
+	}
+	return p.Resume()
+}
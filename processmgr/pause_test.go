@@ -0,0 +1,110 @@
+package processmgr
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// Returns the kernel state letter of the process, e.g. T if stopped
+func kernelState(t *testing.T, pid int) string {
+	stat, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The state follows the parenthesized command name
+	fields := strings.Fields(string(stat[strings.LastIndex(string(stat), ")")+1:]))
+	return fields[0]
+}
+
+// Waits for the kernel state of the process to become `state`
+func waitKernelState(t *testing.T, pid int, state string) {
+	deadline := time.Now().Add(2 * time.Second)
+	for kernelState(t, pid) != state {
+		if time.Now().After(deadline) {
+			t.Fatalf("process %d has state %s expected %s", pid, kernelState(t, pid), state)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestProcessPause(t *testing.T) {
+	p, d := newTestProcess(0)
+	wg := syncStart(p, d)
+	<-d
+	defer killTestProcesses(p)
+	pid := osProcess(p).Pid
+
+	if err := p.Resume(); err == nil {
+		t.Fatalf("P.Resume returned no error for a running process")
+	}
+	if err := p.Pause(0); err != nil {
+		t.Fatal(err)
+	}
+	if state := p.State(); state != StatePaused {
+		t.Fatalf("P.State returned %s expected %s", state, StatePaused)
+	}
+	waitKernelState(t, pid, "T")
+	if err := p.Pause(0); err == nil {
+		t.Fatalf("P.Pause returned no error for a paused process")
+	}
+	if err := p.Resume(); err != nil {
+		t.Fatal(err)
+	}
+	if state := p.State(); state != StateRunning {
+		t.Fatalf("P.State returned %s expected %s", state, StateRunning)
+	}
+	waitKernelState(t, pid, "S")
+
+	t.Run("AutoResume", func(t *testing.T) {
+		if err := p.Pause(50 * time.Millisecond); err != nil {
+			t.Fatal(err)
+		}
+		waitKernelState(t, pid, "T")
+		waitKernelState(t, pid, "S")
+		if state := p.State(); state != StateRunning {
+			t.Fatalf("P.State returned %s expected %s", state, StateRunning)
+		}
+	})
+	t.Run("StopPaused", func(t *testing.T) {
+		if err := p.Pause(time.Minute); err != nil {
+			t.Fatal(err)
+		}
+		waitKernelState(t, pid, "T")
+		if err := p.StopTimeout(2 * time.Second); err != nil {
+			t.Fatal(err)
+		}
+		wg.Wait()
+		if state := p.State(); state != StateStopped {
+			t.Fatalf("P.State returned %s expected %s", state, StateStopped)
+		} else if sig := p.EndSignal(); sig != syscall.SIGINT {
+			t.Fatalf("P.EndSignal returned %s expected %s", signalName(sig), signalName(syscall.SIGINT))
+		}
+		p.lock.Lock()
+		defer p.lock.Unlock()
+		if p.resume != nil {
+			t.Fatalf("P.Resume timer pending after stop")
+		}
+	})
+}
+
+func TestProcessKillPaused(t *testing.T) {
+	p, d := newTestProcess(0)
+	wg := syncStart(p, d)
+	<-d
+	defer killTestProcesses(p)
+
+	if err := p.Pause(0); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Kill(); err != nil {
+		t.Fatal(err)
+	}
+	wg.Wait()
+	if state := p.State(); state != StateStopped {
+		t.Fatalf("P.State returned %s expected %s", state, StateStopped)
+	}
+}
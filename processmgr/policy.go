@@ -0,0 +1,110 @@
+/*
+Copyright © 2019 AVA Labs <collin@avalabs.org>
+*/
+
+package processmgr
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// RestartPolicy determines whether a process is restarted after exiting on its own
+type RestartPolicy int
+
+// Enum ...
+const (
+	RestartNever RestartPolicy = iota
+	RestartOnFailure
+	RestartAlways
+)
+
+// ToRestartPolicy ...
+func ToRestartPolicy(s string) (RestartPolicy, error) {
+	switch strings.ToLower(s) {
+	case "never", "":
+		return RestartNever, nil
+	case "on-failure":
+		return RestartOnFailure, nil
+	case "always":
+		return RestartAlways, nil
+	default:
+		return RestartNever, fmt.Errorf("unknown restart policy: %s", s)
+	}
+}
+
+func (r RestartPolicy) String() string {
+	switch r {
+	case RestartNever:
+		return "never"
+	case RestartOnFailure:
+		return "on-failure"
+	case RestartAlways:
+		return "always"
+	default:
+		return "?????"
+	}
+}
+
+// maxBackoff caps the delay between consecutive restart attempts
+const maxBackoff = 5 * time.Minute
+
+// Policy declares how a process is supervised once it exits on its own
+type Policy struct {
+	Restart     RestartPolicy
+	MaxRestarts int           // 0 allows unlimited restarts
+	Backoff     time.Duration // initial delay, doubled on every attempt
+}
+
+// DefaultPolicy returns a policy that never restarts a process
+func DefaultPolicy() Policy {
+	return Policy{
+		Restart:     RestartNever,
+		MaxRestarts: 5,
+		Backoff:     time.Second,
+	}
+}
+
+func (pol Policy) String() string {
+	return fmt.Sprintf("%s (max restarts: %d, backoff: %s)", pol.Restart, pol.MaxRestarts, pol.Backoff)
+}
+
+// shouldRestart returns true if a process exiting with `err` after `restarts` restarts may be restarted
+func (pol Policy) shouldRestart(err error, restarts int) bool {
+	if pol.MaxRestarts > 0 && restarts >= pol.MaxRestarts {
+		return false
+	}
+	switch pol.Restart {
+	case RestartAlways:
+		return true
+	case RestartOnFailure:
+		return err != nil
+	default:
+		return false
+	}
+}
+
+// backoff returns the delay before the restart following `restarts` restarts
+func (pol Policy) backoff(restarts int) time.Duration {
+	delay := pol.Backoff
+	for i := 0; i < restarts && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxBackoff {
+		delay = maxBackoff
+	}
+	return delay
+}
+
+// exitCode returns the exit code carried by an error returned from `exec.Cmd.Wait`
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		return exitErr.ExitCode()
+	}
+	return -1
+}
@@ -0,0 +1,64 @@
+package processmgr
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestToRestartPolicy(t *testing.T) {
+	for _, r := range []RestartPolicy{RestartNever, RestartOnFailure, RestartAlways} {
+		if res, err := ToRestartPolicy(r.String()); err != nil {
+			t.Fatalf("ToRestartPolicy returned error %v for %s", err, r)
+		} else if res != r {
+			t.Fatalf("ToRestartPolicy returned %s expected %s", res, r)
+		}
+	}
+	if _, err := ToRestartPolicy("sometimes"); err == nil {
+		t.Fatalf("ToRestartPolicy returned %v expected error", err)
+	}
+}
+
+func TestPolicyShouldRestart(t *testing.T) {
+	failure := errors.New("exit status 1")
+
+	t.Run("Never", func(t *testing.T) {
+		pol := Policy{Restart: RestartNever}
+		if pol.shouldRestart(failure, 0) {
+			t.Fatalf("Policy.shouldRestart returned %t expected %t", true, false)
+		}
+	})
+	t.Run("OnFailure", func(t *testing.T) {
+		pol := Policy{Restart: RestartOnFailure}
+		if !pol.shouldRestart(failure, 0) {
+			t.Fatalf("Policy.shouldRestart returned %t expected %t", false, true)
+		} else if pol.shouldRestart(nil, 0) {
+			t.Fatalf("Policy.shouldRestart returned %t expected %t", true, false)
+		}
+	})
+	t.Run("Always", func(t *testing.T) {
+		pol := Policy{Restart: RestartAlways}
+		if !pol.shouldRestart(nil, 0) {
+			t.Fatalf("Policy.shouldRestart returned %t expected %t", false, true)
+		}
+	})
+	t.Run("MaxRestarts", func(t *testing.T) {
+		pol := Policy{Restart: RestartAlways, MaxRestarts: 2}
+		if !pol.shouldRestart(failure, 1) {
+			t.Fatalf("Policy.shouldRestart returned %t expected %t", false, true)
+		} else if pol.shouldRestart(failure, 2) {
+			t.Fatalf("Policy.shouldRestart returned %t expected %t", true, false)
+		}
+	})
+}
+
+func TestPolicyBackoff(t *testing.T) {
+	pol := Policy{Backoff: time.Second}
+	if delay := pol.backoff(0); delay != time.Second {
+		t.Fatalf("Policy.backoff returned %s expected %s", delay, time.Second)
+	} else if delay := pol.backoff(3); delay != 8*time.Second {
+		t.Fatalf("Policy.backoff returned %s expected %s", delay, 8*time.Second)
+	} else if delay := pol.backoff(100); delay != maxBackoff {
+		t.Fatalf("Policy.backoff returned %s expected %s", delay, maxBackoff)
+	}
+}
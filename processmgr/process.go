@@ -10,8 +10,13 @@ import (
 	"io"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/ava-labs/avash/cfg"
+	"github.com/kennygrant/sanitize"
 )
 
 // InputHandler is a generic function for handling input from cin
@@ -20,154 +25,400 @@ type InputHandler func(p []byte) (bool, error)
 // OutputHandler recieves the information
 type OutputHandler func(b bytes.Buffer) error
 
-// Process declares the necessary data for tracking a process
+// DefaultStopTimeout is how long a stopping process is given to exit after each
+// signal before escalating to the next one
+const DefaultStopTimeout = 10 * time.Second
+
+// stopSignals is the escalating sequence of signals sent to stop a process
+var stopSignals = []syscall.Signal{syscall.SIGINT, syscall.SIGTERM, syscall.SIGKILL}
+
+// Process declares the necessary data for tracking a process.
+// All mutable fields are guarded by `lock`, and lifecycle changes happen
+// only through `transition`.
 type Process struct {
+	lock      sync.Mutex
 	cmdstr    string
 	args      []string
 	cmd       *exec.Cmd
+	proc      *os.Process
 	name      string
+	gen       uint64
 	proctype  string
 	metadata  string
-	running   bool
-	failed    bool
+	// generated is set if avash generated the metadata, so may complete it
+	generated bool
+	version   string
+	labels    Labels
+	env       []string
+	dir       string
+	artifacts []string
+	state     State
+	removed   bool
+	events    *EventBus
+	session   *sessionRegistry
+	crashes   *sync.WaitGroup
+	startedAt time.Time
+	cpuPID    int
+	cpuTicks  uint64
+	cpuAt     time.Time
+	exited    chan struct{}
+	policy    Policy
+	limits    Limits
+	reason    string
+	restarts  int
+	exitCode  int
+	endSignal syscall.Signal
+	failedAt  time.Time
+	restart   *time.Timer
+	resume    *time.Timer
 	output    io.ReadCloser
 	errput    io.ReadCloser
 	input     io.WriteCloser
 	cout      chan []byte
 	cerr      chan []byte
 	cin       chan []byte
-	stop      chan bool
-	kill      chan bool
-	fail      chan error
+	stdout    *outputBuffer
+	stderr    *outputBuffer
 	inhandle  InputHandler
 	outhandle OutputHandler
 	errhandle OutputHandler
 }
 
-// Start begins a new process
+// Start begins a new process, sending the outcome of launching it on `done`
+// and then blocking until it exits
 func (p *Process) Start(done chan bool) {
 	log := cfg.Config.Log
-	if p.running {
+	p.lock.Lock()
+	if p.removed {
+		p.lock.Unlock()
+		log.Error("Process has been removed, cannot start: %s", p.name)
+		done <- false
+		return
+	}
+	if !p.transition(StateStarting, StateStopped, StateFailed) {
+		p.lock.Unlock()
 		log.Error("Process is already running, cannot start: %s", p.name)
 		done <- true
 		return
 	}
+	limits := p.limits
+	env, dir := p.env, p.dir
+	p.lock.Unlock()
+
 	log.Info("Starting process %s.", p.name)
-	p.cmd = exec.Command(p.cmdstr, p.args...)
-	log.Info("Command: %s\n", p.cmd.Args)
-
-	selfStopped := false
-	go func() {
-		err := p.cmd.Start()
-		if err != nil {
-			p.fail <- err
-			return
-		}
-		p.running = true
-		p.failed = false
-		done <- true
-		err = p.cmd.Wait()
-		if !selfStopped {
-			p.fail <- err
-		}
-	}()
+	cmd := exec.Command(p.cmdstr, p.args...)
+	cmd.Env = environ(env)
+	cmd.Dir = dir
+	// In its own process group, the process does not receive the Ctrl-C meant for avash
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	log.Info("Command: %s\n", cmd.Args)
+	if err := p.attachOutput(cmd); err != nil {
+		log.Error("Unable to capture output for process %s: %s", p.name, err.Error())
+	}
+	launched, err := limitCommand(cmd, limits)
+	if err == nil {
+		err = launched(cmd.Start())
+	}
 
-	closegen := func() {
-		p.cmd.Stdin = nil
-		p.cmd.Stderr = nil
-		p.cmd.Stdout = nil
-		p.cmd.Wait()
-		p.cmd.Process = nil
+	p.lock.Lock()
+	p.cmd = cmd
+	if err != nil {
+		p.detachOutput()
+		p.recordFailure(err)
+		p.transition(StateFailed, StateStarting)
+		p.publish(EventFailed, p.exitCode, err)
+		p.collectCrash(err)
+		p.lock.Unlock()
+		log.Error("Process failure: %s: %s", p.name, err.Error())
+		done <- false
+		return
 	}
+	p.transition(StateRunning, StateStarting)
+	p.proc = cmd.Process
+	p.startedAt = time.Now()
+	p.endSignal = 0
+	p.register()
+	p.publish(EventStarted, 0, nil)
+	exited := make(chan struct{})
+	p.exited = exited
+	p.lock.Unlock()
+	done <- true
 
-	defer closegen()
+	err = cmd.Wait()
+	p.detachOutput()
+	p.exit(exited, err)
+}
 
-	for {
-		select {
-		case sp := <-p.stop:
-			log.Info("Calling stop() on %s", p.name)
-			if sp {
-				selfStopped = true
-				if err := p.endProcess(false); err != nil {
-					log.Error("SIGINT failed on process: %s: %s", p.name, err.Error())
-					p.stop <- false
-					return
-				}
-				log.Info("SIGINT called on process: %s", p.name)
-				p.stop <- true
-				return
-			}
-		case kl := <-p.kill:
-			log.Info("Calling kill() on %s.", p.name)
-			if kl {
-				selfStopped = true
-				if err := p.endProcess(true); err != nil {
-					log.Error("SIGTERM failed on process: %s: %s", p.name, err.Error())
-					p.kill <- false
-					return
-				}
-				log.Info("SIGTERM called on process: %s", p.name)
-				p.kill <- true
-				return
-			}
-		case fl := <-p.fail:
-			p.failed = true
-			errMsg := "inspect for process validity (command, args, flags) or FATAL output in related logs"
-			if fl != nil {
-				errMsg = fl.Error()
-			}
-			log.Error("Process failure: %s: %s", p.name, errMsg)
-			// Specific case for a bad `p.cmd.Start()` call
-			if !p.running {
-				done <- false
-			}
-			p.running = false
-			return
-		}
+// exit records the outcome of a run that ended with `err`, closing `exited`
+// and scheduling a restart if the policy allows it
+func (p *Process) exit(exited chan struct{}, err error) {
+	log := cfg.Config.Log
+	p.unregister()
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	defer close(exited)
+	p.proc = nil
+	p.cancelResume()
+	if p.transition(StateStopped, StateStopping) {
+		p.publish(EventStopped, exitCode(err), nil)
+		return
+	}
+	p.transition(StateFailed, StateRunning, StatePaused)
+	p.recordFailure(err)
+	var stderr []string
+	if p.stderr != nil {
+		stderr = p.stderr.Tail(limitOutputLines)
+	}
+	p.reason = p.limits.exceeded(err, stderr)
+	p.publish(EventFailed, p.exitCode, err)
+	errMsg := "inspect for process validity (command, args, flags) or FATAL output in related logs"
+	if err != nil {
+		errMsg = err.Error()
 	}
+	if p.reason != "" {
+		errMsg = p.reason + ": " + errMsg
+	}
+	log.Error("Process failure: %s: %s", p.name, errMsg)
+	p.collectCrash(err)
+	p.scheduleRestart(err)
 }
 
-// Stop ends a process with SIGINT
+// Stop ends a process gracefully, escalating after DefaultStopTimeout
 func (p *Process) Stop() error {
-	if !p.running {
-		return fmt.Errorf("Process is not running, cannot stop: %s", p.name)
+	return p.StopTimeout(DefaultStopTimeout)
+}
+
+// StopTimeout ends a process with SIGINT. If it has not exited after `timeout`
+// it is sent SIGTERM, and after another `timeout` SIGKILL.
+func (p *Process) StopTimeout(timeout time.Duration) error {
+	return p.end(stopSignals, timeout)
+}
+
+// Kill ends a process immediately with SIGKILL
+func (p *Process) Kill() error {
+	return p.end(stopSignals[len(stopSignals)-1:], 0)
+}
+
+// end sends `signals` to the running process in order, waiting up to `timeout`
+// after each for it to exit. The last signal is waited on without a deadline.
+// A kill also ends a process being stopped, without waiting for the stop to escalate.
+func (p *Process) end(signals []syscall.Signal, timeout time.Duration) error {
+	log := cfg.Config.Log
+	action := "stop"
+	if len(signals) == 1 {
+		action = "kill"
+	}
+	if p.cancelRestart() {
+		return nil
+	}
+	p.lock.Lock()
+	paused := p.state == StatePaused
+	escalate := action == "kill" && p.state == StateStopping
+	if !escalate && !p.transition(StateStopping, StateRunning, StatePaused) {
+		p.lock.Unlock()
+		return fmt.Errorf("Process is not running, cannot %s: %s", action, p.name)
 	}
-	p.stop <- true
-	result := <-p.stop
-	p.running = false
-	if !result {
-		p.failed = true
-		return fmt.Errorf("Unable to properly stop process: %s", p.name)
+	p.cancelResume()
+	log.Info("Calling %s() on %s", action, p.name)
+	exited := p.exited
+	p.lock.Unlock()
+	for i, sig := range signals {
+		if err := p.signal(sig); err != nil {
+			select {
+			case <-exited:
+				// The process exited on its own in the meantime
+				return nil
+			default:
+			}
+			p.lock.Lock()
+			p.transition(StateFailed, StateStopping)
+			p.lock.Unlock()
+			log.Error("%s failed on process: %s: %s", signalName(sig), p.name, err.Error())
+			return fmt.Errorf("Unable to properly %s process: %s", action, p.name)
+		}
+		if paused {
+			// A stopped process only handles the signal once continued
+			p.wake()
+		}
+		var deadline <-chan time.Time
+		if i < len(signals)-1 {
+			deadline = time.After(timeout)
+		}
+		select {
+		case <-exited:
+			log.Info("Process %s ended by %s", p.name, signalName(p.EndSignal()))
+			return nil
+		case <-deadline:
+			log.Warn("Process %s did not exit within %s of %s, sending %s", p.name, timeout, signalName(sig), signalName(signals[i+1]))
+		}
 	}
 	return nil
 }
 
-// Kill ends a process with SIGTERM
-func (p *Process) Kill() error {
-	if !p.running {
-		return fmt.Errorf("Process is not running, cannot kill: %s", p.name)
+// signal sends `sig` to the running process, recording it as the signal that ends the run
+func (p *Process) signal(sig syscall.Signal) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.proc == nil {
+		// Already exited, the run ended by a previous signal
+		return nil
 	}
-	p.kill <- true
-	result := <-p.kill
-	p.running = false
-	if !result {
-		p.failed = true
-		return fmt.Errorf("Unable to properly kill process: %s", p.name)
+	if err := p.proc.Signal(sig); err != nil {
+		return err
 	}
+	p.endSignal = sig
 	return nil
 }
 
-func (p *Process) endProcess(killer bool) error {
-	if killer {
-		if err := p.cmd.Process.Kill(); err != nil {
+// EndSignal returns the signal that ended the last run stopped through avash, or 0
+func (p *Process) EndSignal() syscall.Signal {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.endSignal
+}
+
+// signalName returns the conventional name of a stop signal
+func signalName(sig syscall.Signal) string {
+	switch sig {
+	case syscall.SIGINT:
+		return "SIGINT"
+	case syscall.SIGTERM:
+		return "SIGTERM"
+	case syscall.SIGKILL:
+		return "SIGKILL"
+	default:
+		return sig.String()
+	}
+}
+
+// recordFailure stores the exit status of a failed run. Must be called with the process lock held.
+func (p *Process) recordFailure(err error) {
+	p.exitCode = exitCode(err)
+	p.failedAt = time.Now()
+	p.reason = ""
+}
+
+// retire marks the process as removed so it can never start again, stopping it if needed
+func (p *Process) retire() error {
+	p.lock.Lock()
+	if p.state == StateStarting {
+		p.lock.Unlock()
+		return fmt.Errorf("Process is starting, cannot remove: %s", p.name)
+	}
+	p.removed = true
+	running := p.state.alive()
+	exited := p.exited
+	p.lock.Unlock()
+	p.cancelRestart()
+	if !running {
+		return nil
+	}
+	if err := p.Stop(); err != nil {
+		select {
+		case <-exited:
+		default:
+			p.lock.Lock()
+			p.removed = false
+			p.lock.Unlock()
 			return err
 		}
-	} else {
-		if err := p.cmd.Process.Signal(os.Interrupt); err != nil {
-			if err := p.cmd.Process.Kill(); err != nil {
-				return err
-			}
-		}
 	}
 	return nil
 }
+
+// scheduleRestart restarts the process after a backoff delay if its policy allows it.
+// Must be called with the process lock held.
+func (p *Process) scheduleRestart(err error) {
+	log := cfg.Config.Log
+	if !p.policy.shouldRestart(err, p.restarts) {
+		if p.policy.Restart != RestartNever {
+			log.Error("Process %s will not be restarted after %d restarts", p.name, p.restarts)
+		}
+		return
+	}
+	delay := p.policy.backoff(p.restarts)
+	p.restarts++
+	log.Info("Restarting process %s in %s (attempt %d)", p.name, delay, p.restarts)
+	p.publish(EventRestarting, p.exitCode, nil)
+	var timer *time.Timer
+	timer = time.AfterFunc(delay, func() {
+		p.lock.Lock()
+		// The restart was cancelled or superseded while waiting on the lock
+		if p.restart != timer || p.removed {
+			p.lock.Unlock()
+			return
+		}
+		p.restart = nil
+		p.lock.Unlock()
+		done := make(chan bool)
+		go p.Start(done)
+		<-done
+	})
+	p.restart = timer
+}
+
+// cancelRestart cancels a pending restart, returning true if there was one
+func (p *Process) cancelRestart() bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.restart == nil {
+		return false
+	}
+	p.restart.Stop()
+	p.restart = nil
+	cfg.Config.Log.Info("Pending restart cancelled for process: %s", p.name)
+	return true
+}
+
+// resetRestarts cancels a pending restart and clears the restart count
+func (p *Process) resetRestarts() {
+	p.cancelRestart()
+	p.lock.Lock()
+	p.restarts = 0
+	p.lock.Unlock()
+}
+
+// OutputDir returns the stash directory where the process output is written
+func (p *Process) OutputDir() string {
+	if cfg.Config.DataDir == "" {
+		return ""
+	}
+	return filepath.Join(cfg.Config.DataDir, sanitize.BaseName(p.name))
+}
+
+// attachOutput connects the command's stdout and stderr to the output buffers,
+// also mirroring them to files in the process output directory if available
+func (p *Process) attachOutput(cmd *exec.Cmd) error {
+	if p.stdout == nil || p.stderr == nil {
+		return nil
+	}
+	cmd.Stdout = p.stdout
+	cmd.Stderr = p.stderr
+	dir := p.OutputDir()
+	if dir == "" {
+		return nil
+	}
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return err
+	}
+	if err := p.stdout.Attach(filepath.Join(dir, "stdout.log")); err != nil {
+		return err
+	}
+	return p.stderr.Attach(filepath.Join(dir, "stderr.log"))
+}
+
+func (p *Process) detachOutput() {
+	if p.stdout != nil {
+		p.stdout.Detach()
+	}
+	if p.stderr != nil {
+		p.stderr.Detach()
+	}
+}
+
+func (p *Process) outputBuffer(stderr bool) *outputBuffer {
+	if stderr {
+		return p.stderr
+	}
+	return p.stdout
+}
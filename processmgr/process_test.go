@@ -1,8 +1,11 @@
 package processmgr
 
 import (
+	"os"
 	"sync"
+	"syscall"
 	"testing"
+	"time"
 )
 
 func newTestProcess(code uint) (*Process, chan bool) {
@@ -19,12 +22,25 @@ func newTestProcess(code uint) (*Process, chan bool) {
 	return &Process{
 		cmdstr: cmdstr,
 		args:   args,
-		stop:   make(chan bool),
-		kill:   make(chan bool),
-		fail:   make(chan error),
 	}, make(chan bool)
 }
 
+// Returns the OS process of `p`'s current run, if any
+func osProcess(p *Process) *os.Process {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.proc
+}
+
+// Kills the OS processes left running by `ps`
+func killTestProcesses(ps ...*Process) {
+	for _, p := range ps {
+		if proc := osProcess(p); proc != nil {
+			proc.Kill()
+		}
+	}
+}
+
 // Calls `p.Start` with `d` and returns a `WaitGroup` to block on `p` stopping
 func syncStart(p *Process, d chan bool) *sync.WaitGroup {
 	var wg sync.WaitGroup
@@ -49,24 +65,26 @@ func TestProcessStart(t *testing.T) {
 		<-d1
 
 		t.Logf("%+v", p1)
-		if proc := p1.cmd.Process; proc == nil {
+		if proc := osProcess(p1); proc == nil {
 			t.Fatalf("P.Cmd.Process returned %v expected not %v", proc, nil)
-		} else if running := p1.running; running != true {
-			t.Fatalf("P.Running returned %t expected %t", running, true)
-		} else if failed := p1.failed; failed != false {
-			t.Fatalf("P.Failed returned %t expected %t", failed, false)
+		} else if state := p1.State(); state != StateRunning {
+			t.Fatalf("P.State returned %s expected %s", state, StateRunning)
+		}
+	})
+	t.Run("ProcessGroup", func(t *testing.T) {
+		pid := osProcess(p1).Pid
+		if pgid, err := syscall.Getpgid(pid); err != nil || pgid != pid {
+			t.Fatalf("process ran in process group %d, %v expected its own", pgid, err)
 		}
 	})
 	t.Run("BadExec", func(t *testing.T) {
 		go p2.Start(d2)
 		<-d2
 
-		if proc := p2.cmd.Process; proc != nil {
+		if proc := osProcess(p2); proc != nil {
 			t.Fatalf("P.Cmd.Process returned %v expected %v", proc, nil)
-		} else if running := p2.running; running != false {
-			t.Fatalf("P.Running returned %t expected %t", running, false)
-		} else if failed := p2.failed; failed != true {
-			t.Fatalf("P.Failed returned %t expected %t", failed, true)
+		} else if state := p2.State(); state != StateFailed {
+			t.Fatalf("P.State returned %s expected %s", state, StateFailed)
 		}
 	})
 	t.Run("KilledExec", func(t *testing.T) {
@@ -76,15 +94,13 @@ func TestProcessStart(t *testing.T) {
 			done <- true
 		}()
 		<-d3
-		p3.cmd.Process.Kill()
+		osProcess(p3).Kill()
 		<-done
 
-		if proc := p3.cmd.Process; proc != nil {
+		if proc := osProcess(p3); proc != nil {
 			t.Fatalf("P.Cmd.Process returned %v expected %v", proc, nil)
-		} else if running := p3.running; running != false {
-			t.Fatalf("P.Running returned %t expected %t", running, false)
-		} else if failed := p3.failed; failed != true {
-			t.Fatalf("P.Failed returned %t expected %t", failed, true)
+		} else if state := p3.State(); state != StateFailed {
+			t.Fatalf("P.State returned %s expected %s", state, StateFailed)
 		}
 	})
 	t.Run("Running", func(t *testing.T) {
@@ -93,59 +109,36 @@ func TestProcessStart(t *testing.T) {
 		go p4.Start(d4)
 		<-d4
 
-		if proc := p4.cmd.Process; proc == nil {
+		if proc := osProcess(p4); proc == nil {
 			t.Fatalf("P.Cmd.Process returned %v expected not %v", proc, nil)
-		} else if running := p4.running; running != true {
-			t.Fatalf("P.Running returned %t expected %t", running, true)
-		} else if failed := p4.failed; failed != false {
-			t.Fatalf("P.Failed returned %t expected %t", failed, false)
+		} else if state := p4.State(); state != StateRunning {
+			t.Fatalf("P.State returned %s expected %s", state, StateRunning)
 		}
 	})
 	t.Run("GoodFailed", func(t *testing.T) {
-		p5.failed = true
+		p5.state = StateFailed
 		go p5.Start(d5)
 		<-d5
 
-		if proc := p5.cmd.Process; proc == nil {
+		if proc := osProcess(p5); proc == nil {
 			t.Fatalf("P.Cmd.Process returned %v expected not %v", proc, nil)
-		} else if running := p5.running; running != true {
-			t.Fatalf("P.Running returned %t expected %t", running, true)
-		} else if failed := p5.failed; failed != false {
-			t.Fatalf("P.Failed returned %t expected %t", failed, false)
+		} else if state := p5.State(); state != StateRunning {
+			t.Fatalf("P.State returned %s expected %s", state, StateRunning)
 		}
 	})
 	t.Run("BadFailed", func(t *testing.T) {
-		p6.failed = true
+		p6.state = StateFailed
 		go p6.Start(d6)
 		<-d6
 
-		if proc := p6.cmd.Process; proc != nil {
+		if proc := osProcess(p6); proc != nil {
 			t.Fatalf("P.Cmd.Process returned %v expected %v", proc, nil)
-		} else if running := p6.running; running != false {
-			t.Fatalf("P.Running returned %t expected %t", running, false)
-		} else if failed := p6.failed; failed != true {
-			t.Fatalf("P.Failed returned %t expected %t", failed, true)
+		} else if state := p6.State(); state != StateFailed {
+			t.Fatalf("P.State returned %s expected %s", state, StateFailed)
 		}
 	})
 
-	if p1.cmd.Process != nil {
-		p1.cmd.Process.Kill()
-	}
-	if p2.cmd.Process != nil {
-		p2.cmd.Process.Kill()
-	}
-	if p3.cmd.Process != nil {
-		p3.cmd.Process.Kill()
-	}
-	if p4.cmd.Process != nil {
-		p4.cmd.Process.Kill()
-	}
-	if p5.cmd.Process != nil {
-		p5.cmd.Process.Kill()
-	}
-	if p6.cmd.Process != nil {
-		p6.cmd.Process.Kill()
-	}
+	killTestProcesses(p1, p2, p3, p4, p5, p6)
 }
 
 func TestProcessStop(t *testing.T) {
@@ -159,12 +152,10 @@ func TestProcessStop(t *testing.T) {
 		p1.Stop()
 		wg.Wait()
 
-		if proc := p1.cmd.Process; proc != nil {
+		if proc := osProcess(p1); proc != nil {
 			t.Fatalf("P.Cmd.Process returned %v expected %v", proc, nil)
-		} else if running := p1.running; running != false {
-			t.Fatalf("P.Running returned %t expected %t", running, false)
-		} else if failed := p1.failed; failed != false {
-			t.Fatalf("P.Failed returned %t expected %t", failed, false)
+		} else if state := p1.State(); state != StateStopped {
+			t.Fatalf("P.State returned %s expected %s", state, StateStopped)
 		}
 	})
 	t.Run("Stopped", func(t *testing.T) {
@@ -174,12 +165,10 @@ func TestProcessStop(t *testing.T) {
 		p2.Stop()
 		wg.Wait()
 
-		if proc := p2.cmd.Process; proc != nil {
+		if proc := osProcess(p2); proc != nil {
 			t.Fatalf("P.Cmd.Process returned %v expected %v", proc, nil)
-		} else if running := p2.running; running != false {
-			t.Fatalf("P.Running returned %t expected %t", running, false)
-		} else if failed := p2.failed; failed != false {
-			t.Fatalf("P.Failed returned %t expected %t", failed, false)
+		} else if state := p2.State(); state != StateStopped {
+			t.Fatalf("P.State returned %s expected %s", state, StateStopped)
 		}
 	})
 	t.Run("Failed", func(t *testing.T) {
@@ -188,24 +177,14 @@ func TestProcessStop(t *testing.T) {
 		p3.Stop()
 		wg.Wait()
 
-		if proc := p3.cmd.Process; proc != nil {
+		if proc := osProcess(p3); proc != nil {
 			t.Fatalf("P.Cmd.Process returned %v expected %v", proc, nil)
-		} else if running := p3.running; running != false {
-			t.Fatalf("P.Running returned %t expected %t", running, false)
-		} else if failed := p3.failed; failed != true {
-			t.Fatalf("P.Failed returned %t expected %t", failed, true)
+		} else if state := p3.State(); state != StateFailed {
+			t.Fatalf("P.State returned %s expected %s", state, StateFailed)
 		}
 	})
 
-	if p1.cmd.Process != nil {
-		p1.cmd.Process.Kill()
-	}
-	if p2.cmd.Process != nil {
-		p2.cmd.Process.Kill()
-	}
-	if p3.cmd.Process != nil {
-		p3.cmd.Process.Kill()
-	}
+	killTestProcesses(p1, p2, p3)
 }
 
 func TestProcessKill(t *testing.T) {
@@ -219,12 +198,10 @@ func TestProcessKill(t *testing.T) {
 		p1.Kill()
 		wg.Wait()
 
-		if proc := p1.cmd.Process; proc != nil {
+		if proc := osProcess(p1); proc != nil {
 			t.Fatalf("P.Cmd.Process returned %v expected %v", proc, nil)
-		} else if running := p1.running; running != false {
-			t.Fatalf("P.Running returned %t expected %t", running, false)
-		} else if failed := p1.failed; failed != false {
-			t.Fatalf("P.Failed returned %t expected %t", failed, false)
+		} else if state := p1.State(); state != StateStopped {
+			t.Fatalf("P.State returned %s expected %s", state, StateStopped)
 		}
 	})
 	t.Run("Stopped", func(t *testing.T) {
@@ -234,12 +211,10 @@ func TestProcessKill(t *testing.T) {
 		p2.Kill()
 		wg.Wait()
 
-		if proc := p2.cmd.Process; proc != nil {
+		if proc := osProcess(p2); proc != nil {
 			t.Fatalf("P.Cmd.Process returned %v expected %v", proc, nil)
-		} else if running := p2.running; running != false {
-			t.Fatalf("P.Running returned %t expected %t", running, false)
-		} else if failed := p2.failed; failed != false {
-			t.Fatalf("P.Failed returned %t expected %t", failed, false)
+		} else if state := p2.State(); state != StateStopped {
+			t.Fatalf("P.State returned %s expected %s", state, StateStopped)
 		}
 	})
 	t.Run("Failed", func(t *testing.T) {
@@ -248,22 +223,95 @@ func TestProcessKill(t *testing.T) {
 		p3.Kill()
 		wg.Wait()
 
-		if proc := p3.cmd.Process; proc != nil {
+		if proc := osProcess(p3); proc != nil {
 			t.Fatalf("P.Cmd.Process returned %v expected %v", proc, nil)
-		} else if running := p3.running; running != false {
-			t.Fatalf("P.Running returned %t expected %t", running, false)
-		} else if failed := p3.failed; failed != true {
-			t.Fatalf("P.Failed returned %t expected %t", failed, true)
+		} else if state := p3.State(); state != StateFailed {
+			t.Fatalf("P.State returned %s expected %s", state, StateFailed)
 		}
 	})
 
-	if p1.cmd.Process != nil {
-		p1.cmd.Process.Kill()
-	}
-	if p2.cmd.Process != nil {
-		p2.cmd.Process.Kill()
-	}
-	if p3.cmd.Process != nil {
-		p3.cmd.Process.Kill()
-	}
-}
\ No newline at end of file
+	killTestProcesses(p1, p2, p3)
+}
+
+func TestProcessStopEscalation(t *testing.T) {
+	t.Run("Graceful", func(t *testing.T) {
+		p, d := newTestProcess(0)
+		wg := syncStart(p, d)
+		<-d
+		p.StopTimeout(time.Second)
+		wg.Wait()
+
+		if sig := p.EndSignal(); sig != syscall.SIGINT {
+			t.Fatalf("P.EndSignal returned %s expected %s", signalName(sig), "SIGINT")
+		}
+	})
+	t.Run("Escalated", func(t *testing.T) {
+		p := &Process{
+			cmdstr: "sh",
+			args:   []string{"-c", "trap '' INT TERM; echo ready; while :; do :; done"},
+			stdout: newOutputBuffer(DefaultOutputLines, nil),
+			stderr: newOutputBuffer(DefaultOutputLines, nil),
+		}
+		lines, stop := p.stdout.Follow()
+		defer stop()
+		d := make(chan bool)
+		wg := syncStart(p, d)
+		<-d
+		// Wait until the signals are trapped
+		<-lines
+		if err := p.StopTimeout(50 * time.Millisecond); err != nil {
+			t.Fatal(err)
+		}
+		wg.Wait()
+
+		if sig := p.EndSignal(); sig != syscall.SIGKILL {
+			t.Fatalf("P.EndSignal returned %s expected %s", signalName(sig), "SIGKILL")
+		} else if state := p.State(); state != StateStopped {
+			t.Fatalf("P.State returned %s expected %s", state, StateStopped)
+		}
+	})
+	t.Run("Kill", func(t *testing.T) {
+		p, d := newTestProcess(0)
+		wg := syncStart(p, d)
+		<-d
+		p.Kill()
+		wg.Wait()
+
+		if sig := p.EndSignal(); sig != syscall.SIGKILL {
+			t.Fatalf("P.EndSignal returned %s expected %s", signalName(sig), "SIGKILL")
+		}
+	})
+	t.Run("KillStopping", func(t *testing.T) {
+		p := &Process{
+			cmdstr: "sh",
+			args:   []string{"-c", "trap '' INT TERM; echo ready; while :; do :; done"},
+			stdout: newOutputBuffer(DefaultOutputLines, nil),
+			stderr: newOutputBuffer(DefaultOutputLines, nil),
+		}
+		lines, stop := p.stdout.Follow()
+		defer stop()
+		d := make(chan bool)
+		wg := syncStart(p, d)
+		<-d
+		<-lines
+		stopped := make(chan error)
+		go func() {
+			stopped <- p.StopTimeout(time.Minute)
+		}()
+		for p.State() != StateStopping {
+			time.Sleep(time.Millisecond)
+		}
+		if err := p.Kill(); err != nil {
+			t.Fatalf("P.Kill returned error %v while stopping", err)
+		}
+		wg.Wait()
+
+		if err := <-stopped; err != nil {
+			t.Fatalf("P.StopTimeout returned error %v after the kill", err)
+		} else if sig := p.EndSignal(); sig != syscall.SIGKILL {
+			t.Fatalf("P.EndSignal returned %s expected %s", signalName(sig), "SIGKILL")
+		} else if state := p.State(); state != StateStopped {
+			t.Fatalf("P.State returned %s expected %s", state, StateStopped)
+		}
+	})
+}
@@ -6,15 +6,31 @@ Copyright © 2019 AVA Labs <collin@avalabs.org>
 package processmgr
 
 import (
+	"encoding/json"
 	"fmt"
+	"os"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/ava-labs/avash/cfg"
 	"github.com/olekukonko/tablewriter"
 )
 
-// ProcessManager is a system for managing processes in the system
+// ProcessManager is a system for managing processes in the system.
+// It is safe for concurrent use.
 type ProcessManager struct {
+	lock    sync.RWMutex
+	events  EventBus
+	session sessionRegistry
+	jobs    scheduler
+	chaos   *chaos
+	// crashes tracks the crash artifact bundles being collected
+	crashes sync.WaitGroup
+	// lastGen numbers the processes added, telling apart processes added under the same name
+	lastGen uint64
 	// Key: Process name
 	// Value: The corresponding process
 	processes map[string]*Process
@@ -26,16 +42,14 @@ func (pm *ProcessManager) AddProcess(cmdstr string, proctype string, args []stri
 	if pname == "" {
 		return fmt.Errorf("Process name cannot be empty")
 	}
-	_, exists := pm.processes[pname]
-	if exists {
-		return fmt.Errorf("Process with name %s already exists", pname)
-	}
+	return pm.add(pm.newProcess(cmdstr, proctype, args, pname, metadata, ih, oh, eh))
+}
+
+// newProcess returns a stopped process attached to the process manager
+func (pm *ProcessManager) newProcess(cmdstr string, proctype string, args []string, pname string, metadata string, ih InputHandler, oh OutputHandler, eh OutputHandler) *Process {
 	cout := make(chan []byte)
 	cerr := make(chan []byte)
 	cin := make(chan []byte)
-	stop := make(chan bool)
-	kill := make(chan bool)
-	fail := make(chan error)
 	p := &Process{
 		cmdstr:    cmdstr,
 		args:      args,
@@ -45,47 +59,101 @@ func (pm *ProcessManager) AddProcess(cmdstr string, proctype string, args []stri
 		cout:      cout,
 		cerr:      cerr,
 		cin:       cin,
-		stop:      stop,
-		kill:      kill,
-		fail:      fail,
+		stdout:    newOutputBuffer(DefaultOutputLines, oh),
+		stderr:    newOutputBuffer(DefaultOutputLines, eh),
+		policy:    DefaultPolicy(),
+		events:    &pm.events,
+		session:   &pm.session,
+		crashes:   &pm.crashes,
 		inhandle:  ih,
 		outhandle: oh,
 		errhandle: eh,
 	}
-	pm.processes[name] = p
+	return p
+}
+
+// add places the process into the process manager under its name
+func (pm *ProcessManager) add(p *Process) error {
+	pm.lock.Lock()
+	defer pm.lock.Unlock()
+	if _, exists := pm.processes[p.name]; exists {
+		return fmt.Errorf("Process with name %s already exists", p.name)
+	}
+	pm.lastGen++
+	p.gen = pm.lastGen
+	pm.processes[p.name] = p
 
 	return nil
 }
 
-// StartProcess starts the process at the name
-func (pm *ProcessManager) StartProcess(name string) error {
+// get returns the process at the name, or an error naming the failed `action`
+func (pm *ProcessManager) get(name string, action string) (*Process, error) {
+	pm.lock.RLock()
+	defer pm.lock.RUnlock()
 	p, ok := pm.processes[name]
 	if !ok {
-		return fmt.Errorf("Process does not exist, cannot start: %s", name)
+		return nil, fmt.Errorf("Process does not exist, cannot %s: %s", action, name)
+	}
+	return p, nil
+}
+
+// list returns a snapshot of all processes sorted by name
+func (pm *ProcessManager) list() []*Process {
+	pm.lock.RLock()
+	defer pm.lock.RUnlock()
+	procs := make([]*Process, 0, len(pm.processes))
+	for _, p := range pm.processes {
+		procs = append(procs, p)
+	}
+	sort.Slice(procs, func(i, j int) bool {
+		return procs[i].name < procs[j].name
+	})
+	return procs
+}
+
+// StartProcess starts the process at the name, returning an error if it fails to launch
+func (pm *ProcessManager) StartProcess(name string) error {
+	p, err := pm.get(name, "start")
+	if err != nil {
+		return err
 	}
+	// An explicit start supersedes any pending restart and resets the count
+	p.resetRestarts()
 	done := make(chan bool)
 	go p.Start(done)
-	<-done
+	if !<-done {
+		return fmt.Errorf("Process failed to start: %s", name)
+	}
 	return nil
 }
 
-// StopProcess stops the process at the name
+// StopProcess stops the process at the name, escalating after DefaultStopTimeout
 func (pm *ProcessManager) StopProcess(name string) error {
-	p, ok := pm.processes[name]
-	if !ok {
-		return fmt.Errorf("Process does not exist, cannot stop: %s", name)
+	return pm.StopProcessTimeout(name, DefaultStopTimeout)
+}
+
+// StopProcessTimeout stops the process at the name, escalating from SIGINT to
+// SIGTERM to SIGKILL whenever it has not exited after `timeout`
+func (pm *ProcessManager) StopProcessTimeout(name string, timeout time.Duration) error {
+	p, err := pm.get(name, "stop")
+	if err != nil {
+		return err
 	}
-	return p.Stop()
+	return p.StopTimeout(timeout)
 }
 
 // StopAllProcesses calls Stop() on every running process, logging errors
 func (pm *ProcessManager) StopAllProcesses() {
+	pm.StopAllProcessesTimeout(DefaultStopTimeout)
+}
+
+// StopAllProcessesTimeout calls StopTimeout(timeout) on every running process, logging errors
+func (pm *ProcessManager) StopAllProcessesTimeout(timeout time.Duration) {
 	existsRunning := false
-	for name := range pm.processes {
-		if pm.processes[name].running {
+	for _, p := range pm.list() {
+		if p.State().alive() {
 			existsRunning = true
-			err := pm.StopProcess(name)
-			if err != nil {
+			if err := p.StopTimeout(timeout); err != nil {
 				cfg.Config.Log.Error(err.Error())
 			}
 		}
@@ -97,21 +165,20 @@ func (pm *ProcessManager) StopAllProcesses() {
 
 // KillProcess kills the process at the name
 func (pm *ProcessManager) KillProcess(name string) error {
-	p, ok := pm.processes[name]
-	if !ok {
-		return fmt.Errorf("Process does not exist, cannot kill: %s", name)
+	p, err := pm.get(name, "kill")
+	if err != nil {
+		return err
 	}
 	return p.Kill()
 }
 
-// KillAllProcesses calls Kill() on every running process, logging errors
+// KillAllProcesses calls Kill() on every running or stopping process, logging errors
 func (pm *ProcessManager) KillAllProcesses() {
 	existsRunning := false
-	for name := range pm.processes {
-		if pm.processes[name].running {
+	for _, p := range pm.list() {
+		if state := p.State(); state.alive() || state == StateStopping {
 			existsRunning = true
-			err := pm.KillProcess(name)
-			if err != nil {
+			if err := p.Kill(); err != nil {
 				cfg.Config.Log.Error(err.Error())
 			}
 		}
@@ -124,11 +191,10 @@ func (pm *ProcessManager) KillAllProcesses() {
 // StartAllProcesses calls Start() on every stopped process, logging errors
 func (pm *ProcessManager) StartAllProcesses() {
 	existsStopped := false
-	for name := range pm.processes {
-		if !pm.processes[name].running {
+	for _, p := range pm.list() {
+		if state := p.State(); state == StateStopped || state == StateFailed {
 			existsStopped = true
-			err := pm.StartProcess(name)
-			if err != nil {
+			if err := pm.StartProcess(p.name); err != nil {
 				cfg.Config.Log.Error(err.Error())
 			}
 		}
@@ -140,74 +206,396 @@ func (pm *ProcessManager) StartAllProcesses() {
 
 // RemoveProcess removes a process from the list of available named processes
 func (pm *ProcessManager) RemoveProcess(name string) error {
-	if _, ok := pm.processes[name]; !ok {
-		return fmt.Errorf("Process does not exist, cannot remove: %s", name)
+	p, err := pm.get(name, "remove")
+	if err != nil {
+		return err
 	}
-	if pm.processes[name].running {
-		if err := pm.StopProcess(name); err != nil {
-			return err
-		}
+	if err := p.retire(); err != nil {
+		return err
 	}
-	delete(pm.processes, name)
+	// The last run finishes recording its exit before the process is gone
+	p.lock.Lock()
+	exited := p.exited
+	p.lock.Unlock()
+	if exited != nil {
+		<-exited
+	}
+	pm.lock.Lock()
+	// A concurrent remove may already have replaced the entry
+	if pm.processes[name] == p {
+		delete(pm.processes, name)
+	}
+	pm.lock.Unlock()
+	pm.jobs.forget(name, p.gen)
 	cfg.Config.Log.Info("Process removed: %s", name)
 	return nil
 }
 
-// ProcessTable returns a formatted metadata table for the data provided
-func (pm *ProcessManager) ProcessTable(table *tablewriter.Table) *tablewriter.Table {
-	table.SetHeader([]string{"Name", "Status", "Metadata", "Command"})
-	table.SetBorder(false)
+// WaitArtifacts blocks until the crash artifact bundles being collected are saved
+func (pm *ProcessManager) WaitArtifacts() {
+	pm.crashes.Wait()
+}
 
-	table.SetHeaderColor(tablewriter.Colors{tablewriter.Bold, tablewriter.BgBlueColor},
-		tablewriter.Colors{tablewriter.Bold, tablewriter.BgMagentaColor, tablewriter.FgWhiteColor},
-		tablewriter.Colors{tablewriter.Bold, tablewriter.BgMagentaColor, tablewriter.FgWhiteColor},
-		tablewriter.Colors{tablewriter.Bold, tablewriter.BgMagentaColor, tablewriter.FgWhiteColor})
-
-	table.SetColumnColor(tablewriter.Colors{tablewriter.Bold},
-		tablewriter.Colors{tablewriter.Normal},
-		tablewriter.Colors{tablewriter.Normal},
-		tablewriter.Colors{tablewriter.Normal})
-	psd := pm.ProcessSummary()
-	table.AppendBulk(*psd)
+// ProcessTable returns a formatted metadata table for the named processes, or for all processes if none are named.
+// With `stats`, the resource usage columns of StatsTable are included.
+func (pm *ProcessManager) ProcessTable(table *tablewriter.Table, stats bool, names ...string) *tablewriter.Table {
+	header := []string{"Name", "Status", "Labels", "Restarts", "Last Exit", "Last Failure", "Version"}
+	if stats {
+		header = append(header, statsHeader...)
+	}
+	header = append(header, "Metadata", "Command")
+	setTableStyle(table, header)
+
+	for _, p := range pm.list() {
+		if len(names) > 0 && !contains(names, p.name) {
+			continue
+		}
+		row := p.summary()
+		if stats {
+			// Resource usage goes before the trailing metadata and command columns
+			i := len(row) - 2
+			row = append(append(append([]string{}, row[:i]...), p.stats().row()...), row[i:]...)
+		}
+		table.Append(row)
+	}
 	table.SetReflowDuringAutoWrap(true)
 	return table
 }
 
-// ProcessSummary returns data table of all processes and their statuses
-func (pm *ProcessManager) ProcessSummary() *[][]string {
+// StatsTable returns a formatted resource usage table for the named processes, or for all processes if none are named
+func (pm *ProcessManager) StatsTable(table *tablewriter.Table, names ...string) *tablewriter.Table {
+	setTableStyle(table, append([]string{"Name"}, statsHeader...))
+	for _, s := range pm.Stats(names...) {
+		table.Append(append([]string{s.Name}, s.row()...))
+	}
+	return table
+}
+
+// setTableStyle sets the header of the table, highlighting the first column
+func setTableStyle(table *tablewriter.Table, header []string) {
+	table.SetHeader(header)
+	table.SetBorder(false)
+
+	headerColors := make([]tablewriter.Colors, len(header))
+	columnColors := make([]tablewriter.Colors, len(header))
+	for i := range header {
+		headerColors[i] = tablewriter.Colors{tablewriter.Bold, tablewriter.BgMagentaColor, tablewriter.FgWhiteColor}
+		columnColors[i] = tablewriter.Colors{tablewriter.Normal}
+	}
+	headerColors[0] = tablewriter.Colors{tablewriter.Bold, tablewriter.BgBlueColor}
+	columnColors[0] = tablewriter.Colors{tablewriter.Bold}
+	table.SetHeaderColor(headerColors...)
+	table.SetColumnColor(columnColors...)
+}
+
+// ProcessSummary returns data table of the named processes, or of all processes if none are named, and their statuses
+func (pm *ProcessManager) ProcessSummary(names ...string) *[][]string {
 	var data [][]string
-	for _, val := range pm.processes {
-		var running string
-		if val.running {
-			running = "running"
-		} else if val.failed {
-			running = "defunct"
-		} else {
-			running = "stopped"
+	for _, val := range pm.list() {
+		if len(names) > 0 && !contains(names, val.name) {
+			continue
 		}
-		cmd := val.cmdstr + " " + strings.Join(val.args, " ")
-		line := []string{val.name, running, val.metadata, cmd}
-		data = append(data, line)
+		data = append(data, val.summary())
 	}
 	return &data
 
 }
 
+// ProcessInfo describes a process for structured command results
+type ProcessInfo struct {
+	Name     string `json:"name" yaml:"name"`
+	Status   string `json:"status" yaml:"status"`
+	Labels   Labels `json:"labels,omitempty" yaml:"labels,omitempty"`
+	Restarts int    `json:"restarts" yaml:"restarts"`
+	// Version is the version of the program run by the process, if known
+	Version string `json:"version,omitempty" yaml:"version,omitempty"`
+	// Env are the environment variable overrides and WorkDir the working directory, if set
+	Env     []string `json:"env,omitempty" yaml:"env,omitempty"`
+	WorkDir string   `json:"workdir,omitempty" yaml:"workdir,omitempty"`
+	// ExitCode, Reason and FailedAt describe the last failure, if any
+	ExitCode *int   `json:"exit-code,omitempty" yaml:"exit-code,omitempty"`
+	Reason   string `json:"reason,omitempty" yaml:"reason,omitempty"`
+	FailedAt string `json:"failed-at,omitempty" yaml:"failed-at,omitempty"`
+	// Signal is the signal that ended the process if it was stopped
+	Signal string `json:"signal,omitempty" yaml:"signal,omitempty"`
+	// Metadata is decoded if it is JSON
+	Metadata interface{} `json:"metadata,omitempty" yaml:"metadata,omitempty"`
+	Command  string      `json:"command" yaml:"command"`
+	Stats    *Stats      `json:"stats,omitempty" yaml:"stats,omitempty"`
+}
+
+// Processes describes the named processes, or all processes if none are named,
+// including their resource usage if `stats` is true
+func (pm *ProcessManager) Processes(stats bool, names ...string) []ProcessInfo {
+	infos := []ProcessInfo{}
+	for _, p := range pm.list() {
+		if len(names) > 0 && !contains(names, p.name) {
+			continue
+		}
+		info := p.info()
+		if stats {
+			s := p.stats()
+			info.Stats = &s
+		}
+		infos = append(infos, info)
+	}
+	return infos
+}
+
+// info describes the process
+func (p *Process) info() ProcessInfo {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	info := ProcessInfo{
+		Name:     p.name,
+		Labels:   p.labels,
+		Restarts: p.restarts,
+		Version:  p.version,
+		Env:      p.env,
+		WorkDir:  p.dir,
+		Reason:   p.reason,
+		Metadata: p.metadata,
+		Command:  p.cmdstr + " " + strings.Join(p.args, " "),
+	}
+	switch {
+	case p.state == StateFailed && p.restart != nil:
+		info.Status = "restarting"
+	case p.state == StateFailed:
+		info.Status = "defunct"
+	default:
+		info.Status = p.state.String()
+	}
+	if !p.failedAt.IsZero() {
+		code := p.exitCode
+		info.ExitCode = &code
+		info.FailedAt = p.failedAt.Format(time.RFC3339)
+	}
+	if p.state == StateStopped && p.endSignal != 0 {
+		info.Signal = signalName(p.endSignal)
+	}
+	var md interface{}
+	if err := json.Unmarshal([]byte(p.metadata), &md); err == nil {
+		info.Metadata = md
+	}
+	return info
+}
+
+// summary returns the process' row in the process summary
+func (p *Process) summary() []string {
+	info := p.info()
+	var exit string
+	if info.ExitCode != nil {
+		exit = strconv.Itoa(*info.ExitCode)
+	}
+	if info.Reason != "" {
+		exit += " (" + info.Reason + ")"
+	}
+	if info.Signal != "" {
+		exit = info.Signal
+	}
+	p.lock.Lock()
+	metadata := p.metadata
+	p.lock.Unlock()
+	return []string{info.Name, info.Status, info.Labels.String(), strconv.Itoa(info.Restarts), exit, info.FailedAt, info.Version, metadata, info.Command}
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// SetPolicy sets the supervision policy of the process at the name
+func (pm *ProcessManager) SetPolicy(name string, policy Policy) error {
+	p, err := pm.get(name, "set policy")
+	if err != nil {
+		return err
+	}
+	p.lock.Lock()
+	p.policy = policy
+	p.lock.Unlock()
+	return nil
+}
+
+// SetLimits sets the resource limits of the process at the name, applied whenever it starts
+func (pm *ProcessManager) SetLimits(name string, limits Limits) error {
+	p, err := pm.get(name, "set limits")
+	if err != nil {
+		return err
+	}
+	p.lock.Lock()
+	p.limits = limits
+	p.lock.Unlock()
+	return nil
+}
+
+// SetVersion sets the version of the program run by the process at the name, shown in its summary
+func (pm *ProcessManager) SetVersion(name string, version string) error {
+	p, err := pm.get(name, "set version")
+	if err != nil {
+		return err
+	}
+	p.lock.Lock()
+	p.version = version
+	p.lock.Unlock()
+	return nil
+}
+
 // Metadata returns the metadata given the process name
 func (pm *ProcessManager) Metadata(name string) (string, error) {
 	if name == "" {
 		return "", fmt.Errorf("Process name required")
 	}
-	if p, ok := pm.processes[name]; ok {
-		return p.metadata, nil
+	p, err := pm.get(name, "get metadata")
+	if err != nil {
+		return "", err
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.metadata, nil
+}
+
+// SetMetadata replaces the metadata of the process at the name, also in the
+// session registry if it is running
+func (pm *ProcessManager) SetMetadata(name string, metadata string) error {
+	p, err := pm.get(name, "set metadata")
+	if err != nil {
+		return err
+	}
+	p.lock.Lock()
+	p.metadata = metadata
+	p.register()
+	p.lock.Unlock()
+	return nil
+}
+
+// SetMetadataGenerated marks the metadata of the process at the name as generated
+// by avash rather than given by the user, allowing avash to complete it
+func (pm *ProcessManager) SetMetadataGenerated(name string, generated bool) error {
+	p, err := pm.get(name, "set metadata")
+	if err != nil {
+		return err
+	}
+	p.lock.Lock()
+	p.generated = generated
+	p.lock.Unlock()
+	return nil
+}
+
+// MetadataGenerated returns true if the metadata of the process at the name was
+// generated by avash
+func (pm *ProcessManager) MetadataGenerated(name string) bool {
+	p, err := pm.get(name, "get metadata")
+	if err != nil {
+		return false
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.generated
+}
+
+// Output returns the last `n` captured lines of stdout (or stderr) for the process name
+func (pm *ProcessManager) Output(name string, stderr bool, n int) ([]string, error) {
+	p, err := pm.get(name, "get output")
+	if err != nil {
+		return nil, err
+	}
+	return p.outputBuffer(stderr).Tail(n), nil
+}
+
+// FollowOutput returns a channel of new stdout (or stderr) lines for the process name,
+// along with a function that stops following
+func (pm *ProcessManager) FollowOutput(name string, stderr bool) (<-chan string, func(), error) {
+	p, err := pm.get(name, "follow output")
+	if err != nil {
+		return nil, nil, err
+	}
+	lines, stop := p.outputBuffer(stderr).Follow()
+	return lines, stop, nil
+}
+
+// Events returns the retained lifecycle events of all processes, oldest first
+func (pm *ProcessManager) Events() []Event {
+	return pm.events.History()
+}
+
+// SubscribeEvents returns a subscription receiving every new lifecycle event
+func (pm *ProcessManager) SubscribeEvents() *Subscription {
+	return pm.events.Subscribe()
+}
+
+// Orphans returns the processes left running by avash sessions that have ended
+func (pm *ProcessManager) Orphans() ([]SessionEntry, error) {
+	return pm.session.orphans()
+}
+
+// orphan returns the orphaned process at the name, or an error naming the failed `action`
+func (pm *ProcessManager) orphan(name string, action string) (SessionEntry, error) {
+	orphans, err := pm.session.orphans()
+	if err != nil {
+		return SessionEntry{}, err
+	}
+	for _, e := range orphans {
+		if e.Name == name {
+			return e, nil
+		}
+	}
+	return SessionEntry{}, fmt.Errorf("Orphaned process does not exist, cannot %s: %s", action, name)
+}
+
+// AdoptProcess reattaches the orphaned process at the name to this session,
+// after which it is managed like any process started by this session
+func (pm *ProcessManager) AdoptProcess(name string) error {
+	e, err := pm.orphan(name, "adopt")
+	if err != nil {
+		return err
 	}
-	return "", fmt.Errorf("Process does not exist, cannot get metadata: %s", name)
+	p := pm.newProcess(e.Command, e.Type, e.Args, e.Name, e.Metadata, nil, nil, nil)
+	p.labels = e.Labels
+	p.env, p.dir = e.Env, e.Dir
+	if err := pm.add(p); err != nil {
+		return err
+	}
+	if err := p.adopt(e); err != nil {
+		pm.lock.Lock()
+		delete(pm.processes, p.name)
+		pm.lock.Unlock()
+		return err
+	}
+	if err := pm.session.forget(e.Name, e.Owner); err != nil {
+		cfg.Config.Log.Error("Unable to update session registry: %s", err.Error())
+	}
+	cfg.Config.Log.Info("Process adopted: %s (pid %d)", name, e.PID)
+	return nil
+}
+
+// ReapProcess kills the orphaned process at the name
+func (pm *ProcessManager) ReapProcess(name string) error {
+	e, err := pm.orphan(name, "reap")
+	if err != nil {
+		return err
+	}
+	proc, err := os.FindProcess(e.PID)
+	if err == nil {
+		err = proc.Kill()
+	}
+	if err != nil {
+		return fmt.Errorf("Unable to reap process %s (pid %d): %s", name, e.PID, err.Error())
+	}
+	if err := pm.session.forget(e.Name, e.Owner); err != nil {
+		cfg.Config.Log.Error("Unable to update session registry: %s", err.Error())
+	}
+	cfg.Config.Log.Info("Process reaped: %s (pid %d)", name, e.PID)
+	return nil
 }
 
 // HasRunning returns true if there exists a running process, otherwise false
 func (pm *ProcessManager) HasRunning() bool {
-	for _, val := range pm.processes {
-		if val.running {
+	for _, p := range pm.list() {
+		if p.State().alive() {
 			return true
 		}
 	}
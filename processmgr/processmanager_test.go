@@ -1,9 +1,13 @@
 package processmgr
 
 import (
+	"encoding/json"
 	"fmt"
 	"reflect"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestAddProcess(t *testing.T) {
@@ -132,8 +136,8 @@ func TestStartProcess(t *testing.T) {
 	t.Run("ExistingProc", func(t *testing.T) {
 		err := pm.StartProcess(name0)
 
-		if err != nil {
-			t.Fatalf("PM.StartProcess returned %v expected %v", err, nil)
+		if failErr := fmt.Sprintf("Process failed to start: %s", name0); err == nil || err.Error() != failErr {
+			t.Fatalf("PM.StartProcess returned %v expected %v", err, failErr)
 		} else if count := len(pm.processes); count != 1 {
 			t.Fatalf("PM.Processes has length %d expected %d", count, 1)
 		} else if _, ok := pm.processes[name0]; !ok {
@@ -151,6 +155,13 @@ func TestStartProcess(t *testing.T) {
 			t.Fatalf("PM.Processes does not contain %s", name0)
 		}
 	})
+	t.Run("RunningProc", func(t *testing.T) {
+		pm.AddProcess("sleep", "sleep", []string{"10"}, "running", "", nil, nil, nil)
+		defer pm.KillProcess("running")
+		if err := pm.StartProcess("running"); err != nil {
+			t.Fatalf("PM.StartProcess returned %v expected %v", err, nil)
+		}
+	})
 }
 
 func TestStopProcess(t *testing.T) {
@@ -211,4 +222,116 @@ func TestKillProcess(t *testing.T) {
 			t.Fatalf("PM.Processes does not contain %s", name0)
 		}
 	})
-}
\ No newline at end of file
+}
+
+// Run with `go test -race` to detect unsynchronized access
+func TestConcurrentOperations(t *testing.T) {
+	pm := ProcessManager{
+		processes: make(map[string]*Process),
+	}
+	names := []string{"race0", "race1", "race2"}
+	for _, name := range names {
+		pm.AddProcess("sleep", "sleep", []string{"10"}, name, "data", nil, nil, nil)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 12; j++ {
+				name := names[(i+j)%len(names)]
+				switch (i + j) % 6 {
+				case 0:
+					pm.StartProcess(name)
+				case 1:
+					pm.StopProcess(name)
+				case 2:
+					pm.KillProcess(name)
+				case 3:
+					pm.RemoveProcess(name)
+				case 4:
+					pm.AddProcess("sleep", "sleep", []string{"10"}, name, "data", nil, nil, nil)
+				case 5:
+					pm.ProcessSummary()
+					pm.HasRunning()
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	pm.KillAllProcesses()
+	if pm.HasRunning() {
+		t.Fatalf("PM.HasRunning returned %t expected %t", true, false)
+	}
+}
+
+func TestRemovedProcessStart(t *testing.T) {
+	pm := ProcessManager{
+		processes: make(map[string]*Process),
+	}
+	name := "test"
+	pm.AddProcess("sleep", "sleep", []string{"10"}, name, "data", nil, nil, nil)
+	p := pm.processes[name]
+	pm.StartProcess(name)
+	pm.RemoveProcess(name)
+
+	done := make(chan bool)
+	go p.Start(done)
+	if started := <-done; started {
+		t.Fatalf("P.Start returned %t expected %t", started, false)
+	} else if state := p.State(); state != StateStopped {
+		t.Fatalf("P.State returned %s expected %s", state, StateStopped)
+	}
+}
+
+func TestProcesses(t *testing.T) {
+	pm := ProcessManager{
+		processes: make(map[string]*Process),
+	}
+	pm.AddProcess("sleep", "type0", []string{"10"}, "test0", `{"http-port":"9650"}`, nil, nil, nil)
+	pm.AddProcess("sleep", "type1", []string{"10"}, "test1", "data1", nil, nil, nil)
+	p := pm.processes["test1"]
+	p.state, p.exitCode, p.failedAt, p.reason = StateFailed, 2, time.Now(), ReasonAddressSpace
+	if err := pm.SetVersion("test0", "1.0.3"); err != nil {
+		t.Fatal(err)
+	}
+
+	infos := pm.Processes(false)
+	if len(infos) != 2 {
+		t.Fatalf("PM.Processes returned %d processes expected %d", len(infos), 2)
+	}
+	if md, ok := infos[0].Metadata.(map[string]interface{}); !ok || md["http-port"] != "9650" {
+		t.Fatalf("PM.Processes returned metadata %v expected decoded JSON", infos[0].Metadata)
+	} else if infos[0].ExitCode != nil || infos[0].Status != "stopped" || infos[0].Version != "1.0.3" {
+		t.Fatalf("PM.Processes returned %+v expected a stopped process without failure", infos[0])
+	}
+	if infos[1].Metadata != "data1" {
+		t.Fatalf("PM.Processes returned metadata %v expected %s", infos[1].Metadata, "data1")
+	} else if infos[1].ExitCode == nil || *infos[1].ExitCode != 2 || infos[1].Reason != ReasonAddressSpace || infos[1].Status != "defunct" {
+		t.Fatalf("PM.Processes returned %+v expected a defunct process", infos[1])
+	}
+	if row := p.summary(); row[4] != "2 ("+ReasonAddressSpace+")" {
+		t.Fatalf("P.summary returned last exit %q", row[4])
+	}
+	if row := pm.processes["test0"].summary(); row[6] != "1.0.3" {
+		t.Fatalf("P.summary returned version %q expected %q", row[6], "1.0.3")
+	}
+	if err := pm.SetMetadata("test1", "data2"); err != nil {
+		t.Fatal(err)
+	} else if md, _ := pm.Metadata("test1"); md != "data2" {
+		t.Fatalf("PM.Metadata returned %q after PM.SetMetadata expected %q", md, "data2")
+	}
+	pm.SetMetadata("test1", "data1")
+
+	if infos := pm.Processes(true, "test0"); len(infos) != 1 || infos[0].Stats == nil {
+		t.Fatalf("PM.Processes returned %+v expected stats of test0", infos)
+	}
+	data, err := json.Marshal(pm.Processes(true, "test0"))
+	if err != nil {
+		t.Fatal(err)
+	} else if !strings.Contains(string(data), `"stats":{"name":"test0","running":false`) {
+		t.Fatalf("PM.Processes marshalled to %s", data)
+	}
+}
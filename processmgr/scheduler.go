@@ -0,0 +1,246 @@
+/*
+Copyright © 2019 AVA Labs <collin@avalabs.org>
+*/
+
+package processmgr
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ava-labs/avash/cfg"
+	"github.com/olekukonko/tablewriter"
+)
+
+// Job is an action scheduled on processes
+type Job struct {
+	ID     int
+	Action string
+	// Targets are the names of the processes acted on, or nil for every process
+	Targets []string
+	// Next is when the job runs next
+	Next time.Time
+	// Every is the interval between runs of a repeating job, or 0 if it runs once
+	Every time.Duration
+	// Runs counts the runs of the job so far
+	Runs int
+}
+
+// job is a pending Job with the generations of its targets at scheduling time,
+// so that it never acts on a process replaced under the same name
+type job struct {
+	Job
+	gens  map[string]uint64
+	run   func(names []string)
+	timer *time.Timer
+}
+
+// scheduler holds the pending jobs of a process manager.
+// The zero value is ready to use.
+type scheduler struct {
+	lock   sync.Mutex
+	lastID int
+	jobs   map[int]*job
+}
+
+// Schedule runs `run` on the named processes after `delay`, and then every
+// `every` if it is positive. A repeating job without a delay first runs after
+// `every`. With no names, the job acts on every process at the time it runs
+// and `run` receives nil. Otherwise the job is bound to the current generation
+// of each named process: it skips processes removed or replaced since, and is
+// cancelled once none of them remain.
+func (pm *ProcessManager) Schedule(action string, names []string, delay time.Duration, every time.Duration, run func(names []string)) (Job, error) {
+	if delay < 0 || every < 0 {
+		return Job{}, fmt.Errorf("Job delay and interval cannot be negative")
+	}
+	if delay == 0 {
+		delay = every
+	}
+	var gens map[string]uint64
+	if len(names) > 0 {
+		gens = make(map[string]uint64, len(names))
+		for _, name := range names {
+			p, err := pm.get(name, "schedule "+action)
+			if err != nil {
+				return Job{}, err
+			}
+			gens[name] = p.gen
+		}
+	}
+
+	s := &pm.jobs
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.jobs == nil {
+		s.jobs = make(map[int]*job)
+	}
+	s.lastID++
+	j := &job{
+		Job: Job{
+			ID:      s.lastID,
+			Action:  action,
+			Targets: append([]string(nil), names...),
+			Next:    time.Now().Add(delay),
+			Every:   every,
+		},
+		gens: gens,
+		run:  run,
+	}
+	id := j.ID
+	j.timer = time.AfterFunc(delay, func() { pm.fire(id) })
+	s.jobs[id] = j
+	return j.Job, nil
+}
+
+// fire runs the job, then reschedules it if it repeats
+func (pm *ProcessManager) fire(id int) {
+	log := cfg.Config.Log
+	s := &pm.jobs
+	s.lock.Lock()
+	j, ok := s.jobs[id]
+	if !ok {
+		// Cancelled while its timer fired
+		s.lock.Unlock()
+		return
+	}
+	j.Runs++
+	if j.Every > 0 {
+		j.Next = time.Now().Add(j.Every)
+	} else {
+		delete(s.jobs, id)
+	}
+	targets, run := j.Targets, j.run
+	gens := make([]uint64, len(targets))
+	for i, name := range targets {
+		gens[i] = j.gens[name]
+	}
+	s.lock.Unlock()
+
+	var names []string
+	for i, name := range targets {
+		if p, err := pm.get(name, j.Action); err != nil || p.gen != gens[i] {
+			log.Info("Job %d skipped %s: process removed or replaced", id, name)
+			continue
+		}
+		names = append(names, name)
+	}
+	if len(targets) > 0 && len(names) == 0 {
+		pm.jobs.cancel(id)
+		log.Info("Job %d cancelled: no target processes remain", id)
+		return
+	}
+	log.Info("Running job %d: %s", id, j.describe(names))
+	run(names)
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if j, ok := s.jobs[id]; ok {
+		j.timer = time.AfterFunc(time.Until(j.Next), func() { pm.fire(id) })
+	}
+}
+
+// describe names the action of the job and the processes it acts on
+func (j *job) describe(names []string) string {
+	if names == nil {
+		return j.Action + " (all processes)"
+	}
+	return j.Action + " " + strings.Join(names, ", ")
+}
+
+// cancel removes the job, returning false if it is not pending
+func (s *scheduler) cancel(id int) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	j, ok := s.jobs[id]
+	if !ok {
+		return false
+	}
+	j.timer.Stop()
+	delete(s.jobs, id)
+	return true
+}
+
+// forget removes the process generation `gen` from the targets of every job,
+// cancelling the jobs left without targets
+func (s *scheduler) forget(name string, gen uint64) {
+	log := cfg.Config.Log
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for id, j := range s.jobs {
+		if g, ok := j.gens[name]; !ok || g != gen {
+			continue
+		}
+		delete(j.gens, name)
+		var targets []string
+		for _, t := range j.Targets {
+			if t != name {
+				targets = append(targets, t)
+			}
+		}
+		j.Targets = targets
+		if len(targets) == 0 {
+			j.timer.Stop()
+			delete(s.jobs, id)
+			log.Info("Job %d cancelled: no target processes remain", id)
+		}
+	}
+}
+
+// Jobs returns the pending jobs sorted by ID
+func (pm *ProcessManager) Jobs() []Job {
+	s := &pm.jobs
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	jobs := make([]Job, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		job := j.Job
+		job.Targets = append([]string(nil), j.Targets...)
+		jobs = append(jobs, job)
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].ID < jobs[j].ID
+	})
+	return jobs
+}
+
+// CancelJob cancels the pending job with the ID
+func (pm *ProcessManager) CancelJob(id int) error {
+	if !pm.jobs.cancel(id) {
+		return fmt.Errorf("Job does not exist: %d", id)
+	}
+	return nil
+}
+
+// JobsTable returns a formatted table of the pending jobs
+func (pm *ProcessManager) JobsTable(table *tablewriter.Table) *tablewriter.Table {
+	setTableStyle(table, []string{"ID", "Action", "Targets", "Next", "ETA", "Every", "Runs"})
+	now := time.Now()
+	for _, j := range pm.Jobs() {
+		targets := strings.Join(j.Targets, ", ")
+		if j.Targets == nil {
+			targets = "(all)"
+		}
+		every := ""
+		if j.Every > 0 {
+			every = j.Every.String()
+		}
+		eta := j.Next.Sub(now)
+		if eta < 0 {
+			eta = 0
+		}
+		table.Append([]string{
+			strconv.Itoa(j.ID),
+			j.Action,
+			targets,
+			j.Next.Format(time.RFC3339),
+			eta.Round(time.Second).String(),
+			every,
+			strconv.Itoa(j.Runs),
+		})
+	}
+	return table
+}
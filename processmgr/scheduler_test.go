@@ -0,0 +1,133 @@
+package processmgr
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Records the names passed to every run of a job
+type runRecorder struct {
+	lock sync.Mutex
+	runs [][]string
+	ran  chan struct{}
+}
+
+func newRunRecorder() *runRecorder {
+	return &runRecorder{ran: make(chan struct{}, 16)}
+}
+
+func (r *runRecorder) run(names []string) {
+	r.lock.Lock()
+	r.runs = append(r.runs, names)
+	r.lock.Unlock()
+	r.ran <- struct{}{}
+}
+
+func (r *runRecorder) get() [][]string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.runs
+}
+
+func newSchedulerTestPM(names ...string) *ProcessManager {
+	pm := &ProcessManager{
+		processes: make(map[string]*Process),
+	}
+	for _, name := range names {
+		pm.AddProcess("sleep", "sleep", []string{"10"}, name, "", nil, nil, nil)
+	}
+	return pm
+}
+
+func TestSchedule(t *testing.T) {
+	pm := newSchedulerTestPM("p1", "p2")
+	r := newRunRecorder()
+	j, err := pm.Schedule("kill", []string{"p1", "p2"}, 20*time.Millisecond, 0, r.run)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if jobs := pm.Jobs(); len(jobs) != 1 || jobs[0].ID != j.ID || jobs[0].Action != "kill" {
+		t.Fatalf("PM.Jobs returned %+v expected job %d", jobs, j.ID)
+	}
+	<-r.ran
+	if runs := r.get(); !reflect.DeepEqual(runs, [][]string{{"p1", "p2"}}) {
+		t.Fatalf("job ran with %v expected %v", runs, [][]string{{"p1", "p2"}})
+	}
+	if jobs := pm.Jobs(); len(jobs) != 0 {
+		t.Fatalf("PM.Jobs returned %+v after a one-shot job ran", jobs)
+	}
+
+	if _, err := pm.Schedule("kill", []string{"missing"}, time.Second, 0, r.run); err == nil {
+		t.Fatalf("PM.Schedule returned no error for a missing process")
+	}
+	if _, err := pm.Schedule("kill", nil, -time.Second, 0, r.run); err == nil {
+		t.Fatalf("PM.Schedule returned no error for a negative delay")
+	}
+}
+
+func TestScheduleAll(t *testing.T) {
+	pm := newSchedulerTestPM()
+	r := newRunRecorder()
+	if _, err := pm.Schedule("killall", nil, time.Millisecond, 0, r.run); err != nil {
+		t.Fatal(err)
+	}
+	<-r.ran
+	if runs := r.get(); len(runs) != 1 || runs[0] != nil {
+		t.Fatalf("job ran with %v expected all processes", runs)
+	}
+}
+
+func TestCancelJob(t *testing.T) {
+	pm := newSchedulerTestPM("p1")
+	r := newRunRecorder()
+	j, _ := pm.Schedule("stop", []string{"p1"}, 50*time.Millisecond, 0, r.run)
+	if err := pm.CancelJob(j.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := pm.CancelJob(j.ID); err == nil {
+		t.Fatalf("PM.CancelJob returned no error for a cancelled job")
+	}
+	time.Sleep(100 * time.Millisecond)
+	if runs := r.get(); len(runs) != 0 {
+		t.Fatalf("cancelled job ran with %v", runs)
+	}
+}
+
+func TestRepeatingJob(t *testing.T) {
+	pm := newSchedulerTestPM("p1")
+	r := newRunRecorder()
+	j, _ := pm.Schedule("start", []string{"p1"}, 0, 20*time.Millisecond, r.run)
+	<-r.ran
+	<-r.ran
+	jobs := pm.Jobs()
+	if len(jobs) != 1 || jobs[0].Runs < 2 || !jobs[0].Next.After(time.Now().Add(-time.Millisecond)) {
+		t.Fatalf("PM.Jobs returned %+v expected a pending repeating job", jobs)
+	}
+	pm.CancelJob(j.ID)
+}
+
+func TestJobGeneration(t *testing.T) {
+	pm := newSchedulerTestPM("p1", "p2")
+	r := newRunRecorder()
+	// Removing every target cancels the job
+	pm.Schedule("kill", []string{"p1"}, 30*time.Millisecond, 0, r.run)
+	pm.RemoveProcess("p1")
+	if jobs := pm.Jobs(); len(jobs) != 0 {
+		t.Fatalf("PM.Jobs returned %+v after removing the only target", jobs)
+	}
+
+	// A process re-added under the same name is not acted on
+	pm.AddProcess("sleep", "sleep", []string{"10"}, "p1", "", nil, nil, nil)
+	j, _ := pm.Schedule("kill", []string{"p1", "p2"}, 30*time.Millisecond, 0, r.run)
+	pm.RemoveProcess("p2")
+	pm.AddProcess("sleep", "sleep", []string{"10"}, "p2", "", nil, nil, nil)
+	if jobs := pm.Jobs(); len(jobs) != 1 || !reflect.DeepEqual(jobs[0].Targets, []string{"p1"}) {
+		t.Fatalf("PM.Jobs returned %+v expected job %d on %s", jobs, j.ID, "p1")
+	}
+	<-r.ran
+	if runs := r.get(); !reflect.DeepEqual(runs, [][]string{{"p1"}}) {
+		t.Fatalf("job ran with %v expected %v", runs, [][]string{{"p1"}})
+	}
+}
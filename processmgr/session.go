@@ -0,0 +1,243 @@
+/*
+Copyright © 2019 AVA Labs <collin@avalabs.org>
+*/
+
+package processmgr
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/ava-labs/avash/cfg"
+)
+
+// SessionFile is the name of the session registry in the data directory
+const SessionFile = "session.json"
+
+// adoptPollInterval is how often an adopted process is checked for liveness
+const adoptPollInterval = 500 * time.Millisecond
+
+// sessionOwner identifies this avash session in the session registry
+var sessionOwner = os.Getpid()
+
+// SessionEntry is a running process recorded in the session registry
+type SessionEntry struct {
+	Name      string    `json:"name"`
+	PID       int       `json:"pid"`
+	Owner     int       `json:"owner"`
+	Command   string    `json:"command"`
+	Args      []string  `json:"args"`
+	Type      string    `json:"type"`
+	Metadata  string    `json:"metadata"`
+	Labels    Labels    `json:"labels,omitempty"`
+	Env       []string  `json:"env,omitempty"`
+	Dir       string    `json:"dir,omitempty"`
+	StartTime time.Time `json:"startTime"`
+}
+
+// isOrphan returns true if the session owning the entry has ended
+// but its process is still running the recorded command
+func (e SessionEntry) isOrphan() bool {
+	if e.Owner == sessionOwner || processAlive(e.Owner) || !processAlive(e.PID) {
+		return false
+	}
+	return commandMatches(e.PID, e.Command)
+}
+
+// sessionRegistry persists the running processes of every avash session
+// sharing the data directory, so later sessions can find them.
+// The zero value is ready to use.
+type sessionRegistry struct {
+	lock sync.Mutex
+}
+
+// sessionPath returns the location of the session registry, or "" if there is no data directory
+func sessionPath() string {
+	if cfg.Config.DataDir == "" {
+		return ""
+	}
+	return filepath.Join(cfg.Config.DataDir, SessionFile)
+}
+
+// read returns the registry entries. Must be called with the registry lock held.
+func (sr *sessionRegistry) read(path string) ([]SessionEntry, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var entries []SessionEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("Invalid session registry %s: %s", path, err.Error())
+	}
+	return entries, nil
+}
+
+// update replaces the registry entries with the result of `fn`, dropping
+// entries of ended sessions whose processes are gone
+func (sr *sessionRegistry) update(fn func([]SessionEntry) []SessionEntry) error {
+	path := sessionPath()
+	if path == "" {
+		return nil
+	}
+	sr.lock.Lock()
+	defer sr.lock.Unlock()
+	entries, err := sr.read(path)
+	if err != nil {
+		return err
+	}
+	var kept []SessionEntry
+	for _, e := range fn(entries) {
+		if e.Owner == sessionOwner || processAlive(e.Owner) || e.isOrphan() {
+			kept = append(kept, e)
+		}
+	}
+	data, err := json.MarshalIndent(kept, "", "  ")
+	if err != nil {
+		return err
+	}
+	// Write then rename so a crash never leaves a truncated registry
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// record adds or replaces the entry of this session's process
+func (sr *sessionRegistry) record(entry SessionEntry) error {
+	return sr.update(func(entries []SessionEntry) []SessionEntry {
+		return append(without(entries, entry.Name, entry.Owner), entry)
+	})
+}
+
+// forget removes the entry for the process name owned by `owner`
+func (sr *sessionRegistry) forget(name string, owner int) error {
+	return sr.update(func(entries []SessionEntry) []SessionEntry {
+		return without(entries, name, owner)
+	})
+}
+
+// orphans returns the entries whose processes outlived their session
+func (sr *sessionRegistry) orphans() ([]SessionEntry, error) {
+	path := sessionPath()
+	if path == "" {
+		return nil, nil
+	}
+	sr.lock.Lock()
+	entries, err := sr.read(path)
+	sr.lock.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	var orphans []SessionEntry
+	for _, e := range entries {
+		if e.isOrphan() {
+			orphans = append(orphans, e)
+		}
+	}
+	return orphans, nil
+}
+
+func without(entries []SessionEntry, name string, owner int) []SessionEntry {
+	var res []SessionEntry
+	for _, e := range entries {
+		if e.Name != name || e.Owner != owner {
+			res = append(res, e)
+		}
+	}
+	return res
+}
+
+// processAlive returns true if a process with the pid exists
+func processAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	err := syscall.Kill(pid, 0)
+	return err == nil || err == syscall.EPERM
+}
+
+// commandMatches guards against pid reuse by comparing the command line of the
+// process with `command`. Without procfs the process is assumed to match.
+func commandMatches(pid int, command string) bool {
+	data, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/cmdline", pid))
+	if err != nil {
+		_, err := os.Stat("/proc/self")
+		return err != nil
+	}
+	return strings.SplitN(string(data), "\x00", 2)[0] == command
+}
+
+// register records the running process in the session registry.
+// Must be called with the process lock held.
+func (p *Process) register() {
+	if p.session == nil || p.proc == nil {
+		return
+	}
+	err := p.session.record(SessionEntry{
+		Name:      p.name,
+		PID:       p.proc.Pid,
+		Owner:     sessionOwner,
+		Command:   p.cmdstr,
+		Args:      p.args,
+		Type:      p.proctype,
+		Metadata:  p.metadata,
+		Labels:    p.labels,
+		Env:       p.env,
+		Dir:       p.dir,
+		StartTime: p.startedAt,
+	})
+	if err != nil {
+		cfg.Config.Log.Error("Unable to record process %s in session registry: %s", p.name, err.Error())
+	}
+}
+
+// unregister removes the process from the session registry
+func (p *Process) unregister() {
+	if p.session == nil {
+		return
+	}
+	if err := p.session.forget(p.name, sessionOwner); err != nil {
+		cfg.Config.Log.Error("Unable to remove process %s from session registry: %s", p.name, err.Error())
+	}
+}
+
+// adopt takes over the orphaned process of `entry` as this process' running instance,
+// watching it until it exits since it cannot be waited on
+func (p *Process) adopt(entry SessionEntry) error {
+	proc, err := os.FindProcess(entry.PID)
+	if err != nil {
+		return err
+	}
+	p.lock.Lock()
+	if !p.transition(StateRunning, StateStopped) {
+		p.lock.Unlock()
+		return fmt.Errorf("Process is already running, cannot adopt: %s", p.name)
+	}
+	p.proc = proc
+	p.startedAt = entry.StartTime
+	exited := make(chan struct{})
+	p.exited = exited
+	p.register()
+	p.lock.Unlock()
+
+	go func() {
+		for processAlive(entry.PID) && commandMatches(entry.PID, entry.Command) {
+			time.Sleep(adoptPollInterval)
+		}
+		p.exit(exited, errAdoptedExit)
+	}()
+	return nil
+}
+
+// errAdoptedExit is the outcome of an adopted process, whose exit status cannot be known
+var errAdoptedExit = fmt.Errorf("adopted process exited with unknown status")
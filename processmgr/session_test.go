@@ -0,0 +1,152 @@
+package processmgr
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/ava-labs/avash/cfg"
+)
+
+// Points the session registry at a temporary data directory for the test
+func withDataDir(t *testing.T) func() {
+	dir, err := ioutil.TempDir("", "avash-session")
+	if err != nil {
+		t.Fatal(err)
+	}
+	prev := cfg.Config.DataDir
+	cfg.Config.DataDir = dir
+	return func() {
+		cfg.Config.DataDir = prev
+		os.RemoveAll(dir)
+	}
+}
+
+// Waits for the process `pid` to exec `command`, as only then does it match its registry entry
+func waitExec(t *testing.T, pid int, command string) {
+	for i := 0; !commandMatches(pid, command); i++ {
+		if i == 100 {
+			t.Fatalf("process %d did not exec %s", pid, command)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// Starts a `sleep` process recorded as orphaned by an ended session
+func startOrphan(t *testing.T, sr *sessionRegistry, name string) *exec.Cmd {
+	ended := exec.Command("true")
+	if err := ended.Run(); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command("sleep", "10")
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	go cmd.Wait()
+	waitExec(t, cmd.Process.Pid, "sleep")
+	err := sr.record(SessionEntry{
+		Name:      name,
+		PID:       cmd.Process.Pid,
+		Owner:     ended.ProcessState.Pid(),
+		Command:   "sleep",
+		Args:      []string{"10"},
+		StartTime: time.Now(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cmd
+}
+
+func TestSessionRegistry(t *testing.T) {
+	defer withDataDir(t)()
+	var sr sessionRegistry
+
+	if err := sr.record(SessionEntry{Name: "n1", PID: os.Getpid(), Owner: sessionOwner}); err != nil {
+		t.Fatal(err)
+	}
+	if entries, err := sr.read(sessionPath()); err != nil {
+		t.Fatal(err)
+	} else if len(entries) != 1 || entries[0].Name != "n1" {
+		t.Fatalf("SR.record stored %v expected entry %s", entries, "n1")
+	}
+	if orphans, err := sr.orphans(); err != nil {
+		t.Fatal(err)
+	} else if len(orphans) != 0 {
+		t.Fatalf("SR.orphans returned %v for a live session", orphans)
+	}
+	if err := sr.forget("n1", sessionOwner); err != nil {
+		t.Fatal(err)
+	}
+	if entries, err := sr.read(sessionPath()); err != nil {
+		t.Fatal(err)
+	} else if len(entries) != 0 {
+		t.Fatalf("SR.forget left %v", entries)
+	}
+}
+
+func TestProcessRegistration(t *testing.T) {
+	defer withDataDir(t)()
+	pm := ProcessManager{
+		processes: make(map[string]*Process),
+	}
+	pm.AddProcess("sleep", "sleep", []string{"10"}, "p", "data", nil, nil, nil)
+	pm.StartProcess("p")
+
+	if entries, _ := pm.session.read(sessionPath()); len(entries) != 1 || entries[0].Command != "sleep" {
+		t.Fatalf("PM.StartProcess registered %v expected entry for %s", entries, "p")
+	}
+	pm.KillProcess("p")
+	if entries, _ := pm.session.read(sessionPath()); len(entries) != 0 {
+		t.Fatalf("PM.KillProcess left %v registered", entries)
+	}
+}
+
+func TestOrphans(t *testing.T) {
+	defer withDataDir(t)()
+	pm := ProcessManager{
+		processes: make(map[string]*Process),
+	}
+	adoptee := startOrphan(t, &pm.session, "adoptee")
+	reapee := startOrphan(t, &pm.session, "reapee")
+	defer adoptee.Process.Kill()
+	defer reapee.Process.Kill()
+
+	if orphans, err := pm.Orphans(); err != nil {
+		t.Fatal(err)
+	} else if len(orphans) != 2 {
+		t.Fatalf("PM.Orphans returned %v expected 2 orphans", orphans)
+	}
+
+	t.Run("Adopt", func(t *testing.T) {
+		if err := pm.AdoptProcess("adoptee"); err != nil {
+			t.Fatal(err)
+		}
+		p, _ := pm.get("adoptee", "test")
+		if state := p.State(); state != StateRunning {
+			t.Fatalf("PM.AdoptProcess state %s expected %s", state, StateRunning)
+		}
+		if err := pm.StopProcess("adoptee"); err != nil {
+			t.Fatal(err)
+		}
+		if state := p.State(); state != StateStopped {
+			t.Fatalf("PM.StopProcess state %s expected %s", state, StateStopped)
+		}
+	})
+	t.Run("Reap", func(t *testing.T) {
+		if err := pm.ReapProcess("reapee"); err != nil {
+			t.Fatal(err)
+		}
+		if err := pm.ReapProcess("reapee"); err == nil {
+			t.Fatalf("PM.ReapProcess returned %v expected error", err)
+		}
+	})
+
+	if orphans, err := pm.Orphans(); err != nil {
+		t.Fatal(err)
+	} else if len(orphans) != 0 {
+		t.Fatalf("PM.Orphans returned %v expected none", orphans)
+	}
+}
@@ -0,0 +1,61 @@
+/*
+Copyright © 2019 AVA Labs <collin@avalabs.org>
+*/
+
+package processmgr
+
+// State is the lifecycle state of a process
+type State int
+
+// Enum ...
+const (
+	StateStopped State = iota
+	StateStarting
+	StateRunning
+	StateStopping
+	StateFailed
+	StatePaused
+)
+
+func (s State) String() string {
+	switch s {
+	case StateStopped:
+		return "stopped"
+	case StateStarting:
+		return "starting"
+	case StateRunning:
+		return "running"
+	case StateStopping:
+		return "stopping"
+	case StateFailed:
+		return "failed"
+	case StatePaused:
+		return "paused"
+	default:
+		return "?????"
+	}
+}
+
+// transition moves the process to state `to` if it is currently in one of `from`,
+// returning true on success. Must be called with the process lock held.
+func (p *Process) transition(to State, from ...State) bool {
+	for _, s := range from {
+		if p.state == s {
+			p.state = to
+			return true
+		}
+	}
+	return false
+}
+
+// alive returns true if the state has a live process, running or paused
+func (s State) alive() bool {
+	return s == StateRunning || s == StatePaused
+}
+
+// State returns the current lifecycle state of the process
+func (p *Process) State() State {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.state
+}
@@ -0,0 +1,236 @@
+/*
+Copyright © 2019 AVA Labs <collin@avalabs.org>
+*/
+
+package processmgr
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// clockTicks is the unit of the CPU times in /proc/<pid>/stat (USER_HZ), fixed at 100 on Linux
+const clockTicks = 100
+
+// Stats are resource usage statistics of a process
+type Stats struct {
+	Name    string
+	Running bool
+	PID     int
+	// CPU is the percentage of one core used since the previous sample,
+	// or since the process started for the first sample
+	CPU     float64
+	RSS     int64
+	FDs     int
+	Threads int
+	Uptime  time.Duration
+	// DiskUsage is the size in bytes of the process' stash directory
+	DiskUsage int64
+	// Err is set if the statistics of a running process could not be read
+	Err error
+}
+
+// statsDoc is the serialized form of Stats
+type statsDoc struct {
+	Name      string  `json:"name" yaml:"name"`
+	Running   bool    `json:"running" yaml:"running"`
+	PID       int     `json:"pid,omitempty" yaml:"pid,omitempty"`
+	CPU       float64 `json:"cpu-percent" yaml:"cpu-percent"`
+	RSS       int64   `json:"rss-bytes" yaml:"rss-bytes"`
+	FDs       int     `json:"fds" yaml:"fds"`
+	Threads   int     `json:"threads" yaml:"threads"`
+	Uptime    string  `json:"uptime,omitempty" yaml:"uptime,omitempty"`
+	DiskUsage int64   `json:"disk-bytes" yaml:"disk-bytes"`
+	Err       string  `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+func (s Stats) doc() statsDoc {
+	d := statsDoc{
+		Name:      s.Name,
+		Running:   s.Running,
+		PID:       s.PID,
+		CPU:       s.CPU,
+		RSS:       s.RSS,
+		FDs:       s.FDs,
+		Threads:   s.Threads,
+		DiskUsage: s.DiskUsage,
+	}
+	if s.Running {
+		d.Uptime = s.Uptime.Round(time.Second).String()
+	}
+	if s.Err != nil {
+		d.Err = s.Err.Error()
+	}
+	return d
+}
+
+// MarshalJSON implements json.Marshaler
+func (s Stats) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.doc())
+}
+
+// MarshalYAML implements yaml.Marshaler
+func (s Stats) MarshalYAML() (interface{}, error) {
+	return s.doc(), nil
+}
+
+// statsHeader are the table columns of Stats.row
+var statsHeader = []string{"PID", "CPU%", "RSS", "FDs", "Threads", "Uptime", "Disk"}
+
+// row returns the statistics as table columns
+func (s Stats) row() []string {
+	disk := formatBytes(s.DiskUsage)
+	if !s.Running {
+		return []string{"", "", "", "", "", "", disk}
+	}
+	pid := strconv.Itoa(s.PID)
+	uptime := s.Uptime.Round(time.Second).String()
+	if s.Err != nil {
+		return []string{pid, "?", "?", "?", "?", uptime, disk}
+	}
+	return []string{
+		pid,
+		strconv.FormatFloat(s.CPU, 'f', 1, 64),
+		formatBytes(s.RSS),
+		strconv.Itoa(s.FDs),
+		strconv.Itoa(s.Threads),
+		uptime,
+		disk,
+	}
+}
+
+// stats samples the resource usage of the process
+func (p *Process) stats() Stats {
+	p.lock.Lock()
+	s := Stats{Name: p.name}
+	startedAt := p.startedAt
+	if p.proc != nil {
+		s.Running = true
+		s.PID = p.proc.Pid
+		s.Uptime = time.Since(startedAt)
+	}
+	p.lock.Unlock()
+
+	s.DiskUsage = dirSize(p.OutputDir())
+	if !s.Running {
+		return s
+	}
+	stat, err := readProcStat(s.PID)
+	if err != nil {
+		s.Err = err
+		return s
+	}
+	s.RSS, s.Threads = stat.rss, stat.threads
+	if s.FDs, err = countFDs(s.PID); err != nil {
+		s.Err = err
+		return s
+	}
+
+	now := time.Now()
+	p.lock.Lock()
+	prevTicks, prevAt := p.cpuTicks, p.cpuAt
+	if p.cpuPID != s.PID {
+		prevTicks, prevAt = 0, startedAt
+	}
+	p.cpuPID, p.cpuTicks, p.cpuAt = s.PID, stat.ticks, now
+	p.lock.Unlock()
+	if elapsed := now.Sub(prevAt).Seconds(); elapsed > 0 && stat.ticks >= prevTicks {
+		s.CPU = float64(stat.ticks-prevTicks) / clockTicks / elapsed * 100
+	}
+	return s
+}
+
+// procStat holds the fields of /proc/<pid>/stat used for statistics
+type procStat struct {
+	ticks   uint64
+	threads int
+	rss     int64
+}
+
+func readProcStat(pid int) (procStat, error) {
+	data, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return procStat{}, err
+	}
+	// The command name may contain spaces, so fields are counted from its closing parenthesis
+	i := bytes.LastIndexByte(data, ')')
+	if i < 0 {
+		return procStat{}, fmt.Errorf("Invalid stat for pid %d", pid)
+	}
+	// fields[0] is field 3 of proc(5), the process state
+	fields := strings.Fields(string(data[i+1:]))
+	if len(fields) < 22 {
+		return procStat{}, fmt.Errorf("Invalid stat for pid %d", pid)
+	}
+	utime, err1 := strconv.ParseUint(fields[11], 10, 64)
+	stime, err2 := strconv.ParseUint(fields[12], 10, 64)
+	threads, err3 := strconv.Atoi(fields[17])
+	rss, err4 := strconv.ParseInt(fields[21], 10, 64)
+	for _, err := range []error{err1, err2, err3, err4} {
+		if err != nil {
+			return procStat{}, fmt.Errorf("Invalid stat for pid %d: %s", pid, err.Error())
+		}
+	}
+	return procStat{
+		ticks:   utime + stime,
+		threads: threads,
+		rss:     rss * int64(os.Getpagesize()),
+	}, nil
+}
+
+func countFDs(pid int) (int, error) {
+	dir, err := os.Open(fmt.Sprintf("/proc/%d/fd", pid))
+	if err != nil {
+		return 0, err
+	}
+	defer dir.Close()
+	names, err := dir.Readdirnames(-1)
+	return len(names), err
+}
+
+// dirSize returns the total size of the regular files under `dir`, skipping unreadable entries
+func dirSize(dir string) int64 {
+	if dir == "" {
+		return 0
+	}
+	var size int64
+	filepath.Walk(dir, func(_ string, info os.FileInfo, err error) error {
+		if err == nil && info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// Stats samples the resource usage of the named processes, or of all processes if none are named
+func (pm *ProcessManager) Stats(names ...string) []Stats {
+	var stats []Stats
+	for _, p := range pm.list() {
+		if len(names) > 0 && !contains(names, p.name) {
+			continue
+		}
+		stats = append(stats, p.stats())
+	}
+	return stats
+}
@@ -0,0 +1,67 @@
+package processmgr
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFormatBytes(t *testing.T) {
+	tests := map[int64]string{
+		0:         "0 B",
+		1023:      "1023 B",
+		1024:      "1.0 KiB",
+		1536:      "1.5 KiB",
+		5 << 20:   "5.0 MiB",
+		3 << 30:   "3.0 GiB",
+		1<<40 + 1: "1.0 TiB",
+	}
+	for n, expected := range tests {
+		if s := formatBytes(n); s != expected {
+			t.Fatalf("formatBytes returned %s expected %s for %d", s, expected, n)
+		}
+	}
+}
+
+func TestDirSize(t *testing.T) {
+	dir, err := ioutil.TempDir("", "avash-stats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	os.MkdirAll(filepath.Join(dir, "db"), os.ModePerm)
+	ioutil.WriteFile(filepath.Join(dir, "a"), make([]byte, 100), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "db", "b"), make([]byte, 50), 0644)
+
+	if size := dirSize(dir); size != 150 {
+		t.Fatalf("dirSize returned %d expected %d", size, 150)
+	} else if size := dirSize(""); size != 0 {
+		t.Fatalf("dirSize returned %d expected %d", size, 0)
+	}
+}
+
+func TestProcessStats(t *testing.T) {
+	if _, err := os.Stat("/proc/self/stat"); err != nil {
+		t.Skip("procfs unavailable")
+	}
+	p, d := newTestProcess(0)
+	if s := p.stats(); s.Running {
+		t.Fatalf("P.stats returned running for a stopped process")
+	}
+	wg := syncStart(p, d)
+	<-d
+	waitExec(t, osProcess(p).Pid, "sleep")
+
+	s := p.stats()
+	if !s.Running || s.PID == 0 {
+		t.Fatalf("P.stats returned %+v expected a running process", s)
+	} else if s.Err != nil {
+		t.Fatal(s.Err)
+	} else if s.Threads < 1 || s.RSS <= 0 || s.FDs < 1 {
+		t.Fatalf("P.stats returned %+v expected positive usage", s)
+	}
+
+	p.Kill()
+	wg.Wait()
+}
@@ -1,11 +1,12 @@
-cmds = {
-    "startnode node1 --db-enabled=false --staking-enabled=true --http-port=9650 --staking-port=9651 --log-level=debug --bootstrap-ips= --staking-tls-cert-file=certs/keys1/staker.crt --staking-tls-key-file=certs/keys1/staker.key",
-    "startnode node2 --db-enabled=false --staking-enabled=true --http-port=9652 --staking-port=9653 --log-level=debug --bootstrap-ips=127.0.0.1:9651 --bootstrap-ids=NodeID-7Xhw2mDxuDS44j42TCB6U5579esbSt3Lg --staking-tls-cert-file=certs/keys2/staker.crt --staking-tls-key-file=certs/keys2/staker.key",
-    "startnode node3 --db-enabled=false --staking-enabled=true --http-port=9654 --staking-port=9655 --log-level=debug --bootstrap-ips=127.0.0.1:9651 --bootstrap-ids=NodeID-7Xhw2mDxuDS44j42TCB6U5579esbSt3Lg --staking-tls-cert-file=certs/keys3/staker.crt --staking-tls-key-file=certs/keys3/staker.key",
-    "startnode node4 --db-enabled=false --staking-enabled=true --http-port=9656 --staking-port=9657 --log-level=debug --bootstrap-ips=127.0.0.1:9651 --bootstrap-ids=NodeID-7Xhw2mDxuDS44j42TCB6U5579esbSt3Lg --staking-tls-cert-file=certs/keys4/staker.crt --staking-tls-key-file=certs/keys4/staker.key",
-    "startnode node5 --db-enabled=false --staking-enabled=true --http-port=9658 --staking-port=9659 --log-level=debug --bootstrap-ips=127.0.0.1:9651 --bootstrap-ids=NodeID-7Xhw2mDxuDS44j42TCB6U5579esbSt3Lg --staking-tls-cert-file=certs/keys5/staker.crt --staking-tls-key-file=certs/keys5/staker.key",
-}
+avash_call("startnode node1 --db-enabled=false --staking-enabled=true --http-port=9650 --staking-port=9651 --log-level=debug --bootstrap-ips= --staking-tls-cert-file=certs/keys1/staker.crt --staking-tls-key-file=certs/keys1/staker.key")
 
-for key, cmd in ipairs(cmds) do
-    avash_call(cmd)
+-- The NodeID of node1 is derived from its staking certificate
+local node1, err = avash.node_info("node1")
+if node1 == nil then
+    error(err)
+end
+
+for i = 2, 5 do
+    local port = 9648 + 2 * i
+    avash_call(string.format("startnode node%d --db-enabled=false --staking-enabled=true --http-port=%d --staking-port=%d --log-level=debug --bootstrap-ips=127.0.0.1:9651 --bootstrap-ids=%s --staking-tls-cert-file=certs/keys%d/staker.crt --staking-tls-key-file=certs/keys%d/staker.key", i, port, port + 1, node1.node_id, i, i))
 end
@@ -4,8 +4,8 @@ cmds = {
     "startnode node3 --db-enabled=false --staking-enabled=true --http-port=9654 --staking-port=9655 --log-level=debug --bootstrap-ips=127.0.0.1:9651 --bootstrap-ids=NodeID-7Xhw2mDxuDS44j42TCB6U5579esbSt3Lg --staking-tls-cert-file=certs/keys3/staker.crt --staking-tls-key-file=certs/keys3/staker.key",
     "startnode node4 --db-enabled=false --staking-enabled=true --http-port=9656 --staking-port=9657 --log-level=debug --bootstrap-ips=127.0.0.1:9651 --bootstrap-ids=NodeID-7Xhw2mDxuDS44j42TCB6U5579esbSt3Lg --staking-tls-cert-file=certs/keys4/staker.crt --staking-tls-key-file=certs/keys4/staker.key",
     "startnode node5 --db-enabled=false --staking-enabled=true --http-port=9658 --staking-port=9659 --log-level=debug --bootstrap-ips=127.0.0.1:9651 --bootstrap-ids=NodeID-7Xhw2mDxuDS44j42TCB6U5579esbSt3Lg --staking-tls-cert-file=certs/keys5/staker.crt --staking-tls-key-file=certs/keys5/staker.key",
-    "startnode node6 --db-enabled=false --staking-enabled=true --http-port=9660 --staking-port=9671 --log-level=debug --bootstrap-ips=127.0.0.1:9651 --bootstrap-ids=NodeID-7Xhw2mDxuDS44j42TCB6U5579esbSt3Lg --staking-tls-cert-file=certs/keys6/staker.crt --staking-tls-key-file=certs/keys6/staker.key",
-    "startnode node7 --db-enabled=false --staking-enabled=true --http-port=9662 --staking-port=9673 --log-level=debug --bootstrap-ips=127.0.0.1:9651 --bootstrap-ids=NodeID-7Xhw2mDxuDS44j42TCB6U5579esbSt3Lg --staking-tls-cert-file=certs/keys7/staker.crt --staking-tls-key-file=certs/keys7/staker.key",
+    "startnode node6 --db-enabled=false --staking-enabled=true --http-port=9660 --staking-port=9661 --log-level=debug --bootstrap-ips=127.0.0.1:9651 --bootstrap-ids=NodeID-7Xhw2mDxuDS44j42TCB6U5579esbSt3Lg --staking-tls-cert-file=certs/keys6/staker.crt --staking-tls-key-file=certs/keys6/staker.key",
+    "startnode node7 --db-enabled=false --staking-enabled=true --http-port=9662 --staking-port=9663 --log-level=debug --bootstrap-ips=127.0.0.1:9651 --bootstrap-ids=NodeID-7Xhw2mDxuDS44j42TCB6U5579esbSt3Lg --staking-tls-cert-file=certs/keys7/staker.crt --staking-tls-key-file=certs/keys7/staker.key",
 }
 
 for key, cmd in ipairs(cmds) do